@@ -19,8 +19,10 @@ package main
 import (
 	"context"
 	"flag"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/api"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/connector"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/lint"
 	"log"
 	"os"
 	"os/signal"
@@ -32,6 +34,10 @@ func main() {
 	configLocation := flag.String("config", "config.json", "configuration file")
 	flag.Parse()
 
+	if flag.Arg(0) == "lint" {
+		os.Exit(lint.Command(*configLocation, flag.Args()[1:], os.Stdout, os.Stderr))
+	}
+
 	config, err := configuration.Load(*configLocation)
 	if err != nil {
 		log.Fatal(err)
@@ -39,7 +45,13 @@ func main() {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	_, err = connector.New(ctx, config)
+	conn, err := connector.New(ctx, config)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	err = api.Start(ctx, config, conn)
 	if err != nil {
 		log.Fatal(err)
 		return
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/connector"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/metrics"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Connector provides the runtime information exposed by the admin api
+type Connector interface {
+	GetRegistries() connector.RegistryInfo
+	GetCorrelations() map[string][]connector.CorrelationInfo
+	GetDeviceStates() map[string]connector.DeviceStateInfo
+	GetLastUpdate() (result connector.UpdateResult, ok bool)
+	GetMetrics() *metrics.Metrics
+	RefreshDeviceInfo()
+	GetHeldRemovals() connector.HeldRemovalsInfo
+	ConfirmRemovals() (confirmed int, err error)
+}
+
+// Start serves the admin api on config.ApiHost:config.ApiPort until ctx is done; an empty port or "-" disables the api
+func Start(ctx context.Context, config configuration.Config, conn Connector) error {
+	if config.ApiPort == "" || config.ApiPort == "-" {
+		return nil
+	}
+	if config.ApiHost == "" && config.ApiToken == "" {
+		log.Println("WARNING: admin api listens on all interfaces without api_token")
+	}
+	server := &http.Server{Addr: net.JoinHostPort(config.ApiHost, config.ApiPort), Handler: New(config, conn), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		log.Println("listening on ", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println("ERROR: api server error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		log.Println("api shutdown", server.Shutdown(context.Background()))
+	}()
+	return nil
+}
+
+func New(config configuration.Config, conn Connector) http.Handler {
+	router := httprouter.New()
+
+	router.Handler(http.MethodGet, "/metrics", conn.GetMetrics().Handler())
+
+	router.GET("/registries", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		writeJson(writer, conn.GetRegistries())
+	})
+
+	router.GET("/correlations", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		writeJson(writer, conn.GetCorrelations())
+	})
+
+	router.GET("/states", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		writeJson(writer, conn.GetDeviceStates())
+	})
+
+	router.GET("/updates/last", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		result, ok := conn.GetLastUpdate()
+		if !ok {
+			http.Error(writer, "no finished update", http.StatusNotFound)
+			return
+		}
+		writeJson(writer, result)
+	})
+
+	//runs a device registry update and returns its result
+	router.POST("/refresh", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		conn.RefreshDeviceInfo()
+		result, _ := conn.GetLastUpdate()
+		writeJson(writer, result)
+	})
+
+	router.GET("/removals", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		writeJson(writer, conn.GetHeldRemovals())
+	})
+
+	//applies the device removals held back by the removal guard; without api_token removals may only be confirmed by control message
+	router.POST("/removals/confirm", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		if config.ApiToken == "" {
+			http.Error(writer, "confirming removals requires api_token; use the control message "+connector.ControlConfirmRemovals, http.StatusForbidden)
+			return
+		}
+		confirmed, err := conn.ConfirmRemovals()
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(writer, map[string]int{"confirmed": confirmed})
+	})
+
+	if config.ApiToken == "" {
+		return router
+	}
+	return requireToken(config.ApiToken, router)
+}
+
+// requireToken rejects requests without the header "Authorization: Bearer <token>"
+func requireToken(token string, handler http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(request.Header.Get("Authorization")), expected) != 1 {
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(writer, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(writer, request)
+	})
+}
+
+func writeJson(writer http.ResponseWriter, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	err := json.NewEncoder(writer).Encode(value)
+	if err != nil {
+		log.Println("ERROR: unable to encode api response", err)
+	}
+}
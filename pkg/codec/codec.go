@@ -0,0 +1,130 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+const FormatJson = "json"
+const FormatText = "text"
+const FormatCbor = "cbor"
+const FormatMsgpack = "msgpack"
+const FormatXml = "xml"
+
+// Codec translates between the payload format of a device and the json documents used by the connector
+type Codec interface {
+	Decode(payload []byte) (jsonPayload []byte, err error) //device payload -> json
+	Encode(jsonPayload []byte) (payload []byte, err error) //json -> device payload
+}
+
+var registry = map[string]Codec{}
+var registryMux sync.RWMutex
+
+func init() {
+	Register(FormatJson, Json{})
+	Register(FormatText, Text{})
+	Register(FormatCbor, Cbor{})
+	Register(FormatMsgpack, Msgpack{})
+	Register(FormatXml, Xml{})
+}
+
+// Register adds a codec for a payload format or replaces the existing one;
+// topic descriptions select the codec by their payload_format
+func Register(format string, codec Codec) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	registry[format] = codec
+}
+
+// Get returns the codec of the payload format; an empty format is json
+func Get(format string) (Codec, error) {
+	if format == "" {
+		format = FormatJson
+	}
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+	codec, ok := registry[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown payload format %v", format)
+	}
+	return codec, nil
+}
+
+// Formats lists the registered payload formats
+func Formats() (result []string) {
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+	for format := range registry {
+		result = append(result, format)
+	}
+	slices.Sort(result)
+	return result
+}
+
+// Decode translates a device payload of the format to json
+func Decode(format string, payload []byte) ([]byte, error) {
+	codec, err := Get(format)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(payload)
+}
+
+// Encode translates a json payload to the format of the device
+func Encode(format string, jsonPayload []byte) ([]byte, error) {
+	codec, err := Get(format)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Encode(jsonPayload)
+}
+
+// unmarshalJson parses json; integers are returned as int64 to keep them integers in binary formats
+func unmarshalJson(payload []byte) (result interface{}, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	err = decoder.Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not valid json: %w", err)
+	}
+	return replaceJsonNumbers(result), nil
+}
+
+func replaceJsonNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, element := range v {
+			v[key] = replaceJsonNumbers(element)
+		}
+	case []interface{}:
+		for i, element := range v {
+			v[i] = replaceJsonNumbers(element)
+		}
+	}
+	return value
+}
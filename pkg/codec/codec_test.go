@@ -0,0 +1,133 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCodecs(t *testing.T) {
+	cases := []struct {
+		format  string
+		payload string //hex for binary formats
+		json    string
+		binary  bool
+	}{
+		{format: "", payload: `{"a":1}`, json: `{"a":1}`},
+		{format: FormatJson, payload: `{"a":1}`, json: `{"a":1}`},
+		{format: FormatText, payload: `ON`, json: `"ON"`},
+		{format: FormatText, payload: `say "hi"`, json: `"say \"hi\""`},
+		//{"temp": 21.5, "on": true, "count": 3}
+		{format: FormatCbor, payload: "a36474656d70f94d60626f6ef565636f756e7403", json: `{"count":3,"on":true,"temp":21.5}`, binary: true},
+		//{"temp": 21.5, "list": [1, "a"]}
+		{format: FormatMsgpack, payload: "82a474656d70cb4035800000000000a46c6973749201a161", json: `{"list":[1,"a"],"temp":21.5}`, binary: true},
+		{format: FormatXml, payload: `<data unit="C"><temp>21.5</temp><on>true</on></data>`, json: `{"data":{"-unit":"C","on":true,"temp":21.5}}`},
+	}
+	for _, c := range cases {
+		payload := []byte(c.payload)
+		if c.binary {
+			var err error
+			payload, err = hex.DecodeString(c.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		decoded, err := Decode(c.format, payload)
+		if err != nil {
+			t.Error(c.format, c.payload, err)
+			continue
+		}
+		if string(decoded) != c.json {
+			t.Error(c.format, c.payload, string(decoded), c.json)
+			continue
+		}
+		//round trip
+		encoded, err := Encode(c.format, decoded)
+		if err != nil {
+			t.Error(c.format, c.payload, err)
+			continue
+		}
+		decoded, err = Decode(c.format, encoded)
+		if err != nil {
+			t.Error(c.format, c.payload, err)
+			continue
+		}
+		if string(decoded) != c.json {
+			t.Error("round trip", c.format, c.payload, string(decoded), c.json)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	result, err := Encode(FormatCbor, []byte(`{"on":true,"level":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//integers stay integers: {"on": true, "level": 3}
+	expected, _ := hex.DecodeString("a2626f6ef5656c6576656c03")
+	if !bytes.Equal(result, expected) {
+		t.Error(hex.EncodeToString(result))
+	}
+
+	result, err = Encode(FormatText, []byte(`42`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != "42" {
+		t.Error(string(result))
+	}
+
+	for _, format := range []string{FormatText, FormatCbor, FormatMsgpack, FormatXml} {
+		_, err = Encode(format, []byte(`not json`))
+		if err == nil {
+			t.Error("expected error", format)
+		}
+	}
+	for _, format := range []string{FormatCbor, FormatMsgpack, FormatXml} {
+		_, err = Decode(format, []byte{0xc1, 0xff})
+		if err == nil {
+			t.Error("expected error", format)
+		}
+	}
+}
+
+type upperCase struct{}
+
+func (this upperCase) Decode(payload []byte) ([]byte, error) {
+	return Text{}.Decode(bytes.ToUpper(payload))
+}
+
+func (this upperCase) Encode(jsonPayload []byte) ([]byte, error) {
+	return Text{}.Encode(jsonPayload)
+}
+
+func TestRegister(t *testing.T) {
+	_, err := Get("upper")
+	if err == nil {
+		t.Error("expected error for unknown format")
+	}
+	Register("upper", upperCase{})
+	result, err := Decode("upper", []byte("on"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != `"ON"` {
+		t.Error(string(result))
+	}
+}
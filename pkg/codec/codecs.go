@@ -0,0 +1,119 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/clbanning/mxj"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"reflect"
+)
+
+// Json passes payloads unchanged
+type Json struct{}
+
+func (this Json) Decode(payload []byte) ([]byte, error) {
+	return payload, nil
+}
+
+func (this Json) Encode(jsonPayload []byte) ([]byte, error) {
+	return jsonPayload, nil
+}
+
+// Text decodes any payload to a json string; json strings are encoded without quotes, other json values unchanged
+type Text struct{}
+
+func (this Text) Decode(payload []byte) ([]byte, error) {
+	return json.Marshal(string(payload))
+}
+
+func (this Text) Encode(jsonPayload []byte) ([]byte, error) {
+	var text string
+	if json.Unmarshal(jsonPayload, &text) == nil {
+		return []byte(text), nil
+	}
+	if !json.Valid(jsonPayload) {
+		return nil, errors.New("payload is not valid json")
+	}
+	return jsonPayload, nil
+}
+
+// Cbor translates between CBOR (RFC 8949) and json; byte strings are base64 encoded json strings
+type Cbor struct{}
+
+var cborDecMode, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+var cborEncMode, _ = cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+
+func (this Cbor) Decode(payload []byte) ([]byte, error) {
+	var value interface{}
+	err := cborDecMode.Unmarshal(payload, &value)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not valid cbor: %w", err)
+	}
+	return json.Marshal(value)
+}
+
+func (this Cbor) Encode(jsonPayload []byte) ([]byte, error) {
+	value, err := unmarshalJson(jsonPayload)
+	if err != nil {
+		return nil, err
+	}
+	return cborEncMode.Marshal(value)
+}
+
+// Msgpack translates between MessagePack and json
+type Msgpack struct{}
+
+func (this Msgpack) Decode(payload []byte) ([]byte, error) {
+	var value interface{}
+	err := msgpack.Unmarshal(payload, &value)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not valid msgpack: %w", err)
+	}
+	return json.Marshal(value)
+}
+
+func (this Msgpack) Encode(jsonPayload []byte) ([]byte, error) {
+	value, err := unmarshalJson(jsonPayload)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(value)
+}
+
+// Xml translates between xml and json objects; attributes are prefixed with '-', numbers and bools are cast
+// (e.g. <data unit="C"><temp>21.5</temp></data> <-> {"data":{"-unit":"C","temp":21.5}})
+type Xml struct{}
+
+func (this Xml) Decode(payload []byte) ([]byte, error) {
+	value, err := mxj.NewMapXml(payload, true)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not valid xml: %w", err)
+	}
+	return value.Json()
+}
+
+func (this Xml) Encode(jsonPayload []byte) ([]byte, error) {
+	value, err := mxj.NewMapJson(jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not a valid json object: %w", err)
+	}
+	return value.Xml()
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// FormatProtobuf needs a descriptor set and message name; use GetProtobuf instead of Get
+const FormatProtobuf = "protobuf"
+
+// Protobuf translates between protobuf messages and json using the protojson mapping with the field names of the .proto file.
+// fields with default values are included in decoded json; 64 bit integers are decoded as json numbers instead of the protojson strings
+type Protobuf struct {
+	Message protoreflect.MessageDescriptor
+}
+
+func (this Protobuf) Decode(payload []byte) ([]byte, error) {
+	message := dynamicpb.NewMessage(this.Message)
+	err := proto.Unmarshal(payload, message)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not a valid %v protobuf message: %w", this.Message.FullName(), err)
+	}
+	result, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	//protojson output is intentionally unstable in whitespace
+	buf := bytes.Buffer{}
+	err = json.Compact(&buf, result)
+	if err != nil {
+		return nil, err
+	}
+	return int64sToNumbers(buf.Bytes(), this.Message)
+}
+
+// int64sToNumbers replaces the json strings protojson uses for 64 bit integers with json numbers.
+// the field order of protojson is kept; well known types keep their special json mapping
+func int64sToNumbers(raw json.RawMessage, desc protoreflect.MessageDescriptor) (json.RawMessage, error) {
+	if desc.ParentFile().Package() == "google.protobuf" || bytes.Equal(raw, []byte("null")) {
+		return raw, nil
+	}
+	obj := map[string]json.RawMessage{}
+	err := json.Unmarshal(raw, &obj)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	buf.WriteString("{")
+	write := func(key string, value json.RawMessage) {
+		if buf.Len() > 1 {
+			buf.WriteString(",")
+		}
+		keyJson, _ := json.Marshal(key)
+		buf.Write(keyJson)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		key := string(field.Name())
+		value, ok := obj[key]
+		if !ok {
+			continue
+		}
+		delete(obj, key)
+		value, err = fieldInt64sToNumbers(value, field)
+		if err != nil {
+			return nil, err
+		}
+		write(key, value)
+	}
+	//extensions
+	for _, key := range slices.Sorted(maps.Keys(obj)) {
+		write(key, obj[key])
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+func fieldInt64sToNumbers(raw json.RawMessage, field protoreflect.FieldDescriptor) (json.RawMessage, error) {
+	switch {
+	case field.IsMap():
+		values := map[string]json.RawMessage{}
+		err := json.Unmarshal(raw, &values)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			values[key], err = valueInt64sToNumbers(value, field.MapValue())
+			if err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(values)
+	case field.IsList():
+		values := []json.RawMessage{}
+		err := json.Unmarshal(raw, &values)
+		if err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			values[i], err = valueInt64sToNumbers(value, field)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(values)
+	default:
+		return valueInt64sToNumbers(raw, field)
+	}
+}
+
+func valueInt64sToNumbers(raw json.RawMessage, field protoreflect.FieldDescriptor) (json.RawMessage, error) {
+	switch field.Kind() {
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var value string
+		if json.Unmarshal(raw, &value) == nil {
+			return json.RawMessage(value), nil
+		}
+		return raw, nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return int64sToNumbers(raw, field.Message())
+	default:
+		return raw, nil
+	}
+}
+
+func (this Protobuf) Encode(jsonPayload []byte) ([]byte, error) {
+	message := dynamicpb.NewMessage(this.Message)
+	err := protojson.Unmarshal(jsonPayload, message)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not a valid %v json message: %w", this.Message.FullName(), err)
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(message)
+}
+
+var descriptorSets = map[string]*protoregistry.Files{}
+var descriptorSetsMux sync.RWMutex
+
+// LoadProtobufDescriptorSet reads a compiled FileDescriptorSet (e.g. protoc --include_imports --descriptor_set_out=sensors.binpb sensors.proto)
+// and replaces the cached version used by GetProtobuf
+func LoadProtobufDescriptorSet(location string) error {
+	content, err := os.ReadFile(location)
+	if err != nil {
+		return err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	err = proto.Unmarshal(content, set)
+	if err != nil {
+		return fmt.Errorf("invalid protobuf descriptor set %v: %w", location, err)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return fmt.Errorf("invalid protobuf descriptor set %v: %w", location, err)
+	}
+	descriptorSetsMux.Lock()
+	defer descriptorSetsMux.Unlock()
+	descriptorSets[filepath.Clean(location)] = files
+	return nil
+}
+
+// GetProtobuf returns the codec of a message (full name, e.g. factory.v1.Reading) in a descriptor set file;
+// descriptor sets are loaded on first use if they have not been loaded by LoadProtobufDescriptorSet
+func GetProtobuf(descriptorSet string, message string) (Codec, error) {
+	if descriptorSet == "" || message == "" {
+		return nil, fmt.Errorf("payload format %v needs a descriptor set and a message name", FormatProtobuf)
+	}
+	location := filepath.Clean(descriptorSet)
+	descriptorSetsMux.RLock()
+	files, ok := descriptorSets[location]
+	descriptorSetsMux.RUnlock()
+	if !ok {
+		err := LoadProtobufDescriptorSet(location)
+		if err != nil {
+			return nil, err
+		}
+		descriptorSetsMux.RLock()
+		files = descriptorSets[location]
+		descriptorSetsMux.RUnlock()
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(message))
+	if err != nil {
+		return nil, fmt.Errorf("unknown protobuf message %v in %v: %w", message, descriptorSet, err)
+	}
+	messageDesc, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%v in %v is not a protobuf message", message, descriptorSet)
+	}
+	return Protobuf{Message: messageDesc}, nil
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"encoding/hex"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProtobuf(t *testing.T) {
+	reading, err := GetProtobuf("testdata/sensors.binpb", "factory.v1.Reading")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//sensor_id: "s1", temperature: 21.5, count: 3, status: STATUS_OK, values: [1.5]
+	payload, _ := hex.DecodeString("0a0273311100000000008035401803280132040000c03f")
+	result, err := reading.Decode(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"sensor_id":"s1","temperature":21.5,"count":3,"on":false,"status":"STATUS_OK","values":[1.5]}`
+	if string(result) != expected {
+		t.Error(string(result))
+	}
+	encoded, err := reading.Encode(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(encoded) != hex.EncodeToString(payload) {
+		t.Error(hex.EncodeToString(encoded))
+	}
+
+	command, err := GetProtobuf("testdata/sensors.binpb", "factory.v1.Command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err = command.Encode([]byte(`{"level":5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(encoded) != "0805" {
+		t.Error(hex.EncodeToString(encoded))
+	}
+	_, err = command.Encode([]byte(`{"unknown":5}`))
+	if err == nil {
+		t.Error("expected error for unknown field")
+	}
+	_, err = command.Decode([]byte{0xff, 0xff})
+	if err == nil {
+		t.Error("expected error for invalid message")
+	}
+}
+
+func TestProtobufInt64(t *testing.T) {
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		result := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     kind.Enum(),
+			Label:    label.Enum(),
+		}
+		if typeName != "" {
+			result.TypeName = proto.String(typeName)
+		}
+		return result
+	}
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("counter.proto"),
+		Package: proto.String("factory.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Counter"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("total", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional, ""),
+				field("name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				field("deltas", 3, descriptorpb.FieldDescriptorProto_TYPE_SINT64, repeated, ""),
+				field("sub", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, optional, ".factory.v1.Counter.Sub"),
+				field("limits", 5, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".factory.v1.Counter.LimitsEntry"),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{
+				{
+					Name:  proto.String("Sub"),
+					Field: []*descriptorpb.FieldDescriptorProto{field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64, optional, "")},
+				},
+				{
+					Name: proto.String("LimitsEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+						field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_FIXED64, optional, ""),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				},
+			},
+		}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := Protobuf{Message: file.Messages().ByName("Counter")}
+	input := `{"total":9007199254740993,"name":"c","deltas":[-1,2],"sub":{"id":18446744073709551615},"limits":{"a":5}}`
+	encoded, err := counter.Encode([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := counter.Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != input {
+		t.Error(string(result))
+	}
+	result, err = counter.Decode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != `{"total":0,"name":"","deltas":[],"sub":null,"limits":{}}` {
+		t.Error(string(result))
+	}
+}
+
+func TestGetProtobufErrors(t *testing.T) {
+	_, err := GetProtobuf("testdata/sensors.binpb", "factory.v1.Unknown")
+	if err == nil {
+		t.Error("expected error for unknown message")
+	}
+	_, err = GetProtobuf("testdata/sensors.binpb", "factory.v1.Reading.Status")
+	if err == nil {
+		t.Error("expected error for enum")
+	}
+	_, err = GetProtobuf("testdata/missing.binpb", "factory.v1.Reading")
+	if err == nil {
+		t.Error("expected error for missing descriptor set")
+	}
+	_, err = GetProtobuf("", "factory.v1.Reading")
+	if err == nil {
+		t.Error("expected error for missing descriptor set")
+	}
+	invalid := filepath.Join(t.TempDir(), "invalid.binpb")
+	err = os.WriteFile(invalid, []byte("not a descriptor set"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = GetProtobuf(invalid, "factory.v1.Reading")
+	if err == nil {
+		t.Error("expected error for invalid descriptor set")
+	}
+}
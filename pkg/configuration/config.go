@@ -43,9 +43,26 @@ type Config struct {
 	MqttCmdClientId        string `json:"mqtt_cmd_client_id"`
 	MqttBroker             string `json:"mqtt_broker"`
 	MqttInsecureSkipVerify bool   `json:"mqtt_insecure_skip_verify"`
+	MqttProtocolVersion    string `json:"mqtt_protocol_version"`
 	DeleteDevices          bool   `json:"delete_devices"`
 	MaxCorrelationIdAge    string `json:"max_correlation_id_age"`
 
+	UpdateRetries          int    `json:"update_retries"`
+	UpdateRetryBackoff     string `json:"update_retry_backoff"`
+	UpdateRetryMaxDuration string `json:"update_retry_max_duration"`
+	ValidationMode         string `json:"validation_mode"`
+
+	RegisteredDevicesFile string `json:"registered_devices_file"`
+	RemovalGracePeriod    string `json:"removal_grace_period"`
+
+	RemovalGuardMaxCount   int     `json:"removal_guard_max_count"`
+	RemovalGuardMaxPercent float64 `json:"removal_guard_max_percent"`
+	RemovalGuardCycles     int     `json:"removal_guard_cycles"`
+
+	EventBufferFile    string `json:"event_buffer_file"`
+	EventBufferMaxSize int64  `json:"event_buffer_max_size"`
+	EventBufferMaxAge  string `json:"event_buffer_max_age"`
+
 	GeneratorUse bool `json:"generator_use"`
 
 	GeneratorMgwCertManagerUrl string `json:"generator_mgw_cert_manager_url"` //alternative to GeneratorAuthEndpoint
@@ -67,6 +84,17 @@ type Config struct {
 	DeviceRepoCacheDuration            string `json:"device_repo_cache_duration"`
 	OnlineCheckFunctionId              string `json:"online_check_function_id"`
 	OnlineCheckBooleanCharacteristicId string `json:"online_check_boolean_characteristic_id"`
+	LivenessTimeout                    string `json:"liveness_timeout"`
+
+	DeviceDescriptionsWatchDebounce string `json:"device_descriptions_watch_debounce"`
+
+	HomieDiscovery   bool              `json:"homie_discovery"`
+	HomieBaseTopic   string            `json:"homie_base_topic"`
+	HomieDeviceTypes map[string]string `json:"homie_device_types"` //homie node type -> device type id
+
+	ApiPort  string `json:"api_port"`
+	ApiHost  string `json:"api_host"`
+	ApiToken string `json:"api_token"`
 
 	ProtocolDescription   models.Protocol `json:"protocol_description"`
 	ProtocolDataFieldName string          `json:"protocol_data_field_name"`
@@ -143,10 +171,9 @@ func handleEnvironmentVars(config *Config) {
 			if configValue.FieldByName(fieldName).Kind() == reflect.Map {
 				value := map[string]string{}
 				for _, element := range strings.Split(envValue, ",") {
-					keyVal := strings.Split(element, ":")
-					key := strings.TrimSpace(keyVal[0])
-					val := strings.TrimSpace(keyVal[1])
-					value[key] = val
+					//values may contain ':' (e.g. device type ids)
+					key, val, _ := strings.Cut(element, ":")
+					value[strings.TrimSpace(key)] = strings.TrimSpace(val)
 				}
 				configValue.FieldByName(fieldName).Set(reflect.ValueOf(value))
 			}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"slices"
+	"time"
+)
+
+// TopicDescriptionInfo is the serializable representation of a TopicDescription used by the admin api
+type TopicDescriptionInfo struct {
+	EventTopic    string `json:"event_topic,omitempty"`
+	CmdTopic      string `json:"cmd_topic,omitempty"`
+	ResponseTopic string `json:"resp_topic,omitempty"`
+	StatusTopic   string `json:"status_topic,omitempty"`
+	DeviceTypeId  string `json:"device_type_id,omitempty"`
+	DeviceId      string `json:"device_local_id,omitempty"`
+	ServiceId     string `json:"service_local_id,omitempty"`
+	DeviceName    string `json:"device_name,omitempty"`
+}
+
+type RegistryInfo struct {
+	EventTopics    map[string]TopicDescriptionInfo `json:"event_topics"`
+	ResponseTopics map[string]TopicDescriptionInfo `json:"response_topics"`
+	Commands       map[string]TopicDescriptionInfo `json:"commands"`
+	StatusTopics   map[string]TopicDescriptionInfo `json:"status_topics"`
+	Devices        map[string]TopicDescriptionInfo `json:"devices"`
+	LearnedDevices map[string]TopicDescriptionInfo `json:"learned_devices"`
+}
+
+type CorrelationInfo struct {
+	CommandId string    `json:"command_id"`
+	Created   time.Time `json:"created"`
+}
+
+// DeviceStateInfo contains the resulting state of a device and the states known by the individual checks
+type DeviceStateInfo struct {
+	State       mgw.State `json:"state"`
+	OnlineCheck mgw.State `json:"online_check,omitempty"`
+	Status      mgw.State `json:"status,omitempty"`
+	Liveness    mgw.State `json:"liveness,omitempty"`
+}
+
+type UpdateResult struct {
+	Time        time.Time `json:"time"`
+	Duration    string    `json:"duration"`
+	Error       string    `json:"error,omitempty"`
+	Devices     int       `json:"devices"`
+	Plan        []string  `json:"plan,omitempty"`
+	Quarantined []string  `json:"quarantined,omitempty"`
+}
+
+func NewTopicDescriptionInfo(desc TopicDescription) TopicDescriptionInfo {
+	return TopicDescriptionInfo{
+		EventTopic:    desc.GetEventTopic(),
+		CmdTopic:      desc.GetCmdTopic(),
+		ResponseTopic: desc.GetResponseTopic(),
+		StatusTopic:   desc.GetStatusTopic(),
+		DeviceTypeId:  desc.GetDeviceTypeId(),
+		DeviceId:      desc.GetLocalDeviceId(),
+		ServiceId:     desc.GetLocalServiceId(),
+		DeviceName:    desc.GetDeviceName(),
+	}
+}
+
+func toTopicDescriptionInfos(descriptions map[string]TopicDescription) (result map[string]TopicDescriptionInfo) {
+	result = map[string]TopicDescriptionInfo{}
+	for key, desc := range descriptions {
+		result[key] = NewTopicDescriptionInfo(desc)
+	}
+	return result
+}
+
+func (this *Connector) GetRegistries() RegistryInfo {
+	return RegistryInfo{
+		EventTopics:    toTopicDescriptionInfos(this.eventTopicRegister.GetAll()),
+		ResponseTopics: toTopicDescriptionInfos(this.responseTopicRegister.GetAll()),
+		Commands:       toTopicDescriptionInfos(this.commandTopicRegister.GetAll()),
+		StatusTopics:   toTopicDescriptionInfos(this.statusTopicRegister.GetAll()),
+		Devices:        toTopicDescriptionInfos(this.devices.GetAll()),
+		LearnedDevices: toTopicDescriptionInfos(this.learnedDevices.GetAll()),
+	}
+}
+
+// GetCorrelations returns the pending command correlations by correlation key
+func (this *Connector) GetCorrelations() (result map[string][]CorrelationInfo) {
+	result = map[string][]CorrelationInfo{}
+	this.correlationStore.Do(func(m *map[string][]CorrelationId) {
+		for key, list := range *m {
+			for _, element := range list {
+				result[key] = append(result[key], CorrelationInfo{CommandId: element.id, Created: element.date})
+			}
+		}
+	})
+	return result
+}
+
+func (this *Connector) GetDeviceStates() (result map[string]DeviceStateInfo) {
+	result = map[string]DeviceStateInfo{}
+	for deviceId, desc := range this.devices.GetAll() {
+		info := DeviceStateInfo{State: this.getDeviceState(desc)}
+		info.OnlineCheck, _ = this.onlineCheck.LoadState(desc)
+		info.Status, _ = this.statusStates.Get(deviceId)
+		info.Liveness, _ = this.liveness.LoadState(deviceId)
+		result[deviceId] = info
+	}
+	return result
+}
+
+// GetLastUpdate returns the result of the last device registry update; ok is false if no update has been finished
+func (this *Connector) GetLastUpdate() (result UpdateResult, ok bool) {
+	last := this.lastUpdate.Load()
+	if last == nil {
+		return result, false
+	}
+	return *last, true
+}
+
+func (this *Connector) storeUpdateResult(start time.Time, plan UpdatePlan, err error) {
+	result := UpdateResult{
+		Time:     start,
+		Duration: time.Since(start).String(),
+		Devices:  len(this.devices.GetKeys()),
+		Plan:     plan.Strings(),
+	}
+	for message := range this.quarantined {
+		result.Quarantined = append(result.Quarantined, message)
+	}
+	slices.Sort(result.Quarantined)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	this.lastUpdate.Store(&result)
+}
@@ -0,0 +1,312 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	BinaryTypeInt    = "int"
+	BinaryTypeUint   = "uint"
+	BinaryTypeFloat  = "float"
+	BinaryTypeBool   = "bool"
+	BinaryTypeString = "string"
+)
+
+const (
+	BinaryEndianBig    = "big"
+	BinaryEndianLittle = "little"
+)
+
+// BinaryField is the details of a binary-layout transformation
+type BinaryField struct {
+	Offset int     `json:"offset"`           //byte offset in the frame
+	Length int     `json:"length,omitempty"` //default: 1 for int, uint and bool, 4 for float, the remaining frame (or the command value) for string
+	Type   string  `json:"type"`             //int (signed), uint, float, bool or string
+	Endian string  `json:"endian,omitempty"` //big (default) or little
+	Scale  float64 `json:"scale,omitempty"`  //numbers are multiplied with scale in events and responses and divided in commands
+}
+
+type binaryLayoutField struct {
+	BinaryField
+	path string
+}
+
+// handleBinaryLayoutOutputTransformations decodes a packed binary frame of the device into a json document.
+// fields contains the json encoded BinaryField of each dot separated target path ("" if the frame contains a single value)
+func (this *Connector) handleBinaryLayoutOutputTransformations(fields map[string]string, payload []byte) ([]byte, error) {
+	if len(fields) == 0 {
+		return payload, nil
+	}
+	layout, err := parseBinaryLayout(fields)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	for _, field := range layout {
+		value, err := field.decode(payload)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", field.path, err)
+		}
+		if field.path == "" {
+			result = value
+			continue
+		}
+		result, err = setJsonPath(result, field.path, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(result)
+}
+
+// handleBinaryLayoutInputTransformations encodes a json command into a packed binary frame covering all fields of the layout;
+// bytes without a field or of fields missing in the command are zero
+func (this *Connector) handleBinaryLayoutInputTransformations(fields map[string]string, payload []byte) ([]byte, error) {
+	if len(fields) == 0 {
+		return payload, nil
+	}
+	layout, err := parseBinaryLayout(fields)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	err = json.Unmarshal(payload, &value)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not valid json: %w", err)
+	}
+	values := map[string]interface{}{}
+	size := 0
+	for i, field := range layout {
+		fieldValue, ok := value, true
+		if field.path != "" {
+			fieldValue, ok = getJsonPath(value, field.path)
+		}
+		if ok {
+			values[field.path] = fieldValue
+		}
+		if ok && field.Type == BinaryTypeString && field.Length == 0 {
+			text, isString := fieldValue.(string)
+			if !isString {
+				return nil, fmt.Errorf("%v: %v is not a string", field.path, fieldValue)
+			}
+			layout[i].Length = len(text)
+		}
+		size = max(size, field.Offset+layout[i].Length)
+	}
+	frame := make([]byte, size)
+	for _, field := range layout {
+		fieldValue, ok := values[field.path]
+		if !ok {
+			continue
+		}
+		err = field.encode(frame, fieldValue)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", field.path, err)
+		}
+	}
+	return frame, nil
+}
+
+func parseBinaryLayout(fields map[string]string) (result []binaryLayoutField, err error) {
+	for path, details := range fields {
+		field := binaryLayoutField{path: path}
+		err = json.Unmarshal([]byte(details), &field.BinaryField)
+		if err != nil {
+			return nil, fmt.Errorf("invalid binary-layout for %v: %w", path, err)
+		}
+		if field.Length == 0 {
+			switch field.Type {
+			case BinaryTypeInt, BinaryTypeUint, BinaryTypeBool:
+				field.Length = 1
+			case BinaryTypeFloat:
+				field.Length = 4
+			}
+		}
+		err = field.check()
+		if err != nil {
+			return nil, fmt.Errorf("invalid binary-layout for %v: %w", path, err)
+		}
+		result = append(result, field)
+	}
+	slices.SortFunc(result, func(a, b binaryLayoutField) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return result, nil
+}
+
+func (this BinaryField) check() error {
+	if this.Offset < 0 || this.Length < 0 {
+		return fmt.Errorf("negative offset or length")
+	}
+	switch this.Type {
+	case BinaryTypeInt, BinaryTypeUint, BinaryTypeBool:
+		if this.Length > 8 {
+			return fmt.Errorf("%v length %v is greater than 8", this.Type, this.Length)
+		}
+	case BinaryTypeFloat:
+		if this.Length != 4 && this.Length != 8 {
+			return fmt.Errorf("float length %v is not 4 or 8", this.Length)
+		}
+	case BinaryTypeString:
+	default:
+		return fmt.Errorf("unknown type %v", this.Type)
+	}
+	switch this.Endian {
+	case BinaryEndianBig, BinaryEndianLittle, "":
+	default:
+		return fmt.Errorf("unknown endian %v", this.Endian)
+	}
+	return nil
+}
+
+func (this BinaryField) decode(frame []byte) (interface{}, error) {
+	length := this.Length
+	if this.Type == BinaryTypeString && length == 0 {
+		length = max(len(frame)-this.Offset, 0)
+	}
+	if this.Offset+length > len(frame) {
+		return nil, fmt.Errorf("frame of %v bytes is too short for offset %v and length %v", len(frame), this.Offset, length)
+	}
+	data := frame[this.Offset : this.Offset+length]
+	if this.Type == BinaryTypeString {
+		return string(bytes.TrimRight(data, "\x00")), nil
+	}
+	raw := this.readUint(data)
+	switch this.Type {
+	case BinaryTypeBool:
+		return raw != 0, nil
+	case BinaryTypeFloat:
+		var value float64
+		if length == 4 {
+			value = float64(math.Float32frombits(uint32(raw)))
+		} else {
+			value = math.Float64frombits(raw)
+		}
+		if this.Scale != 0 {
+			value = value * this.Scale
+		}
+		return value, nil
+	case BinaryTypeInt:
+		value := int64(raw)
+		if length < 8 && raw&(1<<(8*length-1)) != 0 {
+			value = int64(raw) - int64(1)<<(8*length)
+		}
+		if this.Scale != 0 {
+			return this.applyScale(float64(value)), nil
+		}
+		return value, nil
+	default:
+		if this.Scale != 0 {
+			return this.applyScale(float64(raw)), nil
+		}
+		return raw, nil
+	}
+}
+
+// applyScale multiplies an integer with the scale and rounds to the decimals of the scale (e.g. 3 * 0.1 = 0.3 instead of 0.30000000000000004)
+func (this BinaryField) applyScale(value float64) float64 {
+	result := value * this.Scale
+	_, fraction, found := strings.Cut(strconv.FormatFloat(this.Scale, 'f', -1, 64), ".")
+	if !found {
+		return result
+	}
+	factor := math.Pow(10, float64(len(fraction)))
+	return math.Round(result*factor) / factor
+}
+
+func (this BinaryField) encode(frame []byte, value interface{}) error {
+	data := frame[this.Offset : this.Offset+this.Length]
+	switch this.Type {
+	case BinaryTypeString:
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%v is not a string", value)
+		}
+		if len(text) > this.Length {
+			return fmt.Errorf("%v is longer than %v bytes", text, this.Length)
+		}
+		copy(data, text)
+		return nil
+	case BinaryTypeBool:
+		flag, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("%v is not a bool", value)
+		}
+		if flag {
+			this.writeUint(data, 1)
+		}
+		return nil
+	}
+	number, ok := value.(float64)
+	if !ok {
+		return fmt.Errorf("%v is not a number", value)
+	}
+	if this.Scale != 0 {
+		number = number / this.Scale
+	}
+	switch this.Type {
+	case BinaryTypeFloat:
+		if this.Length == 4 {
+			this.writeUint(data, uint64(math.Float32bits(float32(number))))
+		} else {
+			this.writeUint(data, math.Float64bits(number))
+		}
+	case BinaryTypeInt:
+		number = math.Round(number)
+		limit := math.Pow(2, float64(8*this.Length-1))
+		if number < -limit || number >= limit {
+			return fmt.Errorf("%v is out of range for %v bytes", number, this.Length)
+		}
+		this.writeUint(data, uint64(int64(number)))
+	default:
+		number = math.Round(number)
+		if number < 0 || number >= math.Pow(2, float64(8*this.Length)) {
+			return fmt.Errorf("%v is out of range for %v bytes", number, this.Length)
+		}
+		this.writeUint(data, uint64(number))
+	}
+	return nil
+}
+
+func (this BinaryField) readUint(data []byte) (result uint64) {
+	for i := range data {
+		b := data[i]
+		if this.Endian == BinaryEndianLittle {
+			b = data[len(data)-1-i]
+		}
+		result = result<<8 | uint64(b)
+	}
+	return result
+}
+
+func (this BinaryField) writeUint(data []byte, value uint64) {
+	for i := range data {
+		index := len(data) - 1 - i
+		if this.Endian == BinaryEndianLittle {
+			index = i
+		}
+		data[index] = byte(value >> (8 * i))
+	}
+}
@@ -17,18 +17,30 @@
 package connector
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/metrics"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt5"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/util"
+	"github.com/google/uuid"
 	"log"
+	"strings"
 	"time"
 )
 
+const CorrelationTokenCommandId = "command_id"
+const CorrelationTokenGenerated = "generated"
+
 func (this *Connector) CommandHandler(deviceId string, serviceId string, command mgw.Command) {
+	this.metrics.CommandsReceived.Inc()
 	go func() {
 		cmdId := getCommandId(deviceId, serviceId)
 		desc, ok := this.commandTopicRegister.Get(cmdId)
 		if !ok {
 			log.Println("WARNING: got command for unknown device description", cmdId)
+			this.metrics.CommandsFailed.WithLabelValues(metrics.CommandFailureUnknownDevice).Inc()
 			return
 		}
 
@@ -36,24 +48,68 @@ func (this *Connector) CommandHandler(deviceId string, serviceId string, command
 
 		if desc.HasTransformations() {
 			var err error
-			payload, err = this.handleTransformations(desc, TransformerJsonUnwrapInput, payload)
+			payload, err = this.handleInputTransformations(desc, payload)
 			if err != nil {
 				log.Println("ERROR: transform command", deviceId, serviceId, err)
+				this.metrics.CommandsFailed.WithLabelValues(metrics.CommandFailureTransform).Inc()
 				this.mgwClient.SendDeviceError(desc.GetLocalDeviceId(), "unable to transform command: "+err.Error())
 				return
 			}
 		}
 
 		expectsDeviceResponse := desc.GetResponseTopic() != ""
+		correlationKey := cmdId
+		if desc.GetCorrelationPath() != "" {
+			token := getCorrelationToken(desc, command.CommandId)
+			var err error
+			payload, err = setCorrelationToken(desc.GetCorrelationPath(), token, payload)
+			if err != nil {
+				log.Println("ERROR: unable to set correlation token in command", deviceId, serviceId, err)
+				this.metrics.CommandsFailed.WithLabelValues(metrics.CommandFailureCorrelation).Inc()
+				this.mgwClient.SendCommandError(command.CommandId, "unable to set correlation token in command: "+err.Error())
+				return
+			}
+			correlationKey = getCorrelationKey(cmdId, token)
+		}
+
+		if desc.GetPayloadFormat() != "" {
+			var err error
+			payload, err = this.encodePayload(desc, payload)
+			if err != nil {
+				log.Println("ERROR: encode command", deviceId, serviceId, err)
+				this.metrics.CommandsFailed.WithLabelValues(metrics.CommandFailureEncode).Inc()
+				this.mgwClient.SendDeviceError(desc.GetLocalDeviceId(), "unable to encode command: "+err.Error())
+				return
+			}
+		}
+
+		publish := this.commandMqttClient.Publish
+		if v5Client, ok := this.commandMqttClient.(MqttV5Client); ok {
+			properties := this.getCommandProperties(desc)
+			if expectsDeviceResponse {
+				properties.ResponseTopic = desc.GetResponseTopic()
+				if desc.GetCorrelationPath() == "" {
+					properties.CorrelationData = []byte(command.CommandId)
+					correlationKey = getCorrelationKey(cmdId, command.CommandId)
+				}
+			}
+			publish = func(topic string, qos byte, retained bool, payload []byte) error {
+				return v5Client.PublishWithProperties(topic, qos, retained, payload, properties)
+			}
+		}
+
 		if expectsDeviceResponse {
-			this.storeCorrelationId(cmdId, command.CommandId)
+			this.storeCorrelationId(correlationKey, command.CommandId, this.getResponseTimeout(desc))
 		}
 
-		err := this.commandMqttClient.Publish(desc.GetCmdTopic(), 2, false, payload)
+		err := publish(desc.GetCmdTopic(), 2, false, payload)
 		if err != nil {
 			log.Println("ERROR: unable to send command to mqtt", err)
+			this.metrics.CommandsFailed.WithLabelValues(metrics.CommandFailurePublish).Inc()
 			this.mgwClient.SendCommandError(command.CommandId, "unable to send command to mqtt: "+err.Error())
-			this.removeCorrelationId(cmdId, command.CommandId)
+			this.removeCorrelationId(correlationKey, command.CommandId)
+		} else {
+			this.metrics.CommandsPublished.Inc()
 		}
 
 		if !expectsDeviceResponse {
@@ -63,6 +119,7 @@ func (this *Connector) CommandHandler(deviceId string, serviceId string, command
 			})
 			if err != nil {
 				log.Println("ERROR: unable to send empty response", err)
+				this.metrics.CommandsFailed.WithLabelValues(metrics.CommandFailureEmptyResponse).Inc()
 				this.mgwClient.SendCommandError(command.CommandId, "unable to send empty response: "+err.Error())
 			}
 		}
@@ -70,40 +127,127 @@ func (this *Connector) CommandHandler(deviceId string, serviceId string, command
 }
 
 type CorrelationId struct {
-	id   string
-	date time.Time
+	id    string
+	date  time.Time
+	timer *time.Timer
+}
+
+// getCommandProperties returns the mqtt 5 properties defined by the topic description
+func (this *Connector) getCommandProperties(desc TopicDescription) (result mqtt5.Properties) {
+	result.UserProperties = desc.GetUserProperties()
+	if expiry := desc.GetMessageExpiry(); expiry != "" && expiry != "-" {
+		var err error
+		result.MessageExpiry, err = time.ParseDuration(expiry)
+		if err != nil {
+			log.Println("WARNING: unable to parse message expiry", expiry, err)
+		}
+	}
+	return result
+}
+
+func (this *Connector) getResponseTimeout(desc TopicDescription) time.Duration {
+	timeout := desc.GetResponseTimeout()
+	if timeout == "" || timeout == "-" {
+		return this.MaxCorrelationIdAge
+	}
+	result, err := time.ParseDuration(timeout)
+	if err != nil {
+		log.Println("WARNING: unable to parse response timeout; use max_correlation_id_age", timeout, err)
+		return this.MaxCorrelationIdAge
+	}
+	if result <= 0 {
+		log.Println("WARNING: response timeout is not positive; use max_correlation_id_age", timeout)
+		return this.MaxCorrelationIdAge
+	}
+	return result
 }
 
-func (this *Connector) removeOldCorrelationIds(key string) {
+// storeCorrelationId stores the correlation id until the response is received or the timeout is reached.
+// on timeout a command error is sent to the mgw.
+func (this *Connector) storeCorrelationId(key string, correlationId string, timeout time.Duration) {
 	this.correlationStore.Update(key, func(l []CorrelationId) []CorrelationId {
-		return util.ListFilter(l, func(value CorrelationId) bool {
-			toOld := time.Since(value.date) > this.MaxCorrelationIdAge
-			if toOld {
-				log.Println("WARNING: drop correlation id because its older than MaxCorrelationIdAge", value.id, value.date.String())
-			}
-			return !toOld
+		return append(l, CorrelationId{
+			id:   correlationId,
+			date: time.Now(),
+			timer: time.AfterFunc(timeout, func() {
+				this.handleCorrelationIdTimeout(key, correlationId)
+			}),
 		})
 	})
 }
 
-func (this *Connector) storeCorrelationId(key string, correlationId string) {
-	this.removeOldCorrelationIds(key)
-	this.correlationStore.Update(key, func(l []CorrelationId) []CorrelationId {
-		return append(l, CorrelationId{id: correlationId, date: time.Now()})
+func (this *Connector) handleCorrelationIdTimeout(key string, correlationId string) {
+	expired := false
+	this.correlationStore.Do(func(m *map[string][]CorrelationId) {
+		l := util.ListFilter((*m)[key], func(value CorrelationId) bool {
+			if value.id == correlationId {
+				expired = true
+				return false
+			}
+			return true
+		})
+		if len(l) == 0 {
+			delete(*m, key)
+		} else {
+			(*m)[key] = l
+		}
 	})
+	if !expired {
+		//response has been received in the meantime
+		return
+	}
+	this.metrics.CorrelationIdsExpired.Inc()
+	log.Println("WARNING: device response timeout", key, correlationId)
+	this.mgwClient.SendCommandError(correlationId, "device response timeout")
 }
 
 func (this *Connector) removeCorrelationId(key string, correlationId string) {
-	this.removeOldCorrelationIds(key)
-	this.correlationStore.Update(key, func(l []CorrelationId) []CorrelationId {
-		return util.ListFilter(l, func(value CorrelationId) bool {
-			return value.id != correlationId
+	this.correlationStore.Do(func(m *map[string][]CorrelationId) {
+		l := util.ListFilter((*m)[key], func(value CorrelationId) bool {
+			if value.id == correlationId {
+				value.timer.Stop()
+				return false
+			}
+			return true
 		})
+		if len(l) == 0 {
+			delete(*m, key)
+		} else {
+			(*m)[key] = l
+		}
 	})
 }
 
+// popOldestCorrelationId is used if a response contains no correlation token;
+// it pops the oldest correlation id stored for the device service, independent of the correlation token
+func (this *Connector) popOldestCorrelationId(cmdId string) (correlationId string, exists bool) {
+	this.correlationStore.Do(func(m *map[string][]CorrelationId) {
+		oldestKey := ""
+		var oldest CorrelationId
+		for key, l := range *m {
+			if len(l) == 0 || (key != cmdId && !strings.HasPrefix(key, getCorrelationKey(cmdId, ""))) {
+				continue
+			}
+			if !exists || l[0].date.Before(oldest.date) {
+				exists = true
+				oldest = l[0]
+				oldestKey = key
+			}
+		}
+		if !exists {
+			return
+		}
+		correlationId = oldest.id
+		oldest.timer.Stop()
+		(*m)[oldestKey] = (*m)[oldestKey][1:]
+		if len((*m)[oldestKey]) == 0 {
+			delete(*m, oldestKey)
+		}
+	})
+	return
+}
+
 func (this *Connector) popCorrelationId(key string) (correlationId string, exists bool) {
-	this.removeOldCorrelationIds(key)
 	this.correlationStore.Do(func(m *map[string][]CorrelationId) {
 		l, ok := (*m)[key]
 		if !ok {
@@ -113,11 +257,65 @@ func (this *Connector) popCorrelationId(key string) (correlationId string, exist
 		if len(l) > 0 {
 			exists = true
 			correlationId = l[0].id
+			l[0].timer.Stop()
 			l = l[1:]
-			(*m)[key] = l
 		} else {
 			exists = false
 		}
+		//keys are unique per command if correlated by token; empty lists would remain forever
+		if len(l) == 0 {
+			delete(*m, key)
+		} else {
+			(*m)[key] = l
+		}
 	})
 	return
 }
+
+// getCorrelationToken returns the token injected into the command payload if the description uses a correlation path
+func getCorrelationToken(desc TopicDescription, commandId string) string {
+	if desc.GetCorrelationToken() == CorrelationTokenGenerated {
+		return uuid.NewString()
+	}
+	return commandId
+}
+
+// getCorrelationKey is used as correlationStore key for commands correlated by a token in the payload
+func getCorrelationKey(cmdId string, token string) string {
+	return cmdId + "#" + token
+}
+
+func setCorrelationToken(path string, token string, payload []byte) ([]byte, error) {
+	var value interface{}
+	if len(payload) > 0 {
+		err := json.Unmarshal(payload, &value)
+		if err != nil {
+			return nil, fmt.Errorf("payload is not valid json: %w", err)
+		}
+	}
+	value, err := setJsonPath(value, path, token)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+func getCorrelationTokenFromResponse(path string, payload []byte) (string, error) {
+	var value interface{}
+	err := json.Unmarshal(payload, &value)
+	if err != nil {
+		return "", fmt.Errorf("payload is not valid json: %w", err)
+	}
+	token, ok := getJsonPath(value, path)
+	if !ok || token == nil {
+		return "", errors.New("missing correlation token at " + path)
+	}
+	switch t := token.(type) {
+	case string:
+		return t, nil
+	case map[string]interface{}, []interface{}:
+		return "", errors.New("correlation token at " + path + " is not a scalar value")
+	default:
+		return fmt.Sprint(t), nil
+	}
+}
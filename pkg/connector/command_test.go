@@ -0,0 +1,141 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResponseTimeout(t *testing.T) {
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+	conn, err := NewWithFactories(context.Background(), configuration.Config{MaxCorrelationIdAge: "1m"}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		return nil, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for timeout, expected := range map[string]time.Duration{
+		"":      time.Minute,
+		"-":     time.Minute,
+		"foo":   time.Minute,
+		"0s":    time.Minute,
+		"-10ms": time.Minute,
+		"50ms":  50 * time.Millisecond,
+	} {
+		if actual := conn.getResponseTimeout(model.TopicDescription{RespTimeout: timeout}); actual != expected {
+			t.Error(timeout, actual, expected)
+		}
+	}
+
+	conn.storeCorrelationId("d/s", "c1", 50*time.Millisecond)
+	conn.storeCorrelationId("d/s", "c2", 50*time.Millisecond)
+	conn.removeCorrelationId("d/s", "c2")
+	time.Sleep(200 * time.Millisecond)
+	mgwClient.mux.Lock()
+	commandErrors := mgwClient.commandErrors
+	mgwClient.mux.Unlock()
+	if !reflect.DeepEqual(commandErrors, []string{"c1"}) {
+		t.Error("unexpected command errors", commandErrors)
+	}
+	if _, ok := conn.correlationStore.Get("d/s"); ok {
+		t.Error("expected expired correlation ids to be removed")
+	}
+
+	problems := ValidateTopicDescriptions(configuration.Config{}, []TopicDescription{
+		model.TopicDescription{CmdTopic: "a", RespTopic: "a/resp", RespTimeout: "10s", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		model.TopicDescription{CmdTopic: "b", RespTopic: "b/resp", RespTimeout: "0s", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s2", DeviceTypeId: "dt"},
+		model.TopicDescription{CmdTopic: "c", RespTopic: "c/resp", RespTimeout: "-1s", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s3", DeviceTypeId: "dt"},
+	})
+	if len(problems) != 2 || problems[0].Index != 1 || problems[1].Index != 2 {
+		t.Error("unexpected problems", problems)
+	}
+}
+
+func TestCorrelationIdCleanup(t *testing.T) {
+	conn, err := NewWithFactories(context.Background(), configuration.Config{}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		return nil, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.storeCorrelationId(getCorrelationKey("d/s", "t1"), "c1", time.Minute)
+	conn.storeCorrelationId(getCorrelationKey("d/s", "t2"), "c2", time.Minute)
+	conn.storeCorrelationId(getCorrelationKey("d/s", "t3"), "c3", time.Minute)
+
+	if id, ok := conn.popCorrelationId(getCorrelationKey("d/s", "t1")); !ok || id != "c1" {
+		t.Error(id, ok)
+	}
+	conn.removeCorrelationId(getCorrelationKey("d/s", "t2"), "c2")
+	if id, ok := conn.popOldestCorrelationId("d/s"); !ok || id != "c3" {
+		t.Error(id, ok)
+	}
+	if keys := conn.correlationStore.GetKeys(); len(keys) != 0 {
+		t.Error("expected answered correlation keys to be removed", keys)
+	}
+}
+
+func TestResponseTransformationError(t *testing.T) {
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+	conn, err := NewWithFactories(context.Background(), configuration.Config{}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		return []model.TopicDescription{{
+			CmdTopic:        "meter/1/get",
+			RespTopic:       "meter/1/resp",
+			DeviceLocalId:   "meter_1",
+			DeviceName:      "meter 1",
+			ServiceLocalId:  "energy",
+			DeviceTypeId:    "dt",
+			Transformations: []model.Transformation{{Transformation: TransformerExpression, Path: "energy", Details: `{"formula": "value / 1000"}`}},
+		}}, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.updateTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.storeCorrelationId(getCommandId("meter_1", "energy"), "c1", time.Minute)
+	conn.ResponseHandler("meter/1/resp", false, []byte(`{"energy":"invalid"}`))
+	time.Sleep(100 * time.Millisecond)
+	mgwClient.mux.Lock()
+	defer mgwClient.mux.Unlock()
+	if !reflect.DeepEqual(mgwClient.deviceErrors, []string{"meter_1"}) {
+		t.Error("expected device error", mgwClient.deviceErrors)
+	}
+	if !reflect.DeepEqual(mgwClient.commandErrors, []string{"c1"}) {
+		t.Error("expected command error", mgwClient.commandErrors)
+	}
+}
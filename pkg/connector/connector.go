@@ -18,36 +18,65 @@ package connector
 
 import (
 	"context"
+	"errors"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/connector/onlinechecker"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo/auth"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/metrics"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt5"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/sparkplug"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/homie"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/util"
 	"log"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Connector struct {
-	mgwClient             MgwClient
-	config                configuration.Config
-	updateTickerDuration  time.Duration
-	updateTicker          *time.Ticker
-	topicDescProvider     TopicDescriptionProvider
-	commandMqttClient     MqttClient
-	eventMqttClient       MqttClient
-	updateTopicsMux       sync.Mutex
-	eventTopicRegister    *util.SyncMap[TopicDescription]
-	responseTopicRegister *util.SyncMap[TopicDescription]
-	commandTopicRegister  *util.SyncMap[TopicDescription]
-	correlationStore      *util.SyncMap[[]CorrelationId]
-	MaxCorrelationIdAge   time.Duration
-	onlineCheck           OnlineChecker
-	devicerepo            *devicerepo.DeviceRepo
+	mgwClient              MgwClient
+	config                 configuration.Config
+	updateTickerDuration   time.Duration
+	updateTicker           *time.Ticker
+	topicDescProvider      TopicDescriptionProvider
+	commandMqttClient      MqttClient
+	eventMqttClient        MqttClient
+	updateTopicsMux        sync.Mutex
+	eventTopicRegister     *util.SyncMap[TopicDescription]
+	eventPatternRegister   *util.SyncMap[TopicPattern]
+	learnedDevices         *util.SyncMap[TopicDescription]
+	devices                *util.SyncMap[TopicDescription]
+	responseTopicRegister  *util.SyncMap[TopicDescription]
+	commandTopicRegister   *util.SyncMap[TopicDescription]
+	statusTopicRegister    *util.SyncMap[TopicDescription]
+	statusStates           *util.SyncMap[mgw.State]
+	sparkplugRegister      *util.SyncMap[[]TopicDescription] //sparkplug device key -> event descriptions of the device
+	sparkplugHost          *sparkplug.Host
+	correlationStore       *util.SyncMap[[]CorrelationId]
+	MaxCorrelationIdAge    time.Duration
+	updateRetryBackoff     time.Duration
+	updateRetryMaxDuration time.Duration
+	lastUpdate             atomic.Pointer[UpdateResult]
+	quarantined            map[string]bool
+	quarantinedDevices     map[string]bool //devices with quarantined descriptions in the last update; excluded from removals
+	registeredDevices      map[string]RegisteredDevice
+	removalGracePeriod     time.Duration
+	missingSince           map[string]time.Time
+	storedMissingSince     map[string]time.Time
+	lastTopics             []TopicDescription
+	heldRemovals           map[string]bool
+	heldRemovalCycles      int
+	confirmedRemovals      map[string]bool
+	onlineCheck            OnlineChecker
+	liveness               *onlinechecker.Liveness
+	livenessTimeout        time.Duration
+	devicerepo             *devicerepo.DeviceRepo
+	metrics                *metrics.Metrics
 }
 
 type OnlineChecker interface {
@@ -57,7 +86,65 @@ type OnlineChecker interface {
 }
 
 func New(ctx context.Context, config configuration.Config) (result *Connector, err error) {
-	return NewWithFactories(ctx, config, NewTopicDescriptionProvider(topicdescription.Load), NewMgwFactory(mgw.New), NewMqttFactory(mqtt.New))
+	mqttFactory := NewMqttFactory(mqtt.New)
+	switch config.MqttProtocolVersion {
+	case "", "3", "3.1.1":
+	case "5":
+		mqttFactory = NewMqttFactory(mqtt5.New)
+	default:
+		return result, errors.New("unsupported mqtt_protocol_version: " + config.MqttProtocolVersion)
+	}
+	if !config.HomieDiscovery {
+		return NewWithFactories(ctx, config, NewTopicDescriptionProvider(topicdescription.Load), NewMgwFactory(mgw.New), mqttFactory)
+	}
+	return newWithHomieDiscovery(ctx, config, homie.New(config), NewMgwFactory(mgw.New), mqttFactory)
+}
+
+// newWithHomieDiscovery starts the homie discovery before the connector to include the retained homie attributes in the first update of the device registry
+func newWithHomieDiscovery(ctx context.Context, config configuration.Config, discovery *homie.Discovery, mgwFactory MgwFactory, mqttFactory MqttFactory) (result *Connector, err error) {
+	connector := atomic.Pointer[Connector]{}
+	client, err := startHomieDiscovery(ctx, config, discovery, mqttFactory, func() {
+		//changes before the connector is created are part of its first update
+		if c := connector.Load(); c != nil {
+			log.Println("homie devices changed; update device registry")
+			c.RefreshDeviceInfo()
+		}
+	})
+	if err != nil {
+		return result, err
+	}
+	//wait for the initial burst of retained attributes
+	select {
+	case <-ctx.Done():
+		return result, ctx.Err()
+	case <-time.After(discovery.Debounce):
+	}
+	result, err = NewWithFactories(ctx, config, NewTopicDescriptionProvider(discovery.Provider(topicdescription.Load)), mgwFactory, mqttFactory)
+	if err != nil {
+		return result, err
+	}
+	result.registerConnectionState("mqtt_homie", client)
+	connector.Store(result)
+	return result, nil
+}
+
+// startHomieDiscovery subscribes to the homie topics with a separate client to prevent overlaps with the event subscriptions;
+// onChange is called if discovered devices change
+func startHomieDiscovery(ctx context.Context, config configuration.Config, discovery *homie.Discovery, mqttFactory MqttFactory, onChange func()) (client MqttClient, err error) {
+	clientId := config.MqttEventClientId
+	if clientId != "" {
+		clientId = clientId + "_homie"
+	}
+	client, err = mqttFactory(ctx, config.MqttBroker, clientId, config.MqttUser, config.MqttPw, config.MqttInsecureSkipVerify)
+	if err != nil {
+		return client, err
+	}
+	err = discovery.Start(client, onChange)
+	if err != nil {
+		log.Println("ERROR: unable to subscribe to homie topics", err)
+		return client, err
+	}
+	return client, nil
 }
 
 func NewWithFactories(ctx context.Context, config configuration.Config, topicDescProvider TopicDescriptionProvider, mgwFactory MgwFactory, mqttFactory MqttFactory) (result *Connector, err error) {
@@ -67,6 +154,18 @@ func NewWithFactories(ctx context.Context, config configuration.Config, topicDes
 	if config.DeviceRepoCacheDuration == "" {
 		config.DeviceRepoCacheDuration = "10m"
 	}
+	if config.UpdateRetryBackoff == "" {
+		config.UpdateRetryBackoff = "1s"
+	}
+	if config.UpdateRetryMaxDuration == "" {
+		config.UpdateRetryMaxDuration = "10s"
+	}
+	if config.ValidationMode == "" {
+		config.ValidationMode = ValidationModeQuarantine
+	}
+	if config.ValidationMode != ValidationModeQuarantine && config.ValidationMode != ValidationModeStrict {
+		return result, errors.New("unsupported validation_mode: " + config.ValidationMode)
+	}
 
 	a := &auth.Auth{Credentials: auth.Credentials{
 		MgwCertManagerUrl: config.GeneratorMgwCertManagerUrl,
@@ -106,25 +205,74 @@ func NewWithFactories(ctx context.Context, config configuration.Config, topicDes
 		commandMqttClient:     commandMqttClient,
 		eventMqttClient:       eventMqttClient,
 		eventTopicRegister:    util.NewSyncMap[TopicDescription](),
+		eventPatternRegister:  util.NewSyncMap[TopicPattern](),
+		learnedDevices:        util.NewSyncMap[TopicDescription](),
+		devices:               util.NewSyncMap[TopicDescription](),
 		responseTopicRegister: util.NewSyncMap[TopicDescription](),
 		commandTopicRegister:  util.NewSyncMap[TopicDescription](),
+		statusTopicRegister:   util.NewSyncMap[TopicDescription](),
+		statusStates:          util.NewSyncMap[mgw.State](),
+		sparkplugRegister:     util.NewSyncMap[[]TopicDescription](),
+		sparkplugHost:         sparkplug.NewHost(),
 		correlationStore:      util.NewSyncMap[[]CorrelationId](),
 		onlineCheck:           checker,
 		devicerepo:            repo,
+		metrics:               metrics.New(),
 	}
 	result.MaxCorrelationIdAge, err = time.ParseDuration(config.MaxCorrelationIdAge)
 	if err != nil {
 		return result, err
 	}
+	result.updateRetryBackoff, err = time.ParseDuration(config.UpdateRetryBackoff)
+	if err != nil {
+		return result, err
+	}
+	result.updateRetryMaxDuration, err = time.ParseDuration(config.UpdateRetryMaxDuration)
+	if err != nil {
+		return result, err
+	}
+	if config.LivenessTimeout != "" && config.LivenessTimeout != "-" {
+		result.livenessTimeout, err = time.ParseDuration(config.LivenessTimeout)
+		if err != nil {
+			return result, err
+		}
+	}
+	result.liveness = onlinechecker.NewLiveness(result.handleLivenessTimeout)
+	if config.RemovalGracePeriod != "" && config.RemovalGracePeriod != "-" {
+		result.removalGracePeriod, err = time.ParseDuration(config.RemovalGracePeriod)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	err = result.loadRegisteredDevices()
+	if err != nil {
+		log.Println("ERROR: unable to load registered devices", config.RegisteredDevicesFile, err)
+		return result, err
+	}
 
 	result.mgwClient, err = mgwFactory(ctx, config, result.RefreshDeviceInfo)
 	if err != nil {
 		return result, err
 	}
 
+	result.registerConnectionState("mgw", result.mgwClient)
+	result.registerConnectionState("mqtt_cmd", commandMqttClient)
+	result.registerConnectionState("mqtt_event", eventMqttClient)
+
 	return result, result.start(ctx)
 }
 
+func (this *Connector) GetMetrics() *metrics.Metrics {
+	return this.metrics
+}
+
+func (this *Connector) registerConnectionState(name string, client interface{}) {
+	if c, ok := client.(ConnectionStateProvider); ok {
+		this.metrics.RegisterConnectionState(name, c.IsConnected)
+	}
+}
+
 func (this *Connector) RefreshDeviceInfo() {
 	err := this.updateTopics()
 	if err != nil {
@@ -139,6 +287,50 @@ func (this *Connector) start(ctx context.Context) (err error) {
 	if err != nil {
 		return err
 	}
+	err = this.startTopicDescriptionWatcher(ctx)
+	if err != nil {
+		return err
+	}
+	if controlClient, ok := this.mgwClient.(MgwControlClient); ok {
+		err = controlClient.ListenToControlMessages(func(command string, payload []byte) {
+			go this.handleControlMessage(command, payload)
+		})
+		if err != nil {
+			//the mgw client subscribes again on connect
+			log.Println("WARNING: unable to listen to control messages", err)
+		}
+	}
+	return nil
+}
+
+// startTopicDescriptionWatcher updates the topic registry on changes in the device descriptions dir;
+// the periodical update remains as fallback
+func (this *Connector) startTopicDescriptionWatcher(ctx context.Context) error {
+	if this.config.DeviceDescriptionsDir == "" || this.config.DeviceDescriptionsWatchDebounce == "" || this.config.DeviceDescriptionsWatchDebounce == "-" {
+		return nil
+	}
+	debounce, err := time.ParseDuration(this.config.DeviceDescriptionsWatchDebounce)
+	if err != nil {
+		log.Println("ERROR: unable to parse device descriptions watch debounce as duration")
+		return err
+	}
+	ignore := []string{}
+	if this.config.GeneratorUse {
+		//generated descriptions are written by updateTopics itself
+		ignore = append(ignore, this.config.GeneratorDeviceDescriptionsDir)
+	}
+	err = topicdescription.Watch(ctx, this.config.DeviceDescriptionsDir, debounce, ignore, func() {
+		log.Println("topic-descriptions changed; update device registry")
+		err := this.updateTopics()
+		if err != nil {
+			log.Println("ERROR: unable to update device registry after topic-description change:", err)
+			this.mgwClient.SendClientError("unable to update device registry after topic-description change: " + err.Error())
+		}
+	})
+	if err != nil {
+		//the periodical update is still able to load descriptions if the dir is created later
+		log.Println("WARNING: unable to watch device descriptions dir", this.config.DeviceDescriptionsDir, err)
+	}
 	return nil
 }
 
@@ -175,9 +367,16 @@ func (this *Connector) startPeriodicalTopicRegistryUpdate(ctx context.Context) (
 	return nil
 }
 
-func (this *Connector) splitTopicDescriptions(topics []TopicDescription) (events []TopicDescription, commands []TopicDescription, responses []TopicDescription) {
+// splitTopicDescriptions sorts the descriptions by the registers they are used in;
+// sparkplug event topics are handled by the subscription of their edge node instead of an event subscription
+func (this *Connector) splitTopicDescriptions(topics []TopicDescription) (events []TopicDescription, commands []TopicDescription, responses []TopicDescription, statuses []TopicDescription, sparkplugs []TopicDescription) {
 	for _, topic := range topics {
-		if topic.GetEventTopic() != "" {
+		if topic.GetStatusTopic() != "" {
+			statuses = append(statuses, topic)
+		}
+		if isSparkplugEvent(topic) {
+			sparkplugs = append(sparkplugs, topic)
+		} else if topic.GetEventTopic() != "" {
 			events = append(events, topic)
 		}
 		if topic.GetResponseTopic() != "" && topic.GetCmdTopic() != "" {
@@ -100,6 +100,46 @@ func (this MockDesc) GetResponseTopic() string {
 	return this.GetCmdTopic() + "/resp"
 }
 
+func (this MockDesc) GetResponseTimeout() string {
+	return ""
+}
+
+func (this MockDesc) GetCorrelationPath() string {
+	return ""
+}
+
+func (this MockDesc) GetCorrelationToken() string {
+	return ""
+}
+
+func (this MockDesc) GetUserProperties() map[string]string {
+	return nil
+}
+
+func (this MockDesc) GetMessageExpiry() string {
+	return ""
+}
+
+func (this MockDesc) GetLivenessTimeout() string {
+	return ""
+}
+
+func (this MockDesc) GetStatusTopic() string {
+	return ""
+}
+
+func (this MockDesc) GetStatusPath() string {
+	return ""
+}
+
+func (this MockDesc) GetStatusOnlineValues() []string {
+	return nil
+}
+
+func (this MockDesc) GetStatusOfflineValues() []string {
+	return nil
+}
+
 func (this MockDesc) GetDeviceTypeId() string {
 	return "dtid"
 }
@@ -112,6 +152,26 @@ func (this MockDesc) GetLocalServiceId() string {
 	return "slid"
 }
 
+func (this MockDesc) GetDevices() []string {
+	return nil
+}
+
+func (this MockDesc) GetPayloadFormat() string {
+	return ""
+}
+
+func (this MockDesc) GetProtobufDescriptor() string {
+	return ""
+}
+
+func (this MockDesc) GetProtobufMessage() string {
+	return ""
+}
+
+func (this MockDesc) GetSparkplugMetrics() []string {
+	return nil
+}
+
 func (this MockDesc) HasTransformations() bool {
 	return false
 }
@@ -120,6 +180,10 @@ func (this MockDesc) GetTransformations(kind string) (result []string) {
 	return nil
 }
 
+func (this MockDesc) GetTransformationDetails(kind string) (result map[string]string) {
+	return nil
+}
+
 func newMgwMock(ctx context.Context, config configuration.Config, refreshNotifier func()) (*MgwMock, error) {
 	return &MgwMock{config: config}, nil
 }
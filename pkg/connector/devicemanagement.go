@@ -21,11 +21,18 @@ import (
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
 	"log"
 	"net/url"
+	"time"
 )
 
 func (this *Connector) updateTopics() (err error) {
 	this.updateTopicsMux.Lock()
 	defer this.updateTopicsMux.Unlock()
+	start := time.Now()
+	plan := UpdatePlan{}
+	defer func() {
+		this.storeUpdateResult(start, plan, err)
+		this.metrics.ObserveUpdate(start, err)
+	}()
 	if this.topicDescProvider == nil {
 		return errors.New("missing topicDescProvider")
 	}
@@ -34,142 +41,56 @@ func (this *Connector) updateTopics() (err error) {
 		return err
 	}
 
-	err = this.validateTopicDescriptions(topics)
-	if err != nil {
-		return err
-	}
+	topics = this.guardRemovals(topics)
 
-	events, commands, responses := this.splitTopicDescriptions(topics)
-
-	err = this.onlineCheck.Preprocess(events)
+	topics, err = this.validateTopicDescriptions(topics)
 	if err != nil {
 		return err
 	}
 
-	oldDevices := map[string]TopicDescription{}
-	usedDevices := map[string]TopicDescription{}
-
-	// populate event topic registry and usedDevices
-	// delay subscriptions after device management/registration to ensure evaluation of retained messages
-	oldEvents := this.eventTopicRegister.GetAll()
-	usedEvents := map[string]bool{}
-	addEvents := []TopicDescription{}
-	updateEvents := []TopicDescription{}
+	events, commands, responses, statuses, sparkplugs := this.splitTopicDescriptions(topics)
+	this.restoreLearnedDevices(events)
 
+	//events with wildcard topics are resolved to the devices they refer to
+	eventDevices := []TopicDescription{}
 	for _, topic := range events {
-		usedEvents[topic.GetEventTopic()] = true
-		usedDevices[topic.GetLocalDeviceId()] = topic
-		if old, ok := this.eventTopicRegister.Get(topic.GetEventTopic()); !ok {
-			addEvents = append(addEvents, topic)
-		} else if !EqualTopicDesc(old, topic) {
-			updateEvents = append(updateEvents, topic)
-		}
-	}
-	for key, topic := range oldEvents {
-		oldDevices[topic.GetLocalDeviceId()] = topic
-		if _, used := usedEvents[key]; !used {
-			err = this.removeEvent(key)
-			if err != nil {
-				return err
+		for _, device := range this.getDeviceDescriptions(topic) {
+			if device.GetLocalServiceId() != "" {
+				eventDevices = append(eventDevices, device)
 			}
 		}
 	}
 
-	// populate response registry and usedDevices
-	oldResponses := this.responseTopicRegister.GetAll()
-	usedResponses := map[string]bool{}
-	for _, topic := range responses {
-		usedResponses[topic.GetResponseTopic()] = true
-		usedDevices[topic.GetLocalDeviceId()] = topic
-		if old, ok := this.responseTopicRegister.Get(topic.GetResponseTopic()); !ok {
-			err = this.addResponse(topic)
-		} else if !EqualTopicDesc(old, topic) {
-			err = this.updateResponse(topic)
-		}
-		if err != nil {
-			return err
-		}
-	}
-	for key, topic := range oldResponses {
-		oldDevices[topic.GetLocalDeviceId()] = topic
-		if _, notDeleted := usedResponses[key]; !notDeleted {
-			err = this.removeResponse(key)
-			if err != nil {
-				return err
-			}
-		}
+	err = this.onlineCheck.Preprocess(eventDevices)
+	if err != nil {
+		return err
 	}
 
-	// populate commands registry and usedDevices
-	oldCommands := this.commandTopicRegister.GetAll()
-	usedCommands := map[string]bool{}
-	for _, topic := range commands {
-		usedDevices[topic.GetLocalDeviceId()] = topic
-		commandId := getCommandIdFromDesc(topic)
-		usedCommands[commandId] = true
-		this.commandTopicRegister.Set(commandId, topic)
-	}
-	for key, topic := range oldCommands {
-		oldDevices[topic.GetLocalDeviceId()] = topic
-		if _, notDeleted := usedCommands[key]; !notDeleted {
-			this.commandTopicRegister.Remove(key)
-		}
+	plan = this.planUpdate(events, commands, responses, statuses, sparkplugs)
+	if this.config.Debug {
+		log.Println("DEBUG: update plan:\n" + plan.String())
 	}
-
-	addedDevices := map[string]bool{}
-	removedDevices := map[string]bool{}
-
-	//find old devices to remove
-	for id, oldDesc := range oldDevices {
-		if _, ok := usedDevices[id]; !ok {
-			if _, ok2 := removedDevices[id]; !ok2 {
-				removedDevices[id] = true
-				err := this.removeDevice(oldDesc)
-				if err != nil {
-					return err
-				}
-			}
-		}
+	err = this.applyUpdatePlan(plan)
+	if err != nil {
+		return err
 	}
+	this.lastTopics = topics
+	this.setRegisteredDevices()
+	return nil
+}
 
-	//find new devices to add/update
-	for id, desc := range usedDevices {
-		state := mgw.Online
-		if temp, ok := this.onlineCheck.LoadState(desc); ok {
-			state = temp
-		}
-		err = this.mgwClient.SetDevice(desc.GetLocalDeviceId(), desc.GetDeviceName(), desc.GetDeviceTypeId(), string(state))
-		if err != nil {
-			log.Println("ERROR: unable to send device info to mgw", err)
-			this.mgwClient.SendClientError("unable to send device info to mgw: " + err.Error())
-			return err
-		}
-		if _, ok := oldDevices[id]; !ok {
-			if _, ok2 := addedDevices[id]; !ok2 {
-				addedDevices[id] = true
-				err := this.addDeviceCommandListener(desc)
-				if err != nil {
-					return err
-				}
-			}
-		}
+func (this *Connector) getDeviceState(desc TopicDescription) mgw.State {
+	state := mgw.Online
+	if temp, ok := this.onlineCheck.LoadState(desc); ok {
+		state = temp
 	}
-
-	//update subscriptions (only after device registration to ensure evaluation of retained messages)
-	for _, topic := range addEvents {
-		err = this.addEvent(topic)
-		if err != nil {
-			return err
-		}
+	if temp, ok := this.statusStates.Get(desc.GetLocalDeviceId()); ok {
+		state = temp
 	}
-	for _, topic := range updateEvents {
-		err = this.updateEvent(topic)
-		if err != nil {
-			return err
-		}
+	if temp, ok := this.liveness.LoadState(desc.GetLocalDeviceId()); ok && temp == mgw.Offline {
+		state = mgw.Offline
 	}
-
-	return nil
+	return state
 }
 
 func getCommandIdFromDesc(desc TopicDescription) string {
@@ -179,33 +100,3 @@ func getCommandIdFromDesc(desc TopicDescription) string {
 func getCommandId(deviceId string, serviceId string) string {
 	return url.PathEscape(deviceId) + "/" + url.PathEscape(serviceId)
 }
-
-func (this *Connector) addDeviceCommandListener(device DeviceDescription) (err error) {
-	if this.config.Debug {
-		log.Println("DEBUG: add device command listener", device)
-	}
-	err = this.mgwClient.ListenToDeviceCommands(device.GetLocalDeviceId(), this.CommandHandler)
-	if err != nil {
-		log.Println("ERROR: unable to subscribe to device commands", err)
-		this.mgwClient.SendClientError("unable to subscribe to device commands: " + err.Error())
-		return err
-	}
-	return nil
-}
-
-func (this *Connector) removeDevice(device DeviceDescription) error {
-	if this.config.Debug {
-		log.Println("DEBUG: remove device", device)
-	}
-	id := device.GetLocalDeviceId()
-	if this.config.DeleteDevices {
-		log.Println("delete device", device.GetDeviceName(), id)
-		err := this.mgwClient.RemoveDevice(id)
-		if err != nil {
-			return err
-		}
-	} else {
-		log.Println("topic description has ben removed but device deletion is disabled", device.GetDeviceName(), id)
-	}
-	return this.mgwClient.StopListenToDeviceCommands(id)
-}
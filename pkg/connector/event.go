@@ -16,11 +16,16 @@
 
 package connector
 
-import "log"
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/metrics"
+	"log"
+)
 
 func (this *Connector) EventHandler(topic string, retained bool, payload []byte) {
-	desc, ok := this.eventTopicRegister.Get(topic)
+	this.metrics.EventsReceived.Inc()
+	desc, ok := this.getEventDescription(topic)
 	if !ok {
+		this.metrics.EventsDropped.WithLabelValues(metrics.DropReasonUnregisteredTopic).Inc()
 		if this.config.Debug {
 			log.Println("DEBUG: ignore unregistered event", topic, string(payload))
 		}
@@ -29,25 +34,45 @@ func (this *Connector) EventHandler(topic string, retained bool, payload []byte)
 	if this.config.Debug {
 		log.Println("DEBUG: receive event", topic, string(payload))
 	}
+	if desc.GetPayloadFormat() != "" {
+		var err error
+		payload, err = decodePayload(desc, payload)
+		if err != nil {
+			log.Println("ERROR: unable to decode event", topic, err)
+			this.metrics.EventsDropped.WithLabelValues(metrics.DropReasonDecodeFailure).Inc()
+			this.mgwClient.SendDeviceError(desc.GetLocalDeviceId(), "unable to decode event: "+err.Error())
+			return
+		}
+	}
 	if desc.HasTransformations() {
 		var err error
-		payload, err = this.handleTransformations(desc, TransformerJsonUnwrapOutput, payload)
+		payload, err = this.handleOutputTransformations(desc, payload)
 		if err != nil {
 			log.Println("ERROR: unable to transform event", topic, err)
+			this.metrics.EventsDropped.WithLabelValues(metrics.DropReasonTransformFailure).Inc()
 			this.mgwClient.SendDeviceError(desc.GetLocalDeviceId(), "unable to transform event: "+err.Error())
 			return
 		}
 	}
 	go func() {
-		err := this.mgwClient.SendEvent(desc.GetLocalDeviceId(), desc.GetLocalServiceId(), payload)
+		this.learnDevice(desc)
+		err := this.sendEvent(desc.GetLocalDeviceId(), desc.GetLocalServiceId(), payload)
 		if err != nil {
 			log.Println("ERROR: unable to send event to mgw", err)
+			this.metrics.EventsDropped.WithLabelValues(metrics.DropReasonSendFailure).Inc()
 			this.mgwClient.SendDeviceError(desc.GetLocalDeviceId(), "unable to send event to mgw: "+err.Error())
 		}
 	}()
 	go func() {
+		//retained messages may be old and do not prove that the device is alive
+		cameOnline := !retained && this.liveness.Alive(desc.GetLocalDeviceId())
 		state, ignore := this.onlineCheck.CheckAndStoreState(desc, retained, payload)
+		if ignore && cameOnline {
+			log.Println("received event of offline device", desc.GetLocalDeviceId())
+			state, ignore = this.getDeviceState(desc), false
+		}
 		if !ignore {
+			this.metrics.DeviceStateTransitions.WithLabelValues(string(state)).Inc()
 			err := this.mgwClient.SetDevice(desc.GetLocalDeviceId(), desc.GetDeviceName(), desc.GetDeviceTypeId(), string(state))
 			if err != nil {
 				log.Println("ERROR: unable to send device info to mgw", err)
@@ -57,14 +82,44 @@ func (this *Connector) EventHandler(topic string, retained bool, payload []byte)
 	}()
 }
 
+// sendEvent sends the event to the mgw and counts it as forwarded or, if the mgw client buffers it, as buffered
+func (this *Connector) sendEvent(deviceId string, serviceId string, payload []byte) error {
+	bufferClient, ok := this.mgwClient.(MgwEventBufferClient)
+	if !ok {
+		err := this.mgwClient.SendEvent(deviceId, serviceId, payload)
+		if err == nil {
+			this.metrics.EventsForwarded.Inc()
+		}
+		return err
+	}
+	buffered, err := bufferClient.SendOrBufferEvent(deviceId, serviceId, payload)
+	switch {
+	case err != nil:
+	case buffered:
+		this.metrics.EventsBuffered.Inc()
+	default:
+		this.metrics.EventsForwarded.Inc()
+	}
+	return err
+}
+
 func (this *Connector) addEvent(topicDesc TopicDescription) (err error) {
 	if this.config.Debug {
 		log.Println("DEBUG: add event listener", topicDesc)
 	}
 	eventTopic := topicDesc.GetEventTopic()
+	pattern, err := ParseTopicPattern(eventTopic)
+	if err != nil {
+		return err
+	}
 	this.eventTopicRegister.Set(eventTopic, topicDesc)
-	err = this.eventMqttClient.Subscribe(eventTopic, 2, this.EventHandler)
+	if pattern.IsWildcard() {
+		this.eventPatternRegister.Set(eventTopic, pattern)
+	}
+	err = this.eventMqttClient.Subscribe(pattern.Subscription, 2, this.EventHandler)
 	if err != nil {
+		this.eventTopicRegister.Remove(eventTopic)
+		this.eventPatternRegister.Remove(eventTopic)
 		return err
 	}
 	return nil
@@ -89,10 +144,15 @@ func (this *Connector) removeEvent(topic string) (err error) {
 	if !exists {
 		return nil
 	}
-	err = this.eventMqttClient.Unsubscribe(desc.GetEventTopic())
+	pattern, err := ParseTopicPattern(desc.GetEventTopic())
+	if err != nil {
+		return err
+	}
+	err = this.eventMqttClient.Unsubscribe(pattern.Subscription)
 	if err != nil {
 		return err
 	}
 	this.eventTopicRegister.Remove(topic)
+	this.eventPatternRegister.Remove(topic)
 	return nil
 }
@@ -0,0 +1,59 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"testing"
+)
+
+type bufferingMgw struct {
+	recordingMgw
+	buffer bool
+}
+
+func (this *bufferingMgw) SendOrBufferEvent(deviceId string, serviceId string, value []byte) (buffered bool, err error) {
+	return this.buffer, this.SendEvent(deviceId, serviceId, value)
+}
+
+func TestSendEventMetrics(t *testing.T) {
+	counterValue := func(counter prometheus.Counter) float64 {
+		m := &dto.Metric{}
+		err := counter.Write(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.GetCounter().GetValue()
+	}
+	mgwClient := &bufferingMgw{}
+	conn := &Connector{mgwClient: mgwClient, metrics: metrics.New()}
+
+	err := conn.sendEvent("d", "s", []byte("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgwClient.buffer = true
+	err = conn.sendEvent("d", "s", []byte("2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forwarded, buffered := counterValue(conn.metrics.EventsForwarded), counterValue(conn.metrics.EventsBuffered); forwarded != 1 || buffered != 1 {
+		t.Error(forwarded, buffered)
+	}
+}
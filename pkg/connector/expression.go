@@ -0,0 +1,172 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Knetic/govaluate"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/util"
+	"math"
+	"slices"
+)
+
+// ExpressionVariable is the name of the transformed value in expression formulas
+const ExpressionVariable = "value"
+
+// Expression is the details of an expression transformation
+type Expression struct {
+	Formula string `json:"formula"` //applied to events and responses, e.g. "value / 100"
+	Inverse string `json:"inverse"` //applied to commands, e.g. "value * 100"
+}
+
+var expressionFunctions = map[string]govaluate.ExpressionFunction{
+	"round": func(arguments ...interface{}) (interface{}, error) {
+		if len(arguments) == 0 || len(arguments) > 2 {
+			return nil, errors.New("round expects 1 or 2 arguments")
+		}
+		value, ok := arguments[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("round: %v is not a number", arguments[0])
+		}
+		if len(arguments) == 1 {
+			return math.Round(value), nil
+		}
+		digits, ok := arguments[1].(float64)
+		if !ok {
+			return nil, fmt.Errorf("round: %v is not a number", arguments[1])
+		}
+		factor := math.Pow(10, digits)
+		return math.Round(value*factor) / factor, nil
+	},
+	"floor": mathExpressionFunction("floor", math.Floor),
+	"ceil":  mathExpressionFunction("ceil", math.Ceil),
+	"abs":   mathExpressionFunction("abs", math.Abs),
+}
+
+func mathExpressionFunction(name string, f func(float64) float64) govaluate.ExpressionFunction {
+	return func(arguments ...interface{}) (interface{}, error) {
+		if len(arguments) != 1 {
+			return nil, fmt.Errorf("%v expects 1 argument", name)
+		}
+		value, ok := arguments[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("%v: %v is not a number", name, arguments[0])
+		}
+		return f(value), nil
+	}
+}
+
+// handleExpressionTransformations applies the formula (or, with inverse, the inverse formula) of an expression to the value at a path.
+// expressions contains the json encoded Expression of each dot separated path ("" for the whole payload)
+func (this *Connector) handleExpressionTransformations(expressions map[string]string, payload []byte, inverse bool) ([]byte, error) {
+	if len(expressions) == 0 {
+		return payload, nil
+	}
+	var value interface{}
+	err := json.Unmarshal(payload, &value)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not valid json: %w", err)
+	}
+	paths := []string{}
+	for path := range expressions {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		expression, err := parseExpressionDetails(expressions[path])
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression for %v: %w", path, err)
+		}
+		formula := expression.Formula
+		if inverse {
+			formula = expression.Inverse
+		}
+		if formula == "" {
+			continue
+		}
+		if path == "" {
+			value, err = evaluateExpression(formula, value)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		fieldValue, ok := getJsonPath(value, path)
+		if !ok {
+			continue
+		}
+		fieldValue, err = evaluateExpression(formula, fieldValue)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		value, err = setJsonPath(value, path, fieldValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(value)
+}
+
+// parseExpressionDetails decodes the details of an expression transformation and parses its formulas
+func parseExpressionDetails(details string) (result Expression, err error) {
+	err = json.Unmarshal([]byte(details), &result)
+	if err != nil {
+		return result, err
+	}
+	for _, formula := range []string{result.Formula, result.Inverse} {
+		if formula == "" {
+			continue
+		}
+		_, err = parseExpression(formula)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// parsedExpressions caches the parsed formulas of all topic descriptions, to not parse them again for every message
+var parsedExpressions = util.NewSyncMap[*govaluate.EvaluableExpression]()
+
+func parseExpression(formula string) (*govaluate.EvaluableExpression, error) {
+	if expression, ok := parsedExpressions.Get(formula); ok {
+		return expression, nil
+	}
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(formula, expressionFunctions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid formula %v: %w", formula, err)
+	}
+	parsedExpressions.Set(formula, expression)
+	return expression, nil
+}
+
+func evaluateExpression(formula string, value interface{}) (interface{}, error) {
+	expression, err := parseExpression(formula)
+	if err != nil {
+		return nil, err
+	}
+	result, err := expression.Evaluate(map[string]interface{}{ExpressionVariable: value})
+	if err != nil {
+		return nil, fmt.Errorf("unable to evaluate %v with %v = %v: %w", formula, ExpressionVariable, value, err)
+	}
+	if number, ok := result.(float64); ok && (math.IsNaN(number) || math.IsInf(number, 0)) {
+		return nil, fmt.Errorf("%v with %v = %v results in %v", formula, ExpressionVariable, value, number)
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/homie"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// retainedHomieMqtt delivers retained homie attributes shortly after the subscription, like a broker does
+type retainedHomieMqtt struct {
+	recordingMqtt
+}
+
+func (this *retainedHomieMqtt) Subscribe(topic string, qos byte, handler func(topic string, retained bool, payload []byte)) error {
+	if topic == "homie/#" {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			for _, attribute := range [][2]string{
+				{"homie/heater/$homie", "4.0.0"},
+				{"homie/heater/$name", "Heater"},
+				{"homie/heater/$nodes", "thermostat"},
+				{"homie/heater/thermostat/$type", "thermostat"},
+				{"homie/heater/thermostat/$properties", "temperature"},
+				{"homie/heater/thermostat/temperature/$datatype", "float"},
+			} {
+				handler(attribute[0], true, []byte(attribute[1]))
+			}
+		}()
+	}
+	return this.recordingMqtt.Subscribe(topic, qos, handler)
+}
+
+func TestHomieDiscoveryBeforeFirstUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config := configuration.Config{
+		DeviceDescriptionsDir: t.TempDir(),
+		HomieDiscovery:        true,
+		HomieDeviceTypes:      map[string]string{"thermostat": "dt-thermostat"},
+	}
+	discovery := homie.New(config)
+	discovery.Debounce = 100 * time.Millisecond
+	mqttClient := &retainedHomieMqtt{recordingMqtt: recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}}
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+
+	conn, err := newWithHomieDiscovery(ctx, config, discovery, func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		//the mgw client triggers the first update on connect
+		if len(discovery.Descriptions()) == 0 {
+			t.Error("mgw client created before the retained homie attributes are received")
+		}
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return mqttClient, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.updateTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sortedKeys(mgwClient.devices), []string{"heater"}) {
+		t.Error(sortedKeys(mgwClient.devices))
+	}
+}
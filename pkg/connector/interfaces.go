@@ -21,7 +21,10 @@ import (
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt5"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/util"
+	"maps"
+	"slices"
 )
 
 type GenericMgwFactory[T MgwClient] func(ctx context.Context, config configuration.Config, refreshNotifier func()) (T, error)
@@ -51,9 +54,25 @@ type TopicDescription interface {
 	GetEventTopic() string
 	GetCmdTopic() string
 	GetResponseTopic() string
+	GetResponseTimeout() string
+	GetCorrelationPath() string
+	GetCorrelationToken() string
+	GetUserProperties() map[string]string
+	GetMessageExpiry() string
+	GetLivenessTimeout() string
+	GetStatusTopic() string
+	GetStatusPath() string
+	GetStatusOnlineValues() []string
+	GetStatusOfflineValues() []string
 	GetLocalServiceId() string
+	GetDevices() []string
+	GetPayloadFormat() string
+	GetProtobufDescriptor() string
+	GetProtobufMessage() string
+	GetSparkplugMetrics() []string //metric name patterns of sparkplug event topics
 	HasTransformations() bool
 	GetTransformations(kind string) (result []string)
+	GetTransformationDetails(kind string) (result map[string]string) //path -> kind specific details
 }
 
 type DeviceDescription interface {
@@ -66,8 +85,22 @@ func EqualTopicDesc(old TopicDescription, topic TopicDescription) bool {
 	if EqualDeviceDesc(old, topic) &&
 		old.GetEventTopic() == topic.GetEventTopic() &&
 		old.GetResponseTopic() == topic.GetResponseTopic() &&
+		old.GetResponseTimeout() == topic.GetResponseTimeout() &&
 		old.GetCmdTopic() == topic.GetCmdTopic() &&
-		old.GetLocalServiceId() == topic.GetLocalServiceId() {
+		old.GetLocalServiceId() == topic.GetLocalServiceId() &&
+		old.GetCorrelationPath() == topic.GetCorrelationPath() &&
+		old.GetCorrelationToken() == topic.GetCorrelationToken() &&
+		maps.Equal(old.GetUserProperties(), topic.GetUserProperties()) &&
+		old.GetMessageExpiry() == topic.GetMessageExpiry() &&
+		old.GetStatusTopic() == topic.GetStatusTopic() &&
+		old.GetStatusPath() == topic.GetStatusPath() &&
+		slices.Equal(old.GetStatusOnlineValues(), topic.GetStatusOnlineValues()) &&
+		slices.Equal(old.GetStatusOfflineValues(), topic.GetStatusOfflineValues()) &&
+		slices.Equal(old.GetDevices(), topic.GetDevices()) &&
+		old.GetPayloadFormat() == topic.GetPayloadFormat() &&
+		old.GetProtobufDescriptor() == topic.GetProtobufDescriptor() &&
+		old.GetProtobufMessage() == topic.GetProtobufMessage() &&
+		equalTransformations(old, topic) {
 		return true
 	}
 	return false
@@ -88,6 +121,27 @@ type MqttClient interface {
 	Publish(topic string, qos byte, retained bool, payload []byte) error
 }
 
+// MqttV5Client is optionally implemented by MqttClient implementations supporting mqtt 5 properties
+type MqttV5Client interface {
+	SubscribeWithProperties(topic string, qos byte, handler mqtt5.Handler) error
+	PublishWithProperties(topic string, qos byte, retained bool, payload []byte, properties mqtt5.Properties) error
+}
+
+// MgwControlClient is optionally implemented by MgwClient implementations to receive control messages (e.g. confirm-removals)
+type MgwControlClient interface {
+	ListenToControlMessages(handler mgw.ControlHandler) error
+}
+
+// MgwEventBufferClient is optionally implemented by MgwClient implementations which buffer events while the mgw broker is unreachable
+type MgwEventBufferClient interface {
+	SendOrBufferEvent(deviceId string, serviceId string, value []byte) (buffered bool, err error)
+}
+
+// ConnectionStateProvider is optionally implemented by MqttClient and MgwClient implementations to report their connection state
+type ConnectionStateProvider interface {
+	IsConnected() bool
+}
+
 func TopicDescriptionsConverter[T TopicDescription](from []T) []TopicDescription {
 	return util.ListMap(from, func(element T) TopicDescription { return element })
 }
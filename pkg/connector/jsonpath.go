@@ -0,0 +1,84 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// getJsonPath reads the value at a dot separated path (e.g. "meta.id" or "list.0.id") from a parsed json value
+func getJsonPath(value interface{}, path string) (result interface{}, ok bool) {
+	result = value
+	for _, segment := range strings.Split(path, ".") {
+		switch v := result.(type) {
+		case map[string]interface{}:
+			result, ok = v[segment]
+			if !ok {
+				return nil, false
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			result = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return result, true
+}
+
+// setJsonPath sets the value at a dot separated path in a parsed json value; missing objects are created
+func setJsonPath(value interface{}, path string, fieldValue interface{}) (result interface{}, err error) {
+	segments := strings.Split(path, ".")
+	if value == nil {
+		value = map[string]interface{}{}
+	}
+	current := value
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch v := current.(type) {
+		case map[string]interface{}:
+			if last {
+				v[segment] = fieldValue
+				return value, nil
+			}
+			next, ok := v[segment]
+			if !ok || next == nil {
+				next = map[string]interface{}{}
+				v[segment] = next
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, errors.New("invalid array index " + segment + " in path " + path)
+			}
+			if last {
+				v[index] = fieldValue
+				return value, nil
+			}
+			current = v[index]
+		default:
+			return nil, errors.New("unable to set " + path + ": " + strings.Join(segments[:i], ".") + " is not an object or array")
+		}
+	}
+	return value, nil
+}
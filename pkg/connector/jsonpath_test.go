@@ -0,0 +1,90 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"testing"
+)
+
+func TestSetCorrelationToken(t *testing.T) {
+	cases := []struct {
+		path     string
+		payload  string
+		expected string
+		err      bool
+	}{
+		{path: "id", payload: `{"value":1}`, expected: `{"id":"token","value":1}`},
+		{path: "meta.id", payload: `{"value":1}`, expected: `{"meta":{"id":"token"},"value":1}`},
+		{path: "meta.id", payload: `{"meta":{"foo":"bar"}}`, expected: `{"meta":{"foo":"bar","id":"token"}}`},
+		{path: "list.1.id", payload: `{"list":[{},{}]}`, expected: `{"list":[{},{"id":"token"}]}`},
+		{path: "meta.id", payload: ``, expected: `{"meta":{"id":"token"}}`},
+		{path: "id", payload: `42`, err: true},
+		{path: "value.id", payload: `{"value":1}`, err: true},
+		{path: "list.5", payload: `{"list":[]}`, err: true},
+		{path: "id", payload: `not json`, err: true},
+	}
+	for _, c := range cases {
+		result, err := setCorrelationToken(c.path, "token", []byte(c.payload))
+		if c.err {
+			if err == nil {
+				t.Error("expected error", c.path, c.payload, string(result))
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(c.path, c.payload, err)
+			continue
+		}
+		if string(result) != c.expected {
+			t.Error(c.path, c.payload, string(result), c.expected)
+		}
+	}
+}
+
+func TestGetCorrelationTokenFromResponse(t *testing.T) {
+	cases := []struct {
+		path     string
+		payload  string
+		expected string
+		err      bool
+	}{
+		{path: "id", payload: `{"id":"token","value":1}`, expected: "token"},
+		{path: "meta.id", payload: `{"meta":{"id":"token"}}`, expected: "token"},
+		{path: "list.0.id", payload: `{"list":[{"id":"token"}]}`, expected: "token"},
+		{path: "id", payload: `{"id":42}`, expected: "42"},
+		{path: "id", payload: `{"value":1}`, err: true},
+		{path: "id", payload: `{"id":null}`, err: true},
+		{path: "id", payload: `{"id":{"foo":"bar"}}`, err: true},
+		{path: "id", payload: `not json`, err: true},
+	}
+	for _, c := range cases {
+		result, err := getCorrelationTokenFromResponse(c.path, []byte(c.payload))
+		if c.err {
+			if err == nil {
+				t.Error("expected error", c.path, c.payload, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(c.path, c.payload, err)
+			continue
+		}
+		if result != c.expected {
+			t.Error(c.path, c.payload, result, c.expected)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// handleJsonReshapeTransformations builds a new json document from the payload.
+// mappings maps dot separated target paths ("" for the whole document) to source expressions (see parseReshapeExpression).
+// sources without a match are omitted in the result.
+func (this *Connector) handleJsonReshapeTransformations(mappings map[string]string, payload []byte) ([]byte, error) {
+	if len(mappings) == 0 {
+		return payload, nil
+	}
+	var value interface{}
+	err := json.Unmarshal(payload, &value)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not valid json: %w", err)
+	}
+	result, err := jsonReshape(value, mappings)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+func jsonReshape(value interface{}, mappings map[string]string) (result interface{}, err error) {
+	targets := []string{}
+	for target := range mappings {
+		targets = append(targets, target)
+	}
+	//parents are set before their children
+	slices.Sort(targets)
+	for _, target := range targets {
+		expression, err := parseReshapeExpression(mappings[target])
+		if err != nil {
+			return nil, fmt.Errorf("invalid json-reshape expression %v for %v: %w", mappings[target], target, err)
+		}
+		fieldValue, found := expression.evaluate(value)
+		if !found {
+			continue
+		}
+		if target == "" {
+			result = fieldValue
+			continue
+		}
+		result, err = setJsonPath(result, target, fieldValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if result == nil {
+		result = map[string]interface{}{}
+	}
+	return result, nil
+}
+
+type reshapeSegmentKind int
+
+const (
+	reshapeSegmentKey reshapeSegmentKind = iota
+	reshapeSegmentIndex
+	reshapeSegmentWildcard
+	reshapeSegmentFilter
+)
+
+type reshapeSegment struct {
+	kind   reshapeSegmentKind
+	key    string
+	index  int
+	filter *reshapeFilter
+}
+
+type reshapeFilter struct {
+	path     reshapeExpression
+	operator string //empty if the filter only checks the existence of path
+	value    interface{}
+}
+
+// reshapeExpression is a JSONPath like expression selecting values from a parsed json document:
+//   - `$` the root document (optional prefix)
+//   - `.name` or `['name']` object fields
+//   - `[0]` array elements (negative indexes count from the end)
+//   - `.*` or `[*]` all elements of an array or object
+//   - `[?(@.type == 'temp')]` array elements matching a filter (==, !=, <, <=, >, >=; without operator the path must exist)
+//
+// expressions with a wildcard or filter select a list of values.
+type reshapeExpression []reshapeSegment
+
+var reshapeFilterOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseReshapeExpression(expression string) (result reshapeExpression, err error) {
+	expression = strings.TrimSpace(expression)
+	expression = strings.TrimPrefix(expression, "$")
+	if expression != "" && expression[0] != '.' && expression[0] != '[' {
+		expression = "." + expression
+	}
+	for len(expression) > 0 {
+		switch expression[0] {
+		case '.':
+			expression = expression[1:]
+			end := strings.IndexAny(expression, ".[")
+			if end < 0 {
+				end = len(expression)
+			}
+			name := expression[:end]
+			expression = expression[end:]
+			switch name {
+			case "":
+				return nil, errors.New("empty field name")
+			case "*":
+				result = append(result, reshapeSegment{kind: reshapeSegmentWildcard})
+			default:
+				result = append(result, reshapeSegment{kind: reshapeSegmentKey, key: name})
+			}
+		case '[':
+			var segment reshapeSegment
+			segment, expression, err = parseReshapeBracket(expression)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, segment)
+		default:
+			return nil, fmt.Errorf("unexpected %q", expression)
+		}
+	}
+	return result, nil
+}
+
+func parseReshapeBracket(expression string) (segment reshapeSegment, rest string, err error) {
+	content := expression[1:]
+	switch {
+	case strings.HasPrefix(content, "'") || strings.HasPrefix(content, "\""):
+		quote := content[0]
+		end := strings.IndexByte(content[1:], quote)
+		if end < 0 || !strings.HasPrefix(content[end+2:], "]") {
+			return segment, rest, errors.New("unterminated quoted field name")
+		}
+		return reshapeSegment{kind: reshapeSegmentKey, key: content[1 : end+1]}, content[end+3:], nil
+	case strings.HasPrefix(content, "*]"):
+		return reshapeSegment{kind: reshapeSegmentWildcard}, content[2:], nil
+	case strings.HasPrefix(content, "?("):
+		end := findFilterEnd(content)
+		if end < 0 {
+			return segment, rest, errors.New("unterminated filter")
+		}
+		filter, err := parseReshapeFilter(content[2:end])
+		if err != nil {
+			return segment, rest, err
+		}
+		return reshapeSegment{kind: reshapeSegmentFilter, filter: &filter}, content[end+2:], nil
+	default:
+		end := strings.IndexByte(content, ']')
+		if end < 0 {
+			return segment, rest, errors.New("missing ]")
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(content[:end]))
+		if err != nil {
+			return segment, rest, fmt.Errorf("invalid array index %v", content[:end])
+		}
+		return reshapeSegment{kind: reshapeSegmentIndex, index: index}, content[end+1:], nil
+	}
+}
+
+// findFilterEnd returns the index of the ')' closing a filter starting with "?(", followed by ']'
+func findFilterEnd(content string) int {
+	depth := 0
+	var quote byte
+	for i := 1; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				if i+1 < len(content) && content[i+1] == ']' {
+					return i
+				}
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+func parseReshapeFilter(filter string) (result reshapeFilter, err error) {
+	filter = strings.TrimSpace(filter)
+	if !strings.HasPrefix(filter, "@") {
+		return result, errors.New("filter must start with @")
+	}
+	path, value := filter[1:], ""
+	for _, operator := range reshapeFilterOperators {
+		if index := indexOutsideQuotes(path, operator); index >= 0 {
+			result.operator = operator
+			path, value = path[:index], strings.TrimSpace(path[index+len(operator):])
+			break
+		}
+	}
+	result.path, err = parseReshapeExpression(strings.TrimSpace(path))
+	if err != nil {
+		return result, err
+	}
+	if result.operator == "" {
+		return result, nil
+	}
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		result.value = value[1 : len(value)-1]
+		return result, nil
+	}
+	err = json.Unmarshal([]byte(value), &result.value)
+	if err != nil {
+		return result, fmt.Errorf("invalid filter value %v", value)
+	}
+	return result, nil
+}
+
+func indexOutsideQuotes(text string, sub string) int {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(text[i:], sub):
+			return i
+		}
+	}
+	return -1
+}
+
+func (this reshapeExpression) isList() bool {
+	for _, segment := range this {
+		if segment.kind == reshapeSegmentWildcard || segment.kind == reshapeSegmentFilter {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate returns the selected value; list expressions return a (possibly empty) list and are always found
+func (this reshapeExpression) evaluate(value interface{}) (result interface{}, found bool) {
+	nodes := []interface{}{value}
+	for _, segment := range this {
+		next := []interface{}{}
+		for _, node := range nodes {
+			next = append(next, segment.apply(node)...)
+		}
+		nodes = next
+	}
+	if this.isList() {
+		return nodes, true
+	}
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	return nodes[0], true
+}
+
+func (this reshapeSegment) apply(node interface{}) (result []interface{}) {
+	switch this.kind {
+	case reshapeSegmentKey:
+		if m, ok := node.(map[string]interface{}); ok {
+			if value, ok := m[this.key]; ok {
+				return []interface{}{value}
+			}
+		}
+	case reshapeSegmentIndex:
+		if list, ok := node.([]interface{}); ok {
+			index := this.index
+			if index < 0 {
+				index = len(list) + index
+			}
+			if index >= 0 && index < len(list) {
+				return []interface{}{list[index]}
+			}
+		}
+	case reshapeSegmentWildcard:
+		switch v := node.(type) {
+		case []interface{}:
+			return v
+		case map[string]interface{}:
+			keys := []string{}
+			for key := range v {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				result = append(result, v[key])
+			}
+		}
+	case reshapeSegmentFilter:
+		if list, ok := node.([]interface{}); ok {
+			for _, element := range list {
+				if this.filter.matches(element) {
+					result = append(result, element)
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (this reshapeFilter) matches(element interface{}) bool {
+	value, found := this.path.evaluate(element)
+	if !found {
+		return false
+	}
+	switch this.operator {
+	case "":
+		return true
+	case "==":
+		return reflect.DeepEqual(value, this.value)
+	case "!=":
+		return !reflect.DeepEqual(value, this.value)
+	}
+	var compare int
+	switch v := value.(type) {
+	case float64:
+		expected, ok := this.value.(float64)
+		if !ok {
+			return false
+		}
+		compare = cmp.Compare(v, expected)
+	case string:
+		expected, ok := this.value.(string)
+		if !ok {
+			return false
+		}
+		compare = strings.Compare(v, expected)
+	default:
+		return false
+	}
+	switch this.operator {
+	case "<":
+		return compare < 0
+	case "<=":
+		return compare <= 0
+	case ">":
+		return compare > 0
+	case ">=":
+		return compare >= 0
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"log"
+	"time"
+)
+
+// getLivenessTimeout returns the liveness timeout of the description or the global liveness_timeout;
+// 0 disables the timeout
+func (this *Connector) getLivenessTimeout(desc TopicDescription) time.Duration {
+	timeout := desc.GetLivenessTimeout()
+	if timeout == "" {
+		return this.livenessTimeout
+	}
+	if timeout == "-" {
+		return 0
+	}
+	result, err := time.ParseDuration(timeout)
+	if err != nil {
+		log.Println("WARNING: unable to parse liveness timeout", timeout, err)
+		return this.livenessTimeout
+	}
+	return result
+}
+
+// updateLiveness sets the liveness timeouts of all devices with events;
+// if a device has multiple events, the longest timeout is used
+func (this *Connector) updateLiveness(events []TopicDescription) {
+	timeouts := map[string]time.Duration{}
+	for _, event := range events {
+		timeout := this.getLivenessTimeout(event)
+		for _, device := range this.getDeviceDescriptions(event) {
+			deviceId := device.GetLocalDeviceId()
+			if timeout > timeouts[deviceId] {
+				timeouts[deviceId] = timeout
+			}
+		}
+	}
+	this.liveness.Update(timeouts)
+}
+
+func (this *Connector) handleLivenessTimeout(deviceId string) {
+	desc, ok := this.devices.Get(deviceId)
+	if !ok {
+		desc, ok = this.learnedDevices.Get(deviceId)
+	}
+	if !ok {
+		return
+	}
+	log.Println("no event received within liveness timeout; set device offline", deviceId)
+	this.metrics.DeviceStateTransitions.WithLabelValues(string(mgw.Offline)).Inc()
+	err := this.mgwClient.SetDevice(deviceId, desc.GetDeviceName(), desc.GetDeviceTypeId(), string(mgw.Offline))
+	if err != nil {
+		log.Println("ERROR: unable to send device info to mgw", err)
+		this.mgwClient.SendClientError("unable to send device info to mgw: " + err.Error())
+	}
+}
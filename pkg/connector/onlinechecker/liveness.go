@@ -0,0 +1,142 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package onlinechecker
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"sync"
+	"time"
+)
+
+// Liveness marks devices as offline if no event has been received within their timeout
+// and as online on the next received event.
+type Liveness struct {
+	mux       sync.Mutex
+	devices   map[string]*livenessEntry
+	onTimeout func(deviceId string)
+}
+
+type livenessEntry struct {
+	timeout time.Duration
+	timer   *time.Timer
+	state   mgw.State
+}
+
+// NewLiveness creates a liveness tracker; onTimeout is called (in its own go routine) when a device becomes offline
+func NewLiveness(onTimeout func(deviceId string)) *Liveness {
+	return &Liveness{
+		devices:   map[string]*livenessEntry{},
+		onTimeout: onTimeout,
+	}
+}
+
+// Update sets the timeouts of all tracked devices. Devices missing in timeouts are no longer tracked.
+// Timers of unchanged devices keep running; new devices get the full timeout to send their first event.
+func (this *Liveness) Update(timeouts map[string]time.Duration) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for deviceId, entry := range this.devices {
+		if timeout, ok := timeouts[deviceId]; !ok || timeout <= 0 {
+			entry.timer.Stop()
+			delete(this.devices, deviceId)
+		}
+	}
+	for deviceId, timeout := range timeouts {
+		this.set(deviceId, timeout)
+	}
+}
+
+// Add tracks a single device, e.g. a device learned from a wildcard topic
+func (this *Liveness) Add(deviceId string, timeout time.Duration) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.set(deviceId, timeout)
+}
+
+func (this *Liveness) set(deviceId string, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	entry, ok := this.devices[deviceId]
+	if ok && entry.timeout == timeout {
+		return
+	}
+	if ok {
+		entry.timer.Stop()
+	} else {
+		entry = &livenessEntry{}
+		this.devices[deviceId] = entry
+	}
+	entry.timeout = timeout
+	entry.timer = this.startTimer(deviceId, entry)
+}
+
+func (this *Liveness) startTimer(deviceId string, entry *livenessEntry) *time.Timer {
+	var timer *time.Timer
+	timer = time.AfterFunc(entry.timeout, func() {
+		this.mux.Lock()
+		current, ok := this.devices[deviceId]
+		expired := ok && current == entry && entry.timer == timer && entry.state != mgw.Offline
+		if expired {
+			entry.state = mgw.Offline
+		}
+		this.mux.Unlock()
+		if expired {
+			this.onTimeout(deviceId)
+		}
+	})
+	return timer
+}
+
+// Alive restarts the timeout of the device and returns true if the device has been offline before
+func (this *Liveness) Alive(deviceId string) (cameOnline bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	entry, ok := this.devices[deviceId]
+	if !ok {
+		return false
+	}
+	entry.timer.Stop()
+	cameOnline = entry.state == mgw.Offline
+	entry.state = mgw.Online
+	entry.timer = this.startTimer(deviceId, entry)
+	return cameOnline
+}
+
+// LoadState returns the state of tracked devices; found is false if the device is not tracked
+// or if no event has been received and the timeout is still running
+func (this *Liveness) LoadState(deviceId string) (state mgw.State, found bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	entry, ok := this.devices[deviceId]
+	if !ok || entry.state == "" {
+		return "", false
+	}
+	return entry.state, true
+}
+
+func (this *Liveness) GetKnownStates() (result map[string]mgw.State) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result = map[string]mgw.State{}
+	for deviceId, entry := range this.devices {
+		if entry.state != "" {
+			result[deviceId] = entry.state
+		}
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package onlinechecker
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLiveness(t *testing.T) {
+	mux := sync.Mutex{}
+	timeouts := []string{}
+	liveness := NewLiveness(func(deviceId string) {
+		mux.Lock()
+		defer mux.Unlock()
+		timeouts = append(timeouts, deviceId)
+	})
+	liveness.Update(map[string]time.Duration{"a": 100 * time.Millisecond, "b": 300 * time.Millisecond, "c": 0})
+
+	if _, found := liveness.LoadState("a"); found {
+		t.Error("state should be unknown before the first event or timeout")
+	}
+	if liveness.Alive("c") {
+		t.Error("untracked device should not come online")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if state, _ := liveness.LoadState("a"); state != mgw.Offline {
+		t.Error(state)
+	}
+	if _, found := liveness.LoadState("b"); found {
+		t.Error("b should still be unknown")
+	}
+	if !liveness.Alive("a") {
+		t.Error("a should come online")
+	}
+	if liveness.Alive("b") {
+		t.Error("b was not offline")
+	}
+	if state, _ := liveness.LoadState("a"); state != mgw.Online {
+		t.Error(state)
+	}
+
+	//unchanged timeouts keep running timers; removed devices are no longer tracked
+	liveness.Update(map[string]time.Duration{"b": 300 * time.Millisecond})
+	time.Sleep(400 * time.Millisecond)
+	if _, found := liveness.LoadState("a"); found {
+		t.Error("a should no longer be tracked")
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(timeouts) != 2 || timeouts[0] != "a" || timeouts[1] != "b" {
+		t.Error(timeouts)
+	}
+	if states := liveness.GetKnownStates(); len(states) != 1 || states["b"] != mgw.Offline {
+		t.Error(states)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/codec"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/sparkplug"
+)
+
+// getPayloadCodec returns the codec of the payload format of desc; protobuf codecs are specific to the descriptor and message of desc
+func getPayloadCodec(desc TopicDescription) (codec.Codec, error) {
+	if desc.GetPayloadFormat() == codec.FormatProtobuf {
+		return codec.GetProtobuf(desc.GetProtobufDescriptor(), desc.GetProtobufMessage())
+	}
+	return codec.Get(desc.GetPayloadFormat())
+}
+
+func decodePayload(desc TopicDescription, payload []byte) ([]byte, error) {
+	payloadCodec, err := getPayloadCodec(desc)
+	if err != nil {
+		return nil, err
+	}
+	return payloadCodec.Decode(payload)
+}
+
+// encodePayload encodes a command; sparkplug metrics are typed with the data types of the last birth certificate of the device
+func (this *Connector) encodePayload(desc TopicDescription, jsonPayload []byte) ([]byte, error) {
+	if desc.GetPayloadFormat() == sparkplug.Format {
+		topic, err := sparkplug.ParseTopic(desc.GetCmdTopic())
+		if err != nil {
+			return nil, err
+		}
+		return sparkplug.Codec{DataTypes: this.sparkplugHost.DataTypes(topic)}.Encode(jsonPayload)
+	}
+	payloadCodec, err := getPayloadCodec(desc)
+	if err != nil {
+		return nil, err
+	}
+	return payloadCodec.Encode(jsonPayload)
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RegisteredDevice is a device registered in the mgw by this connector
+type RegisteredDevice struct {
+	Name         string `json:"name"`
+	DeviceTypeId string `json:"device_type_id"`
+	LearnedFrom  string `json:"learned_from,omitempty"` //wildcard event topic of devices learned from received events
+}
+
+type registeredDevicesFile struct {
+	ConnectorId  string                      `json:"connector_id"`
+	Devices      map[string]RegisteredDevice `json:"devices"`
+	MissingSince map[string]time.Time        `json:"missing_since,omitempty"` //devices in the removal grace period
+}
+
+func (this *Connector) registeredDevicesFileEnabled() bool {
+	return this.config.RegisteredDevicesFile != "" && this.config.RegisteredDevicesFile != "-"
+}
+
+// loadRegisteredDevices reads the devices registered before the last shutdown.
+// they are compared to the loaded topic descriptions on the next update to find orphaned devices;
+// learned devices are restored if their wildcard event topic is still described (see restoreLearnedDevices)
+func (this *Connector) loadRegisteredDevices() error {
+	this.registeredDevices = map[string]RegisteredDevice{}
+	this.missingSince = map[string]time.Time{}
+	this.storedMissingSince = map[string]time.Time{}
+	if !this.registeredDevicesFileEnabled() {
+		return nil
+	}
+	content, err := os.ReadFile(this.config.RegisteredDevicesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	stored := registeredDevicesFile{}
+	err = json.Unmarshal(content, &stored)
+	if err != nil {
+		return err
+	}
+	if stored.ConnectorId != this.config.ConnectorId {
+		log.Println("WARNING: ignore registered devices of other connector id", this.config.RegisteredDevicesFile, stored.ConnectorId)
+		return nil
+	}
+	if stored.Devices != nil {
+		this.registeredDevices = stored.Devices
+	}
+	for deviceId, since := range stored.MissingSince {
+		if _, ok := this.registeredDevices[deviceId]; ok {
+			this.missingSince[deviceId] = since
+		}
+	}
+	this.storedMissingSince = maps.Clone(this.missingSince)
+	return nil
+}
+
+// setRegisteredDevices replaces the known registered devices with the devices register, learned devices,
+// orphans held back by the removal guard, quarantined devices and devices in the removal grace period
+func (this *Connector) setRegisteredDevices() {
+	registered := map[string]RegisteredDevice{}
+	for deviceId, desc := range this.devices.GetAll() {
+		registered[deviceId] = RegisteredDevice{Name: desc.GetDeviceName(), DeviceTypeId: desc.GetDeviceTypeId()}
+	}
+	for deviceId, desc := range this.learnedDevices.GetAll() {
+		registered[deviceId] = RegisteredDevice{Name: desc.GetDeviceName(), DeviceTypeId: desc.GetDeviceTypeId(), LearnedFrom: desc.GetEventTopic()}
+	}
+	for deviceId, since := range this.missingSince {
+		if _, reappeared := registered[deviceId]; reappeared {
+			log.Println("device reappeared within removal grace period", deviceId, "missing since", since)
+			delete(this.missingSince, deviceId)
+		}
+	}
+	for deviceId, device := range this.registeredDevices {
+		if _, missing := this.missingSince[deviceId]; missing || this.heldRemovals[deviceId] || this.quarantinedDevices[deviceId] {
+			registered[deviceId] = device
+		}
+	}
+	if maps.Equal(registered, this.registeredDevices) && maps.Equal(this.missingSince, this.storedMissingSince) {
+		return
+	}
+	this.registeredDevices = registered
+	this.storedMissingSince = maps.Clone(this.missingSince)
+	err := this.storeRegisteredDevices()
+	if err != nil {
+		log.Println("ERROR: unable to store registered devices", this.config.RegisteredDevicesFile, err)
+	}
+}
+
+func (this *Connector) storeRegisteredDevices() error {
+	if !this.registeredDevicesFileEnabled() {
+		return nil
+	}
+	content, err := json.Marshal(registeredDevicesFile{ConnectorId: this.config.ConnectorId, Devices: this.registeredDevices, MissingSince: this.missingSince})
+	if err != nil {
+		return err
+	}
+	location := this.config.RegisteredDevicesFile
+	temp, err := os.CreateTemp(filepath.Dir(location), filepath.Base(location)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.Write(content)
+	if err != nil {
+		temp.Close()
+		return err
+	}
+	err = temp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), location)
+}
+
+// removeOrphanOperation deletes a device registered by a previous run whose topic descriptions no longer exist,
+// or sets it offline if delete_devices is disabled
+func (this *Connector) removeOrphanOperation(deviceId string, device RegisteredDevice) UpdateOperation {
+	if this.config.DeleteDevices {
+		return UpdateOperation{
+			Action: UpdateActionRemove,
+			Target: UpdateTargetOrphan,
+			Key:    deviceId,
+			apply: func() error {
+				log.Println("delete orphaned device", device.Name, deviceId)
+				return this.mgwClient.RemoveDevice(deviceId)
+			},
+			revert: func() error {
+				//the device is still listed as registered and will be removed by the next update
+				return nil
+			},
+		}
+	}
+	return UpdateOperation{
+		Action: UpdateActionSet,
+		Target: UpdateTargetOrphan,
+		Key:    deviceId,
+		apply: func() error {
+			log.Println("set orphaned device offline", device.Name, deviceId)
+			return this.mgwClient.SetDevice(deviceId, device.Name, device.DeviceTypeId, string(mgw.Offline))
+		},
+		revert: func() error {
+			return nil
+		},
+	}
+}
+
+// startRemovalGraceOperations sets a device offline and marks it as missing;
+// the device is removed by the first update after removal_grace_period if its topic descriptions do not reappear
+func (this *Connector) startRemovalGraceOperations(deviceId string, device RegisteredDevice) []UpdateOperation {
+	return []UpdateOperation{
+		{
+			Action: UpdateActionSetOffline,
+			Target: UpdateTargetDevice,
+			Key:    deviceId,
+			apply: func() error {
+				log.Println("topic description has been removed; set device offline until end of removal grace period", device.Name, deviceId)
+				return this.mgwClient.SetDevice(deviceId, device.Name, device.DeviceTypeId, string(mgw.Offline))
+			},
+			revert: func() error {
+				return this.mgwClient.SetDevice(deviceId, device.Name, device.DeviceTypeId, string(mgw.Online))
+			},
+		},
+		this.setMissingOperation(deviceId, time.Now()),
+	}
+}
+
+func (this *Connector) setMissingOperation(deviceId string, since time.Time) UpdateOperation {
+	return UpdateOperation{
+		Action: UpdateActionSet,
+		Target: UpdateTargetRemovalGrace,
+		Key:    deviceId,
+		apply: func() error {
+			this.missingSince[deviceId] = since
+			return nil
+		},
+		revert: func() error {
+			delete(this.missingSince, deviceId)
+			return nil
+		},
+	}
+}
+
+func (this *Connector) removeMissingOperation(deviceId string) UpdateOperation {
+	since := this.missingSince[deviceId]
+	return UpdateOperation{
+		Action: UpdateActionRemove,
+		Target: UpdateTargetRemovalGrace,
+		Key:    deviceId,
+		apply: func() error {
+			delete(this.missingSince, deviceId)
+			return nil
+		},
+		revert: func() error {
+			this.missingSince[deviceId] = since
+			return nil
+		},
+	}
+}
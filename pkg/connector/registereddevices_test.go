@@ -0,0 +1,160 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOrphanedDevices(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "registered.json")
+
+	// mgw devices survive connector restarts
+	mgwDevices := map[string]string{}
+
+	start := func(t *testing.T, deleteDevices bool, descriptions []MockDesc) (*Connector, *recordingMgw) {
+		mgwClient := &recordingMgw{devices: mgwDevices, listening: map[string]bool{}}
+		conn, err := NewWithFactories(context.Background(), configuration.Config{
+			ConnectorId:           "test",
+			DeleteDevices:         deleteDevices,
+			RegisteredDevicesFile: file,
+		}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]MockDesc, error) {
+			return descriptions, nil
+		}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+			return mgwClient, nil
+		}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+			return &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = conn.updateTopics()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, mgwClient
+	}
+
+	t.Run("initial", func(t *testing.T) {
+		start(t, true, []MockDesc{"e:a", "e:b", "e:c"})
+		expected := map[string]string{"e:a_dlid": string(mgw.Online), "e:b_dlid": string(mgw.Online), "e:c_dlid": string(mgw.Online)}
+		if !reflect.DeepEqual(mgwDevices, expected) {
+			t.Error(mgwDevices, expected)
+		}
+	})
+
+	t.Run("delete orphans", func(t *testing.T) {
+		conn, _ := start(t, true, []MockDesc{"e:a", "e:b"})
+		expected := map[string]string{"e:a_dlid": string(mgw.Online), "e:b_dlid": string(mgw.Online)}
+		if !reflect.DeepEqual(mgwDevices, expected) {
+			t.Error(mgwDevices, expected)
+		}
+		last, _ := conn.GetLastUpdate()
+		found := false
+		for _, operation := range last.Plan {
+			found = found || operation == "remove orphan e:c_dlid"
+		}
+		if !found {
+			t.Error("missing orphan operation", last.Plan)
+		}
+	})
+
+	t.Run("set orphans offline", func(t *testing.T) {
+		start(t, false, []MockDesc{"e:a"})
+		expected := map[string]string{"e:a_dlid": string(mgw.Online), "e:b_dlid": string(mgw.Offline)}
+		if !reflect.DeepEqual(mgwDevices, expected) {
+			t.Error(mgwDevices, expected)
+		}
+	})
+
+	t.Run("orphans are handled once", func(t *testing.T) {
+		mgwDevices["e:b_dlid"] = string(mgw.Online)
+		start(t, true, []MockDesc{"e:a"})
+		expected := map[string]string{"e:a_dlid": string(mgw.Online), "e:b_dlid": string(mgw.Online)}
+		if !reflect.DeepEqual(mgwDevices, expected) {
+			t.Error(mgwDevices, expected)
+		}
+	})
+}
+
+func TestRestoreLearnedDevices(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "registered.json")
+	mgwDevices := map[string]string{}
+	descriptions := []model.TopicDescription{
+		{EventTopic: "sensors/{device}/temp", DeviceLocalId: "{device}", DeviceName: "sensor {device}", ServiceLocalId: "temp", DeviceTypeId: "dt"},
+	}
+
+	start := func(t *testing.T) *Connector {
+		conn, err := NewWithFactories(context.Background(), configuration.Config{
+			ConnectorId:           "test",
+			DeleteDevices:         true,
+			RegisteredDevicesFile: file,
+		}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+			return descriptions, nil
+		}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+			return &recordingMgw{devices: mgwDevices, listening: map[string]bool{}}, nil
+		}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+			return &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = conn.updateTopics()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+
+	conn := start(t)
+	desc, ok := conn.getEventDescription("sensors/s1/temp")
+	if !ok {
+		t.Fatal("missing event description")
+	}
+	conn.learnDevice(desc)
+	if !reflect.DeepEqual(mgwDevices, map[string]string{"s1": string(mgw.Online)}) {
+		t.Error(mgwDevices)
+	}
+
+	t.Run("restart", func(t *testing.T) {
+		conn := start(t)
+		if !reflect.DeepEqual(mgwDevices, map[string]string{"s1": string(mgw.Online)}) {
+			t.Error(mgwDevices)
+		}
+		learned, ok := conn.learnedDevices.Get("s1")
+		if !ok || learned.GetDeviceName() != "sensor s1" {
+			t.Error("expected restored learned device", learned)
+		}
+	})
+
+	t.Run("restart without wildcard topic", func(t *testing.T) {
+		descriptions = []model.TopicDescription{
+			{EventTopic: "sensors/s2/temp", DeviceLocalId: "s2", DeviceName: "sensor s2", ServiceLocalId: "temp", DeviceTypeId: "dt"},
+		}
+		start(t)
+		if !reflect.DeepEqual(mgwDevices, map[string]string{"s2": string(mgw.Online)}) {
+			t.Error(mgwDevices)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRemovalGracePeriod(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "registered.json")
+
+	mgwDevices := map[string]string{}
+	descriptions := []MockDesc{"e:a", "e:b"}
+
+	start := func(t *testing.T) (*Connector, *recordingMgw) {
+		mgwClient := &recordingMgw{devices: mgwDevices, listening: map[string]bool{}}
+		conn, err := NewWithFactories(context.Background(), configuration.Config{
+			ConnectorId:           "test",
+			DeleteDevices:         true,
+			RegisteredDevicesFile: file,
+			RemovalGracePeriod:    "200ms",
+		}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]MockDesc, error) {
+			return descriptions, nil
+		}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+			return mgwClient, nil
+		}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+			return &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, mgwClient
+	}
+
+	update := func(t *testing.T, conn *Connector) {
+		err := conn.updateTopics()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn, mgwClient := start(t)
+	update(t, conn)
+
+	t.Run("missing device is set offline", func(t *testing.T) {
+		descriptions = []MockDesc{"e:a"}
+		update(t, conn)
+		expected := map[string]string{"e:a_dlid": string(mgw.Online), "e:b_dlid": string(mgw.Offline)}
+		if !reflect.DeepEqual(mgwDevices, expected) {
+			t.Error(mgwDevices, expected)
+		}
+		if mgwClient.listening["e:b_dlid"] {
+			t.Error("missing device still listens to commands")
+		}
+		if _, ok := conn.missingSince["e:b_dlid"]; !ok {
+			t.Error("missing device is not in grace period")
+		}
+	})
+
+	t.Run("reappearing device is set online", func(t *testing.T) {
+		descriptions = []MockDesc{"e:a", "e:b"}
+		update(t, conn)
+		expected := map[string]string{"e:a_dlid": string(mgw.Online), "e:b_dlid": string(mgw.Online)}
+		if !reflect.DeepEqual(mgwDevices, expected) {
+			t.Error(mgwDevices, expected)
+		}
+		if !mgwClient.listening["e:b_dlid"] {
+			t.Error("reappeared device does not listen to commands")
+		}
+		if len(conn.missingSince) != 0 {
+			t.Error("unexpected grace period", conn.missingSince)
+		}
+	})
+
+	t.Run("grace period survives restart", func(t *testing.T) {
+		descriptions = []MockDesc{"e:a"}
+		update(t, conn)
+		conn, _ = start(t)
+		update(t, conn)
+		expected := map[string]string{"e:a_dlid": string(mgw.Online), "e:b_dlid": string(mgw.Offline)}
+		if !reflect.DeepEqual(mgwDevices, expected) {
+			t.Error(mgwDevices, expected)
+		}
+		if _, ok := conn.missingSince["e:b_dlid"]; !ok {
+			t.Error("missing device is not in grace period after restart")
+		}
+	})
+
+	t.Run("device is removed after grace period", func(t *testing.T) {
+		time.Sleep(300 * time.Millisecond)
+		update(t, conn)
+		expected := map[string]string{"e:a_dlid": string(mgw.Online)}
+		if !reflect.DeepEqual(mgwDevices, expected) {
+			t.Error(mgwDevices, expected)
+		}
+		if len(conn.missingSince) != 0 || len(conn.registeredDevices) != 1 {
+			t.Error("unexpected registered devices", conn.missingSince, conn.registeredDevices)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+)
+
+const ControlConfirmRemovals = "confirm-removals"
+
+// HeldRemovalsInfo describes device removals held back by the removal guard
+type HeldRemovalsInfo struct {
+	Devices []string `json:"devices"`
+	Known   int      `json:"known"`
+	Cycles  int      `json:"cycles"`
+}
+
+// guardRemovals holds back device removals if the count of removed devices exceeds removal_guard_max_count or removal_guard_max_percent.
+// held back devices keep their previous topic descriptions until the removal is confirmed
+// or persists unchanged for removal_guard_cycles consecutive updates.
+func (this *Connector) guardRemovals(topics []TopicDescription) []TopicDescription {
+	known := map[string]bool{}
+	for deviceId := range this.devices.GetAll() {
+		known[deviceId] = true
+	}
+	for deviceId := range this.learnedDevices.GetAll() {
+		known[deviceId] = true
+	}
+	for deviceId := range this.registeredDevices {
+		//devices in the removal grace period have already been counted as removed
+		if _, missing := this.missingSince[deviceId]; !missing {
+			known[deviceId] = true
+		}
+	}
+	used := map[string]bool{}
+	for _, topic := range topics {
+		for _, device := range this.getDeviceDescriptions(topic) {
+			used[device.GetLocalDeviceId()] = true
+		}
+	}
+	removed := map[string]bool{}
+	for deviceId := range known {
+		if !used[deviceId] {
+			removed[deviceId] = true
+		}
+	}
+
+	if !this.exceedsRemovalGuard(len(removed), len(known)) {
+		this.resetRemovalGuard()
+		return topics
+	}
+	if isSubset(removed, this.confirmedRemovals) {
+		log.Println("apply confirmed removal of", len(removed), "devices")
+		this.resetRemovalGuard()
+		return topics
+	}
+	changed := !maps.Equal(removed, this.heldRemovals)
+	if changed {
+		//only an unchanged removal persists
+		this.heldRemovalCycles = 0
+	}
+	this.heldRemovalCycles++
+	if this.config.RemovalGuardCycles > 0 && this.heldRemovalCycles >= this.config.RemovalGuardCycles {
+		log.Println("removal of", len(removed), "devices persisted for", this.heldRemovalCycles, "updates; apply removal")
+		this.resetRemovalGuard()
+		return topics
+	}
+	if changed {
+		message := fmt.Sprintf("hold back removal of %v of %v devices; confirm with POST /removals/confirm or control message %v", len(removed), len(known), ControlConfirmRemovals)
+		if this.config.RemovalGuardCycles > 0 {
+			message = message + fmt.Sprintf(" or wait %v updates", this.config.RemovalGuardCycles)
+		}
+		log.Println("WARNING:", message, sortedDeviceIds(removed))
+		this.mgwClient.SendClientError(message)
+	}
+	this.heldRemovals = removed
+
+	//keep the previous descriptions of held back devices
+	result := slices.Clone(topics)
+	for _, topic := range this.lastTopics {
+		for _, device := range this.getDeviceDescriptions(topic) {
+			if removed[device.GetLocalDeviceId()] {
+				result = append(result, topic)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (this *Connector) exceedsRemovalGuard(removed int, known int) bool {
+	maxCount := this.config.RemovalGuardMaxCount
+	maxPercent := this.config.RemovalGuardMaxPercent
+	if removed == 0 || (maxCount <= 0 && maxPercent <= 0) {
+		return false
+	}
+	if maxCount > 0 && removed > maxCount {
+		return true
+	}
+	if maxPercent > 0 && float64(removed)*100/float64(known) > maxPercent {
+		return true
+	}
+	return false
+}
+
+func (this *Connector) resetRemovalGuard() {
+	this.heldRemovals = nil
+	this.heldRemovalCycles = 0
+	this.confirmedRemovals = nil
+}
+
+// ConfirmRemovals applies the device removals held back by the removal guard
+func (this *Connector) ConfirmRemovals() (confirmed int, err error) {
+	this.updateTopicsMux.Lock()
+	this.confirmedRemovals = this.heldRemovals
+	confirmed = len(this.confirmedRemovals)
+	this.updateTopicsMux.Unlock()
+	if confirmed == 0 {
+		return 0, nil
+	}
+	log.Println("removal of", confirmed, "devices confirmed")
+	return confirmed, this.updateTopics()
+}
+
+func (this *Connector) GetHeldRemovals() (result HeldRemovalsInfo) {
+	this.updateTopicsMux.Lock()
+	defer this.updateTopicsMux.Unlock()
+	return HeldRemovalsInfo{
+		Devices: sortedDeviceIds(this.heldRemovals),
+		Known:   len(this.devices.GetKeys()),
+		Cycles:  this.heldRemovalCycles,
+	}
+}
+
+func (this *Connector) handleControlMessage(command string, payload []byte) {
+	switch command {
+	case ControlConfirmRemovals:
+		_, err := this.ConfirmRemovals()
+		if err != nil {
+			log.Println("ERROR: unable to apply confirmed removals", err)
+			this.mgwClient.SendClientError("unable to apply confirmed removals: " + err.Error())
+		}
+	default:
+		log.Println("WARNING: unknown control message", command)
+	}
+}
+
+func isSubset(subset map[string]bool, set map[string]bool) bool {
+	for key := range subset {
+		if !set[key] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedDeviceIds(ids map[string]bool) []string {
+	result := []string{}
+	for id := range ids {
+		result = append(result, id)
+	}
+	slices.Sort(result)
+	return result
+}
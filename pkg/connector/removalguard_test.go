@@ -0,0 +1,154 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"reflect"
+	"testing"
+)
+
+func TestRemovalGuard(t *testing.T) {
+	all := []MockDesc{"e:a", "e:b", "e:c", "e:d"}
+	descriptions := all
+	mqttClient := &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+	conn, err := NewWithFactories(context.Background(), configuration.Config{
+		DeleteDevices:          true,
+		RemovalGuardMaxCount:   1,
+		RemovalGuardMaxPercent: 50,
+		RemovalGuardCycles:     3,
+	}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]MockDesc, error) {
+		return descriptions, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return mqttClient, nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	update := func(t *testing.T, expectedDevices []string) {
+		t.Helper()
+		err := conn.updateTopics()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !reflect.DeepEqual(sortedKeys(mgwClient.devices), expectedDevices) {
+			t.Error("unexpected mgw devices", sortedKeys(mgwClient.devices), expectedDevices)
+		}
+		if !reflect.DeepEqual(sortedKeys(mqttClient.subscriptions), sortedKeys(conn.eventTopicRegister.GetAll())) {
+			t.Error("unexpected subscriptions", sortedKeys(mqttClient.subscriptions))
+		}
+	}
+	allDevices := []string{"e:a_dlid", "e:b_dlid", "e:c_dlid", "e:d_dlid"}
+
+	t.Run("initial", func(t *testing.T) {
+		update(t, allDevices)
+	})
+
+	t.Run("removal below threshold", func(t *testing.T) {
+		descriptions = all[:3]
+		update(t, allDevices[:3])
+		descriptions = all
+		update(t, allDevices)
+	})
+
+	t.Run("hold back removal", func(t *testing.T) {
+		descriptions = all[:1]
+		update(t, allDevices)
+		update(t, allDevices)
+		if len(mgwClient.clientErrors) != 1 {
+			t.Error("expected one client error", mgwClient.clientErrors)
+		}
+		held := conn.GetHeldRemovals()
+		if !reflect.DeepEqual(held.Devices, allDevices[1:]) || held.Cycles != 2 {
+			t.Error("unexpected held removals", held)
+		}
+	})
+
+	t.Run("confirm", func(t *testing.T) {
+		confirmed, err := conn.ConfirmRemovals()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if confirmed != 3 {
+			t.Error("unexpected confirmed count", confirmed)
+		}
+		if !reflect.DeepEqual(sortedKeys(mgwClient.devices), allDevices[:1]) {
+			t.Error("unexpected mgw devices", sortedKeys(mgwClient.devices))
+		}
+		if held := conn.GetHeldRemovals(); len(held.Devices) != 0 {
+			t.Error("unexpected held removals", held)
+		}
+	})
+
+	t.Run("persisting removal", func(t *testing.T) {
+		descriptions = all
+		update(t, allDevices)
+		descriptions = all[:1]
+		update(t, allDevices)
+		update(t, allDevices)
+		update(t, allDevices[:1])
+	})
+
+	t.Run("changed removal", func(t *testing.T) {
+		descriptions = all
+		update(t, allDevices)
+		descriptions = all[:1]
+		update(t, allDevices)
+		update(t, allDevices)
+		//removal of 2 of 4 devices exceeds the max count but not the max percent
+		descriptions = all[:2]
+		update(t, allDevices)
+		if held := conn.GetHeldRemovals(); !reflect.DeepEqual(held.Devices, allDevices[2:]) || held.Cycles != 1 {
+			t.Error("unexpected held removals", held)
+		}
+		update(t, allDevices)
+		update(t, allDevices[:2])
+	})
+}
+
+// disconnectedControlMgw fails to subscribe to control messages, like a mgw client which is not yet connected
+type disconnectedControlMgw struct {
+	recordingMgw
+}
+
+func (this *disconnectedControlMgw) ListenToControlMessages(handler mgw.ControlHandler) error {
+	return errors.New("mqtt client not connected")
+}
+
+func TestControlSubscriptionErrorDoesNotStopStartup(t *testing.T) {
+	_, err := NewWithFactories(context.Background(), configuration.Config{}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]MockDesc, error) {
+		return nil, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return &disconnectedControlMgw{recordingMgw: recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}}, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}, nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
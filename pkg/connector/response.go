@@ -17,11 +17,19 @@
 package connector
 
 import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/metrics"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt5"
 	"log"
 )
 
 func (this *Connector) ResponseHandler(topic string, retained bool, payload []byte) {
+	this.ResponseHandlerWithProperties(topic, retained, payload, mqtt5.Properties{})
+}
+
+// ResponseHandlerWithProperties handles responses received by mqtt 5 clients;
+// responses are correlated by the correlation data property if no correlation path is used
+func (this *Connector) ResponseHandlerWithProperties(topic string, retained bool, payload []byte, properties mqtt5.Properties) {
 	go func() {
 		desc, isRegistered := this.responseTopicRegister.Get(topic)
 		if !isRegistered {
@@ -30,22 +38,57 @@ func (this *Connector) ResponseHandler(topic string, retained bool, payload []by
 
 		deviceId := desc.GetLocalDeviceId()
 		serviceId := desc.GetLocalServiceId()
+		if desc.GetPayloadFormat() != "" {
+			var err error
+			payload, err = decodePayload(desc, payload)
+			if err != nil {
+				log.Println("ERROR: decode response", deviceId, serviceId, err)
+				this.metrics.ResponsesUnmatched.WithLabelValues(metrics.ResponseUnmatchedDecode).Inc()
+				this.mgwClient.SendDeviceError(deviceId, "unable to decode response: "+err.Error())
+				return
+			}
+		}
+
 		cmdId := getCommandId(deviceId, serviceId)
-		correlationId, correlationExists := this.popCorrelationId(cmdId)
+		correlationKey := cmdId
+		if path := desc.GetCorrelationPath(); path != "" {
+			token, err := getCorrelationTokenFromResponse(path, payload)
+			if err != nil {
+				log.Println("WARNING: unable to read correlation token from response", topic, err)
+				this.metrics.ResponsesUnmatched.WithLabelValues(metrics.ResponseUnmatchedInvalid).Inc()
+				return
+			}
+			correlationKey = getCorrelationKey(cmdId, token)
+		} else if len(properties.CorrelationData) > 0 {
+			correlationKey = getCorrelationKey(cmdId, string(properties.CorrelationData))
+		}
+		var correlationId string
+		var correlationExists bool
+		if correlationKey == cmdId {
+			correlationId, correlationExists = this.popOldestCorrelationId(cmdId)
+		} else {
+			correlationId, correlationExists = this.popCorrelationId(correlationKey)
+		}
 
 		if desc.HasTransformations() {
 			var err error
-			payload, err = this.handleTransformations(desc, TransformerJsonUnwrapOutput, payload)
+			payload, err = this.handleOutputTransformations(desc, payload)
 			if err != nil {
 				log.Println("ERROR: transform response", deviceId, serviceId, err)
+				this.metrics.ResponsesUnmatched.WithLabelValues(metrics.ResponseUnmatchedTransform).Inc()
 				this.mgwClient.SendDeviceError(desc.GetLocalDeviceId(), "unable to transform response: "+err.Error())
+				if correlationExists {
+					//the correlation id is already popped; without command error the command would never be answered
+					this.mgwClient.SendCommandError(correlationId, "unable to transform response: "+err.Error())
+				}
 				return
 			}
 		}
 
 		if !correlationExists {
+			this.metrics.ResponsesUnmatched.WithLabelValues(metrics.ResponseUnmatchedUnknown).Inc()
 			if this.config.Debug {
-				log.Println("DEBUG: no correlation id stored for response", topic, cmdId)
+				log.Println("DEBUG: no correlation id stored for response", topic, correlationKey)
 			}
 			return
 		}
@@ -55,8 +98,11 @@ func (this *Connector) ResponseHandler(topic string, retained bool, payload []by
 		})
 		if err != nil {
 			log.Println("ERROR: unable to send response", err)
+			this.metrics.ResponsesUnmatched.WithLabelValues(metrics.ResponseUnmatchedSendFailure).Inc()
 			this.mgwClient.SendCommandError(correlationId, "unable to send response: "+err.Error())
+			return
 		}
+		this.metrics.ResponsesMatched.Inc()
 	}()
 }
 
@@ -65,7 +111,11 @@ func (this *Connector) addResponse(topicDesc TopicDescription) (err error) {
 		log.Println("DEBUG: add response listener", topicDesc)
 	}
 	responseTopic := topicDesc.GetResponseTopic()
-	err = this.commandMqttClient.Subscribe(responseTopic, 2, this.ResponseHandler)
+	if v5Client, ok := this.commandMqttClient.(MqttV5Client); ok {
+		err = v5Client.SubscribeWithProperties(responseTopic, 2, this.ResponseHandlerWithProperties)
+	} else {
+		err = this.commandMqttClient.Subscribe(responseTopic, 2, this.ResponseHandler)
+	}
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,293 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/metrics"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/sparkplug"
+	"log"
+	"path"
+	"strings"
+)
+
+// isSparkplugEvent checks if the event topic of the description is a sparkplug b device topic.
+// these events are received by the subscription of the edge node
+func isSparkplugEvent(desc TopicDescription) bool {
+	return desc.GetEventTopic() != "" && desc.GetPayloadFormat() == sparkplug.Format
+}
+
+func getSparkplugDeviceKey(desc TopicDescription) string {
+	topic, err := sparkplug.ParseTopic(desc.GetEventTopic())
+	if err != nil {
+		return ""
+	}
+	return topic.DeviceKey()
+}
+
+// SparkplugHandler handles all messages of the subscribed edge nodes:
+// birth and death certificates set the online state of the devices, metrics of DBIRTH and DDATA are forwarded as events
+func (this *Connector) SparkplugHandler(topic string, retained bool, payload []byte) {
+	parsed, err := sparkplug.ParseTopic(topic)
+	if err != nil {
+		if this.config.Debug {
+			log.Println("DEBUG: ignore sparkplug message", topic, err)
+		}
+		return
+	}
+	switch parsed.MessageType {
+	case sparkplug.MessageNodeCommand, sparkplug.MessageDeviceCommand, sparkplug.MessageState:
+		return
+	case sparkplug.MessageDeviceBirth, sparkplug.MessageDeviceData:
+		this.metrics.EventsReceived.Inc()
+	}
+	if this.config.Debug {
+		log.Println("DEBUG: receive sparkplug message", topic, len(payload))
+	}
+	decoded, err := sparkplug.Unmarshal(payload)
+	if err != nil {
+		log.Println("ERROR: unable to decode sparkplug message", topic, err)
+		this.metrics.EventsDropped.WithLabelValues(metrics.DropReasonDecodeFailure).Inc()
+		for _, desc := range this.getSparkplugDevices(parsed) {
+			this.mgwClient.SendDeviceError(desc.GetLocalDeviceId(), "unable to decode sparkplug message: "+err.Error())
+		}
+		return
+	}
+	result := this.sparkplugHost.Handle(parsed, decoded)
+	if result.Rebirth {
+		log.Println("WARNING: unknown sparkplug session or sequence gap; request rebirth", parsed.NodeKey())
+		go this.publishSparkplugRebirth(parsed)
+	}
+	switch parsed.MessageType {
+	case sparkplug.MessageNodeDeath:
+		if result.Stale {
+			log.Println("WARNING: ignore sparkplug death certificate of previous session", topic)
+			return
+		}
+		for _, desc := range this.getSparkplugDevices(parsed) {
+			this.setDeviceStatus(desc, mgw.Offline)
+		}
+	case sparkplug.MessageDeviceDeath:
+		for _, desc := range this.getSparkplugDevices(parsed) {
+			this.setDeviceStatus(desc, mgw.Offline)
+		}
+	case sparkplug.MessageDeviceBirth:
+		for _, desc := range this.getSparkplugDevices(parsed) {
+			this.setDeviceStatus(desc, mgw.Online)
+		}
+		this.forwardSparkplugMetrics(parsed, result.Metrics)
+	case sparkplug.MessageDeviceData:
+		this.forwardSparkplugMetrics(parsed, result.Metrics)
+	}
+}
+
+// getSparkplugDevices returns one description per device of the topic; node topics refer to all devices of the edge node
+func (this *Connector) getSparkplugDevices(topic sparkplug.Topic) (result []TopicDescription) {
+	if topic.Device != "" {
+		if descs, ok := this.sparkplugRegister.Get(topic.DeviceKey()); ok && len(descs) > 0 {
+			result = append(result, descs[0])
+		}
+		return result
+	}
+	for key, descs := range this.sparkplugRegister.GetAll() {
+		if strings.HasPrefix(key, topic.NodeKey()+"/") && len(descs) > 0 {
+			result = append(result, descs[0])
+		}
+	}
+	return result
+}
+
+// forwardSparkplugMetrics sends the metrics matching the sparkplug_metrics of each service of the device as event
+func (this *Connector) forwardSparkplugMetrics(topic sparkplug.Topic, values []sparkplug.Metric) {
+	descs, ok := this.sparkplugRegister.Get(topic.DeviceKey())
+	if !ok {
+		this.metrics.EventsDropped.WithLabelValues(metrics.DropReasonUnregisteredTopic).Inc()
+		if this.config.Debug {
+			log.Println("DEBUG: ignore unregistered sparkplug device", topic)
+		}
+		return
+	}
+	for _, desc := range descs {
+		selected := []sparkplug.Metric{}
+		for _, metric := range values {
+			if matchesSparkplugMetric(desc.GetSparkplugMetrics(), metric.Name) {
+				selected = append(selected, metric)
+			}
+		}
+		if len(selected) == 0 {
+			continue
+		}
+		payload, err := sparkplug.MetricsToJson(selected)
+		if err != nil {
+			log.Println("ERROR: unable to decode sparkplug metrics", topic, err)
+			this.metrics.EventsDropped.WithLabelValues(metrics.DropReasonDecodeFailure).Inc()
+			this.mgwClient.SendDeviceError(desc.GetLocalDeviceId(), "unable to decode sparkplug metrics: "+err.Error())
+			continue
+		}
+		if desc.HasTransformations() {
+			payload, err = this.handleOutputTransformations(desc, payload)
+			if err != nil {
+				log.Println("ERROR: unable to transform event", topic, err)
+				this.metrics.EventsDropped.WithLabelValues(metrics.DropReasonTransformFailure).Inc()
+				this.mgwClient.SendDeviceError(desc.GetLocalDeviceId(), "unable to transform event: "+err.Error())
+				continue
+			}
+		}
+		go func(desc TopicDescription, payload []byte) {
+			err := this.sendEvent(desc.GetLocalDeviceId(), desc.GetLocalServiceId(), payload)
+			if err != nil {
+				log.Println("ERROR: unable to send event to mgw", err)
+				this.metrics.EventsDropped.WithLabelValues(metrics.DropReasonSendFailure).Inc()
+				this.mgwClient.SendDeviceError(desc.GetLocalDeviceId(), "unable to send event to mgw: "+err.Error())
+			}
+		}(desc, payload)
+	}
+}
+
+// matchesSparkplugMetric checks the metric name against the path.Match patterns; no patterns match all metrics
+func matchesSparkplugMetric(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// publishSparkplugRebirth asks the edge node to publish new birth certificates
+func (this *Connector) publishSparkplugRebirth(topic sparkplug.Topic) {
+	err := this.commandMqttClient.Publish(topic.RebirthTopic(), 0, false, sparkplug.RebirthRequest())
+	if err != nil {
+		log.Println("WARNING: unable to request sparkplug rebirth", topic.NodeKey(), err)
+	}
+}
+
+// getSparkplugNodes returns the edge nodes of the sparkplug devices by their subscription
+func getSparkplugNodes(devices map[string][]TopicDescription) (result map[string]sparkplug.Topic) {
+	result = map[string]sparkplug.Topic{}
+	for _, descs := range devices {
+		for _, desc := range descs {
+			topic, err := sparkplug.ParseTopic(desc.GetEventTopic())
+			if err == nil {
+				result[topic.NodeSubscription()] = topic
+			}
+		}
+	}
+	return result
+}
+
+func (this *Connector) setSparkplugDeviceOperation(key string, descs []TopicDescription) UpdateOperation {
+	old, known := this.sparkplugRegister.Get(key)
+	return UpdateOperation{
+		Action: UpdateActionSet,
+		Target: UpdateTargetSparkplugDevice,
+		Key:    key,
+		apply: func() error {
+			this.sparkplugRegister.Set(key, descs)
+			return nil
+		},
+		revert: func() error {
+			if known {
+				this.sparkplugRegister.Set(key, old)
+			} else {
+				this.sparkplugRegister.Remove(key)
+			}
+			return nil
+		},
+	}
+}
+
+// removeSparkplugDeviceOperation removes the device from the register and forgets its online state
+func (this *Connector) removeSparkplugDeviceOperation(key string, old []TopicDescription) UpdateOperation {
+	deviceId := ""
+	if len(old) > 0 {
+		deviceId = old[0].GetLocalDeviceId()
+	}
+	state, stateKnown := this.statusStates.Get(deviceId)
+	return UpdateOperation{
+		Action: UpdateActionRemove,
+		Target: UpdateTargetSparkplugDevice,
+		Key:    key,
+		apply: func() error {
+			this.sparkplugRegister.Remove(key)
+			this.statusStates.Remove(deviceId)
+			return nil
+		},
+		revert: func() error {
+			this.sparkplugRegister.Set(key, old)
+			if stateKnown {
+				this.statusStates.Set(deviceId, state)
+			}
+			return nil
+		},
+	}
+}
+
+// subscribeSparkplugNodeOperation subscribes to all messages of the edge node and requests a rebirth
+// to learn the metrics and online state of its devices; a failed rebirth request is only logged
+func (this *Connector) subscribeSparkplugNodeOperation(topic sparkplug.Topic) UpdateOperation {
+	subscription := topic.NodeSubscription()
+	return UpdateOperation{
+		Action: UpdateActionSubscribe,
+		Target: UpdateTargetSparkplugNode,
+		Key:    subscription,
+		apply: func() error {
+			if this.config.Debug {
+				log.Println("DEBUG: add sparkplug node listener", subscription)
+			}
+			this.sparkplugHost.Forget(topic.NodeKey())
+			err := this.eventMqttClient.Subscribe(subscription, 2, this.SparkplugHandler)
+			if err != nil {
+				return err
+			}
+			if this.sparkplugHost.RequestRebirth(topic.NodeKey()) {
+				this.publishSparkplugRebirth(topic)
+			}
+			return nil
+		},
+		revert: func() error {
+			this.sparkplugHost.Forget(topic.NodeKey())
+			return this.eventMqttClient.Unsubscribe(subscription)
+		},
+	}
+}
+
+func (this *Connector) unsubscribeSparkplugNodeOperation(topic sparkplug.Topic) UpdateOperation {
+	subscription := topic.NodeSubscription()
+	return UpdateOperation{
+		Action: UpdateActionUnsubscribe,
+		Target: UpdateTargetSparkplugNode,
+		Key:    subscription,
+		apply: func() error {
+			if this.config.Debug {
+				log.Println("DEBUG: remove sparkplug node listener", subscription)
+			}
+			err := this.eventMqttClient.Unsubscribe(subscription)
+			if err != nil {
+				return err
+			}
+			this.sparkplugHost.Forget(topic.NodeKey())
+			return nil
+		},
+		revert: func() error {
+			return this.eventMqttClient.Subscribe(subscription, 2, this.SparkplugHandler)
+		},
+	}
+}
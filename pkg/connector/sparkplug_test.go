@@ -0,0 +1,136 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/sparkplug"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSparkplug(t *testing.T) {
+	descriptions := []model.TopicDescription{
+		{EventTopic: "spBv1.0/plant/DDATA/gw1/plc1", PayloadFormat: sparkplug.Format, SparkplugMetrics: []string{"temperature"}, DeviceLocalId: "plc1", DeviceName: "plc 1", ServiceLocalId: "temperature", DeviceTypeId: "dt"},
+		{EventTopic: "spBv1.0/plant/DDATA/gw1/plc1", PayloadFormat: sparkplug.Format, SparkplugMetrics: []string{"state/*"}, DeviceLocalId: "plc1", DeviceName: "plc 1", ServiceLocalId: "state", DeviceTypeId: "dt"},
+		{CmdTopic: "spBv1.0/plant/DCMD/gw1/plc1", PayloadFormat: sparkplug.Format, DeviceLocalId: "plc1", DeviceName: "plc 1", ServiceLocalId: "set", DeviceTypeId: "dt"},
+	}
+	mqttClient := &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+	conn, err := NewWithFactories(context.Background(), configuration.Config{}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		return descriptions, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return mqttClient, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.updateTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sortedKeys(mqttClient.subscriptions), []string{"spBv1.0/plant/+/gw1/#"}) {
+		t.Error("unexpected subscriptions", sortedKeys(mqttClient.subscriptions))
+	}
+	if _, ok := mqttClient.getPublished("spBv1.0/plant/NCMD/gw1"); !ok {
+		t.Error("missing rebirth request")
+	}
+
+	waitForState := func(t *testing.T, expected mgw.State) {
+		t.Helper()
+		for i := 0; i < 50; i++ {
+			mgwClient.mux.Lock()
+			state := mgwClient.devices["plc1"]
+			mgwClient.mux.Unlock()
+			if state == string(expected) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Error("expected device state", expected)
+	}
+	waitForEvent := func(t *testing.T, serviceId string, expected string) {
+		t.Helper()
+		var received []byte
+		for i := 0; i < 50 && string(received) != expected; i++ {
+			time.Sleep(10 * time.Millisecond)
+			received, _ = mgwClient.getEvent("plc1", serviceId)
+		}
+		if string(received) != expected {
+			t.Error(serviceId, string(received), expected)
+		}
+	}
+
+	conn.SparkplugHandler("spBv1.0/plant/NBIRTH/gw1", false, sparkplug.Marshal(sparkplug.Payload{Seq: 0, HasSeq: true, Metrics: []sparkplug.Metric{
+		{Name: sparkplug.MetricBdSeq, DataType: sparkplug.Int64, RawValue: uint64(1)},
+	}}))
+	conn.SparkplugHandler("spBv1.0/plant/DBIRTH/gw1/plc1", false, sparkplug.Marshal(sparkplug.Payload{Seq: 1, HasSeq: true, Metrics: []sparkplug.Metric{
+		{Name: "temperature", Alias: 1, HasAlias: true, DataType: sparkplug.Float, RawValue: float32(20)},
+		{Name: "state/mode", Alias: 2, HasAlias: true, DataType: sparkplug.String, RawValue: "auto"},
+		{Name: "level", Alias: 3, HasAlias: true, DataType: sparkplug.UInt8, RawValue: uint32(1)},
+	}}))
+	waitForState(t, mgw.Online)
+	waitForEvent(t, "state", `{"state/mode":"auto"}`)
+
+	conn.SparkplugHandler("spBv1.0/plant/DDATA/gw1/plc1", false, sparkplug.Marshal(sparkplug.Payload{Seq: 2, HasSeq: true, Metrics: []sparkplug.Metric{
+		{Alias: 1, HasAlias: true, RawValue: float32(21.5)},
+	}}))
+	waitForEvent(t, "temperature", `{"temperature":21.5}`)
+
+	conn.CommandHandler("plc1", "set", mgw.Command{CommandId: "c1", Data: `{"level":5}`})
+	var published []byte
+	for i := 0; i < 50 && published == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		published, _ = mqttClient.getPublished("spBv1.0/plant/DCMD/gw1/plc1")
+	}
+	command, err := sparkplug.Unmarshal(published)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(command.Metrics) != 1 || command.Metrics[0].Name != "level" || command.Metrics[0].DataType != sparkplug.UInt8 || command.Metrics[0].Value() != uint64(5) {
+		t.Errorf("%#v", command)
+	}
+
+	conn.SparkplugHandler("spBv1.0/plant/NDEATH/gw1", false, sparkplug.Marshal(sparkplug.Payload{Metrics: []sparkplug.Metric{
+		{Name: sparkplug.MetricBdSeq, DataType: sparkplug.Int64, RawValue: uint64(0)},
+	}}))
+	time.Sleep(50 * time.Millisecond)
+	waitForState(t, mgw.Online)
+	conn.SparkplugHandler("spBv1.0/plant/NDEATH/gw1", false, sparkplug.Marshal(sparkplug.Payload{Metrics: []sparkplug.Metric{
+		{Name: sparkplug.MetricBdSeq, DataType: sparkplug.Int64, RawValue: uint64(1)},
+	}}))
+	waitForState(t, mgw.Offline)
+
+	descriptions = nil
+	err = conn.updateTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mqttClient.subscriptions) != 0 {
+		t.Error("unexpected subscriptions", sortedKeys(mqttClient.subscriptions))
+	}
+	if _, ok := conn.statusStates.Get("plc1"); ok {
+		t.Error("unexpected device status after removal")
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"log"
+	"strings"
+)
+
+var DefaultStatusOnlineValues = []string{"online", "true", "1"}
+var DefaultStatusOfflineValues = []string{"offline", "false", "0"}
+
+func (this *Connector) StatusHandler(topic string, retained bool, payload []byte) {
+	desc, ok := this.statusTopicRegister.Get(topic)
+	if !ok {
+		return
+	}
+	if this.config.Debug {
+		log.Println("DEBUG: receive status", topic, string(payload))
+	}
+	if len(payload) == 0 {
+		//deleted retained message
+		return
+	}
+	state, err := parseStatus(desc, payload)
+	if err != nil {
+		log.Println("WARNING: unable to read device status", topic, err)
+		return
+	}
+	this.setDeviceStatus(desc, state)
+}
+
+// setDeviceStatus stores the state reported by the device and sends the resulting device state to the mgw if it changed
+func (this *Connector) setDeviceStatus(desc TopicDescription, state mgw.State) {
+	deviceId := desc.GetLocalDeviceId()
+	before := this.getDeviceState(desc)
+	this.statusStates.Set(deviceId, state)
+	after := this.getDeviceState(desc)
+	if before == after {
+		return
+	}
+	log.Println("device status changed", deviceId, after)
+	this.metrics.DeviceStateTransitions.WithLabelValues(string(after)).Inc()
+	go func() {
+		err := this.mgwClient.SetDevice(deviceId, desc.GetDeviceName(), desc.GetDeviceTypeId(), string(after))
+		if err != nil {
+			log.Println("ERROR: unable to send device info to mgw", err)
+			this.mgwClient.SendClientError("unable to send device info to mgw: " + err.Error())
+		}
+	}()
+}
+
+func parseStatus(desc TopicDescription, payload []byte) (state mgw.State, err error) {
+	value := strings.TrimSpace(string(payload))
+	if path := desc.GetStatusPath(); path != "" {
+		var msg interface{}
+		err = json.Unmarshal(payload, &msg)
+		if err != nil {
+			return state, fmt.Errorf("payload is not valid json: %w", err)
+		}
+		field, ok := getJsonPath(msg, path)
+		if !ok || field == nil {
+			return state, errors.New("missing status at " + path)
+		}
+		value = fmt.Sprint(field)
+	} else {
+		value = strings.Trim(value, "\"")
+	}
+	onlineValues := desc.GetStatusOnlineValues()
+	if len(onlineValues) == 0 {
+		onlineValues = DefaultStatusOnlineValues
+	}
+	offlineValues := desc.GetStatusOfflineValues()
+	if len(offlineValues) == 0 {
+		offlineValues = DefaultStatusOfflineValues
+	}
+	for _, online := range onlineValues {
+		if strings.EqualFold(value, online) {
+			return mgw.Online, nil
+		}
+	}
+	for _, offline := range offlineValues {
+		if strings.EqualFold(value, offline) {
+			return mgw.Offline, nil
+		}
+	}
+	return state, errors.New("unknown status value " + value)
+}
+
+func (this *Connector) addStatus(topicDesc TopicDescription) (err error) {
+	if this.config.Debug {
+		log.Println("DEBUG: add status listener", topicDesc)
+	}
+	statusTopic := topicDesc.GetStatusTopic()
+	this.statusTopicRegister.Set(statusTopic, topicDesc)
+	err = this.eventMqttClient.Subscribe(statusTopic, 2, this.StatusHandler)
+	if err != nil {
+		this.statusTopicRegister.Remove(statusTopic)
+		return err
+	}
+	return nil
+}
+
+func (this *Connector) updateStatus(topicDesc TopicDescription) error {
+	if this.config.Debug {
+		log.Println("DEBUG: update status listener", topicDesc)
+	}
+	err := this.removeStatus(topicDesc.GetStatusTopic())
+	if err != nil {
+		return err
+	}
+	return this.addStatus(topicDesc)
+}
+
+func (this *Connector) removeStatus(topic string) (err error) {
+	if this.config.Debug {
+		log.Println("DEBUG: remove status listener", topic)
+	}
+	desc, exists := this.statusTopicRegister.Get(topic)
+	if !exists {
+		return nil
+	}
+	err = this.eventMqttClient.Unsubscribe(topic)
+	if err != nil {
+		return err
+	}
+	this.statusTopicRegister.Remove(topic)
+	this.statusStates.Remove(desc.GetLocalDeviceId())
+	return nil
+}
+
+// getStatusDescriptions resolves the status topics of all descriptions to the devices they refer to
+func (this *Connector) getStatusDescriptions(statuses []TopicDescription) (result map[string]TopicDescription) {
+	result = map[string]TopicDescription{}
+	for _, topic := range statuses {
+		for _, device := range this.getDeviceDescriptions(topic) {
+			result[device.GetStatusTopic()] = device
+		}
+	}
+	return result
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	type testCase struct {
+		desc    model.TopicDescription
+		payload string
+		state   mgw.State
+		err     bool
+	}
+	for _, tc := range []testCase{
+		{desc: model.TopicDescription{}, payload: "Online", state: mgw.Online},
+		{desc: model.TopicDescription{}, payload: "Offline", state: mgw.Offline},
+		{desc: model.TopicDescription{}, payload: " true\n", state: mgw.Online},
+		{desc: model.TopicDescription{}, payload: "0", state: mgw.Offline},
+		{desc: model.TopicDescription{}, payload: `"online"`, state: mgw.Online},
+		{desc: model.TopicDescription{}, payload: "unknown", err: true},
+		{desc: model.TopicDescription{StatusPath: "state"}, payload: `{"state":"offline"}`, state: mgw.Offline},
+		{desc: model.TopicDescription{StatusPath: "a.b"}, payload: `{"a":{"b":true}}`, state: mgw.Online},
+		{desc: model.TopicDescription{StatusPath: "state"}, payload: `{"foo":"online"}`, err: true},
+		{desc: model.TopicDescription{StatusPath: "state"}, payload: `online`, err: true},
+		{desc: model.TopicDescription{StatusOnlineValues: []string{"up"}, StatusOfflineValues: []string{"down"}}, payload: "UP", state: mgw.Online},
+		{desc: model.TopicDescription{StatusOnlineValues: []string{"up"}, StatusOfflineValues: []string{"down"}}, payload: "down", state: mgw.Offline},
+		{desc: model.TopicDescription{StatusOnlineValues: []string{"up"}}, payload: "online", err: true},
+		{desc: model.TopicDescription{StatusOnlineValues: []string{"up"}}, payload: "offline", state: mgw.Offline},
+	} {
+		state, err := parseStatus(tc.desc, []byte(tc.payload))
+		if (err != nil) != tc.err {
+			t.Error(tc.desc, tc.payload, err)
+			continue
+		}
+		if state != tc.state {
+			t.Error(tc.desc, tc.payload, state, tc.state)
+		}
+	}
+}
@@ -18,7 +18,9 @@ package connector
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"slices"
 	"strconv"
 	"strings"
@@ -26,15 +28,114 @@ import (
 
 const TransformerJsonUnwrapInput = "json-unwrap-input"
 const TransformerJsonUnwrapOutput = "json-unwrap-output"
+const TransformerJsonReshapeInput = "json-reshape-input"
+const TransformerJsonReshapeOutput = "json-reshape-output"
+const TransformerJsonWrapInput = "json-wrap-input"
+const TransformerJsonWrapOutput = "json-wrap-output"
+const TransformerValueMap = "value-map"
+const TransformerExpression = "expression"
+const TransformerBinaryLayout = "binary-layout"
 
-func (this *Connector) handleTransformations(desc TopicDescription, kind string, payload []byte) ([]byte, error) {
-	switch kind {
-	case TransformerJsonUnwrapInput:
-		return this.handleJsonUnwrapTransformations(desc.GetTransformations(TransformerJsonUnwrapInput), payload)
-	case TransformerJsonUnwrapOutput:
-		return this.handleJsonUnwrapTransformations(desc.GetTransformations(TransformerJsonUnwrapOutput), payload)
+var transformerKinds = []string{
+	TransformerJsonUnwrapInput,
+	TransformerJsonUnwrapOutput,
+	TransformerJsonReshapeInput,
+	TransformerJsonReshapeOutput,
+	TransformerJsonWrapInput,
+	TransformerJsonWrapOutput,
+	TransformerValueMap,
+	TransformerExpression,
+	TransformerBinaryLayout,
+}
+
+// checkTransformationDetails parses the kind specific details of the transformations,
+// to report invalid details while loading the descriptions instead of on every message
+func checkTransformationDetails(desc TopicDescription) error {
+	valueMaps := desc.GetTransformationDetails(TransformerValueMap)
+	for _, path := range slices.Sorted(maps.Keys(valueMaps)) {
+		if _, err := parseValueMap(valueMaps[path]); err != nil {
+			return fmt.Errorf("invalid value-map for %v: %w", path, err)
+		}
 	}
-	return payload, nil
+	expressions := desc.GetTransformationDetails(TransformerExpression)
+	for _, path := range slices.Sorted(maps.Keys(expressions)) {
+		if _, err := parseExpressionDetails(expressions[path]); err != nil {
+			return fmt.Errorf("invalid expression for %v: %w", path, err)
+		}
+	}
+	_, err := parseBinaryLayout(desc.GetTransformationDetails(TransformerBinaryLayout))
+	return err
+}
+
+// equalTransformations compares the transformations of the descriptions as they are used by the transformer
+func equalTransformations(old TopicDescription, topic TopicDescription) bool {
+	if old.HasTransformations() != topic.HasTransformations() {
+		return false
+	}
+	for _, kind := range transformerKinds {
+		if !slices.Equal(old.GetTransformations(kind), topic.GetTransformations(kind)) ||
+			!maps.Equal(old.GetTransformationDetails(kind), topic.GetTransformationDetails(kind)) {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	wrapTypeAuto   = ""
+	wrapTypeNumber = "number"
+	wrapTypeBool   = "bool"
+	wrapTypeString = "string"
+)
+
+// handleInputTransformations transforms command payloads from the mgw before they are published to the device
+func (this *Connector) handleInputTransformations(desc TopicDescription, payload []byte) (result []byte, err error) {
+	result, err = this.handleJsonUnwrapTransformations(desc.GetTransformations(TransformerJsonUnwrapInput), payload)
+	if err != nil {
+		return nil, err
+	}
+	result, err = this.handleJsonReshapeTransformations(desc.GetTransformationDetails(TransformerJsonReshapeInput), result)
+	if err != nil {
+		return nil, err
+	}
+	result, err = this.handleExpressionTransformations(desc.GetTransformationDetails(TransformerExpression), result, true)
+	if err != nil {
+		return nil, err
+	}
+	result, err = this.handleValueMapTransformations(desc.GetTransformationDetails(TransformerValueMap), result, true)
+	if err != nil {
+		return nil, err
+	}
+	result, err = this.handleJsonWrapInputTransformations(desc.GetTransformationDetails(TransformerJsonWrapInput), result)
+	if err != nil {
+		return nil, err
+	}
+	return this.handleBinaryLayoutInputTransformations(desc.GetTransformationDetails(TransformerBinaryLayout), result)
+}
+
+// handleOutputTransformations transforms event and response payloads of the device before they are sent to the mgw
+func (this *Connector) handleOutputTransformations(desc TopicDescription, payload []byte) (result []byte, err error) {
+	result, err = this.handleBinaryLayoutOutputTransformations(desc.GetTransformationDetails(TransformerBinaryLayout), payload)
+	if err != nil {
+		return nil, err
+	}
+	result, err = this.handleJsonWrapOutputTransformations(desc.GetTransformationDetails(TransformerJsonWrapOutput), result)
+	if err != nil {
+		return nil, err
+	}
+	result, err = this.handleJsonUnwrapTransformations(desc.GetTransformations(TransformerJsonUnwrapOutput), result)
+	if err != nil {
+		return nil, err
+	}
+	result, err = this.handleValueMapTransformations(desc.GetTransformationDetails(TransformerValueMap), result, false)
+	if err != nil {
+		return nil, err
+	}
+	result, err = this.handleExpressionTransformations(desc.GetTransformationDetails(TransformerExpression), result, false)
+	if err != nil {
+		return nil, err
+	}
+	return this.handleJsonReshapeTransformations(desc.GetTransformationDetails(TransformerJsonReshapeOutput), result)
 }
 
 func (this *Connector) handleJsonUnwrapTransformations(paths []string, payload []byte) ([]byte, error) {
@@ -53,6 +154,122 @@ func (this *Connector) handleJsonUnwrapTransformations(paths []string, payload [
 	return json.Marshal(value)
 }
 
+// handleJsonWrapOutputTransformations wraps a raw device payload (e.g. `23.5` or `ON`) into a json object;
+// paths maps the dot separated target path ("" for the whole document) to the type of the value (number, bool, string or empty to keep valid json values)
+func (this *Connector) handleJsonWrapOutputTransformations(paths map[string]string, payload []byte) ([]byte, error) {
+	if len(paths) == 0 {
+		return payload, nil
+	}
+	targets := []string{}
+	for target := range paths {
+		targets = append(targets, target)
+	}
+	slices.Sort(targets)
+	var result interface{}
+	for _, target := range targets {
+		value, err := parseWrapValue(paths[target], payload)
+		if err != nil {
+			return nil, err
+		}
+		if target == "" {
+			result = value
+			continue
+		}
+		result, err = setJsonPath(result, target, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(result)
+}
+
+// handleJsonWrapInputTransformations is the inverse of handleJsonWrapOutputTransformations:
+// the value at the path is sent as raw payload (strings without quotes)
+func (this *Connector) handleJsonWrapInputTransformations(paths map[string]string, payload []byte) ([]byte, error) {
+	if len(paths) == 0 {
+		return payload, nil
+	}
+	if len(paths) > 1 {
+		return nil, errors.New("json-wrap-input expects exactly one path")
+	}
+	var value interface{}
+	err := json.Unmarshal(payload, &value)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not valid json: %w", err)
+	}
+	for path, valueType := range paths {
+		if path != "" {
+			var ok bool
+			value, ok = getJsonPath(value, path)
+			if !ok {
+				return nil, fmt.Errorf("path %v not found in payload", path)
+			}
+		}
+		return formatWrapValue(valueType, value)
+	}
+	return payload, nil
+}
+
+func parseWrapValue(valueType string, payload []byte) (interface{}, error) {
+	text := strings.TrimSpace(string(payload))
+	switch valueType {
+	case wrapTypeNumber:
+		result, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("payload %q is not a number", text)
+		}
+		return result, nil
+	case wrapTypeBool:
+		result, err := strconv.ParseBool(strings.ToLower(text))
+		if err != nil {
+			return nil, fmt.Errorf("payload %q is not a bool", text)
+		}
+		return result, nil
+	case wrapTypeString:
+		return string(payload), nil
+	case wrapTypeAuto:
+		var result interface{}
+		if json.Unmarshal(payload, &result) == nil {
+			return result, nil
+		}
+		return string(payload), nil
+	default:
+		return nil, fmt.Errorf("unknown json-wrap type %v", valueType)
+	}
+}
+
+func formatWrapValue(valueType string, value interface{}) ([]byte, error) {
+	switch valueType {
+	case wrapTypeNumber:
+		switch v := value.(type) {
+		case float64:
+			return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+		case string:
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return []byte(v), nil
+			}
+		}
+		return nil, fmt.Errorf("value %v is not a number", value)
+	case wrapTypeBool:
+		switch v := value.(type) {
+		case bool:
+			return []byte(strconv.FormatBool(v)), nil
+		case string:
+			if b, err := strconv.ParseBool(strings.ToLower(v)); err == nil {
+				return []byte(strconv.FormatBool(b)), nil
+			}
+		}
+		return nil, fmt.Errorf("value %v is not a bool", value)
+	case wrapTypeString, wrapTypeAuto:
+		if v, ok := value.(string); ok {
+			return []byte(v), nil
+		}
+		return json.Marshal(value)
+	default:
+		return nil, fmt.Errorf("unknown json-wrap type %v", valueType)
+	}
+}
+
 func recursiveJsonUnwrap(value interface{}, paths []string, currentPath []string) (interface{}, error) {
 	var err error
 	switch v := value.(type) {
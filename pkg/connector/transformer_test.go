@@ -0,0 +1,531 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJsonReshape(t *testing.T) {
+	payload := `{
+		"id": "sensor_1",
+		"data": {"temperature": 21.5, "humidity": 40, "unit": "°C"},
+		"readings": [{"type": "temp", "value": 21.5}, {"type": "hum", "value": 40}, {"type": "temp", "value": 22}],
+		"with.dot": true
+	}`
+	cases := []struct {
+		name     string
+		mappings map[string]string
+		expected string
+		err      bool
+	}{
+		{name: "select and rename", mappings: map[string]string{"temp": "$.data.temperature", "device": "id"}, expected: `{"device":"sensor_1","temp":21.5}`},
+		{name: "nest", mappings: map[string]string{"value.temp": "data.temperature", "value.unit": "$.data.unit"}, expected: `{"value":{"temp":21.5,"unit":"°C"}}`},
+		{name: "flatten", mappings: map[string]string{"": "$.data"}, expected: `{"humidity":40,"temperature":21.5,"unit":"°C"}`},
+		{name: "root and field", mappings: map[string]string{"": "$.data", "id": "$.id"}, expected: `{"humidity":40,"id":"sensor_1","temperature":21.5,"unit":"°C"}`},
+		{name: "scalar", mappings: map[string]string{"": "$.data.humidity"}, expected: `40`},
+		{name: "array index", mappings: map[string]string{"first": "$.readings[0].value", "last": "$.readings[-1].type"}, expected: `{"first":21.5,"last":"temp"}`},
+		{name: "wildcard", mappings: map[string]string{"values": "$.readings[*].value"}, expected: `{"values":[21.5,40,22]}`},
+		{name: "object wildcard", mappings: map[string]string{"values": "$.data.*"}, expected: `{"values":[40,21.5,"°C"]}`},
+		{name: "filter", mappings: map[string]string{"temps": "$.readings[?(@.type == 'temp')].value"}, expected: `{"temps":[21.5,22]}`},
+		{name: "numeric filter", mappings: map[string]string{"high": "$.readings[?(@.value>21.5)].type"}, expected: `{"high":["hum","temp"]}`},
+		{name: "existence filter", mappings: map[string]string{"all": "$.readings[?(@.type)].type"}, expected: `{"all":["temp","hum","temp"]}`},
+		{name: "quoted field", mappings: map[string]string{"flag": "$['with.dot']"}, expected: `{"flag":true}`},
+		{name: "missing source", mappings: map[string]string{"temp": "$.data.temperature", "foo": "$.foo.bar", "bar": "$.readings[5]"}, expected: `{"temp":21.5}`},
+		{name: "nothing found", mappings: map[string]string{"foo": "$.foo"}, expected: `{}`},
+		{name: "invalid expression", mappings: map[string]string{"foo": "$.readings[?(@.type == 'temp']"}, err: true},
+		{name: "invalid index", mappings: map[string]string{"foo": "$.readings[a]"}, err: true},
+		{name: "invalid target", mappings: map[string]string{"": "$.id", "foo": "$.id"}, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, err := (&Connector{}).handleJsonReshapeTransformations(c.mappings, []byte(payload))
+			if c.err {
+				if err == nil {
+					t.Error("expected error", string(result))
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(result) != c.expected {
+				t.Error(string(result), c.expected)
+			}
+		})
+	}
+}
+
+func TestTransformationPipeline(t *testing.T) {
+	desc := model.TopicDescription{
+		Transformations: []model.Transformation{
+			{Transformation: TransformerJsonUnwrapOutput, Path: "payload"},
+			{Transformation: TransformerJsonReshapeOutput, Path: "value", Details: "$.payload.v"},
+			{Transformation: TransformerJsonReshapeInput, Path: "set", Details: "$.value"},
+		},
+	}
+	scalar := model.TopicDescription{
+		Transformations: []model.Transformation{
+			{Transformation: TransformerJsonWrapOutput, Path: "value", Details: "number"},
+			{Transformation: TransformerJsonReshapeOutput, Path: "temperature", Details: "$.value"},
+			{Transformation: TransformerJsonReshapeInput, Path: "value", Details: "$.on"},
+			{Transformation: TransformerJsonWrapInput, Path: "value", Details: "bool"},
+		},
+	}
+	switchable := model.TopicDescription{
+		Transformations: []model.Transformation{
+			{Transformation: TransformerJsonWrapOutput, Path: "value", Details: "string"},
+			{Transformation: TransformerValueMap, Path: "value", Details: `{"map": {"ON": true, "OFF": false}}`},
+			{Transformation: TransformerJsonWrapInput, Path: "value", Details: "string"},
+		},
+	}
+	frame := model.TopicDescription{
+		Transformations: []model.Transformation{
+			{Transformation: TransformerBinaryLayout, Path: "temp", Details: `{"offset": 0, "length": 2, "type": "int", "scale": 0.1}`},
+			{Transformation: TransformerBinaryLayout, Path: "mode", Details: `{"offset": 2, "type": "uint"}`},
+			{Transformation: TransformerValueMap, Path: "mode", Details: `{"map": {"0": "off", "1": "heat"}}`},
+			{Transformation: TransformerJsonReshapeInput, Path: "mode", Details: "$.mode"},
+		},
+	}
+	conn := &Connector{}
+
+	result, err := conn.handleOutputTransformations(desc, []byte(`{"payload":"{\"v\":13}"}`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(result) != `{"value":13}` {
+		t.Error(string(result))
+	}
+
+	result, err = conn.handleInputTransformations(desc, []byte(`{"value":true}`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(result) != `{"set":true}` {
+		t.Error(string(result))
+	}
+
+	_, err = conn.handleOutputTransformations(desc, []byte(`not json`))
+	if err == nil {
+		t.Error("expected error")
+	}
+
+	result, err = conn.handleOutputTransformations(scalar, []byte(`21.5`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(result) != `{"temperature":21.5}` {
+		t.Error(string(result))
+	}
+
+	result, err = conn.handleInputTransformations(scalar, []byte(`{"on":true}`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(result) != `true` {
+		t.Error(string(result))
+	}
+	result, err = conn.handleOutputTransformations(switchable, []byte(`OFF`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(result) != `{"value":false}` {
+		t.Error(string(result))
+	}
+
+	result, err = conn.handleInputTransformations(switchable, []byte(`{"value":true}`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(result) != `ON` {
+		t.Error(string(result))
+	}
+
+	result, err = conn.handleOutputTransformations(frame, []byte{0x00, 0xeb, 0x01})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(result) != `{"mode":"heat","temp":23.5}` {
+		t.Error(string(result))
+	}
+
+	result, err = conn.handleInputTransformations(frame, []byte(`{"mode":"heat"}`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(result, []byte{0x00, 0x00, 0x01}) {
+		t.Error(result)
+	}
+}
+
+func TestJsonWrap(t *testing.T) {
+	output := []struct {
+		paths    map[string]string
+		payload  string
+		expected string
+		err      bool
+	}{
+		{paths: map[string]string{"value": "number"}, payload: "23.5", expected: `{"value":23.5}`},
+		{paths: map[string]string{"value": "number"}, payload: " 23.5\n", expected: `{"value":23.5}`},
+		{paths: map[string]string{"value": "number"}, payload: "ON", err: true},
+		{paths: map[string]string{"value": "bool"}, payload: "TRUE", expected: `{"value":true}`},
+		{paths: map[string]string{"value": "bool"}, payload: "0", expected: `{"value":false}`},
+		{paths: map[string]string{"value": "bool"}, payload: "ON", err: true},
+		{paths: map[string]string{"value": "string"}, payload: "42", expected: `{"value":"42"}`},
+		{paths: map[string]string{"state.value": ""}, payload: "ON", expected: `{"state":{"value":"ON"}}`},
+		{paths: map[string]string{"value": ""}, payload: "42", expected: `{"value":42}`},
+		{paths: map[string]string{"value": "", "raw": "string"}, payload: "42", expected: `{"raw":"42","value":42}`},
+		{paths: map[string]string{"": "number"}, payload: "42", expected: `42`},
+		{paths: map[string]string{"value": "foo"}, payload: "42", err: true},
+	}
+	for _, c := range output {
+		result, err := (&Connector{}).handleJsonWrapOutputTransformations(c.paths, []byte(c.payload))
+		if c.err {
+			if err == nil {
+				t.Error("expected error", c.paths, c.payload, string(result))
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(c.paths, c.payload, err)
+			continue
+		}
+		if string(result) != c.expected {
+			t.Error(c.paths, c.payload, string(result), c.expected)
+		}
+	}
+
+	input := []struct {
+		paths    map[string]string
+		payload  string
+		expected string
+		err      bool
+	}{
+		{paths: map[string]string{"value": "number"}, payload: `{"value":23.5}`, expected: "23.5"},
+		{paths: map[string]string{"value": "number"}, payload: `{"value":"23.5"}`, expected: "23.5"},
+		{paths: map[string]string{"value": "number"}, payload: `{"value":"on"}`, err: true},
+		{paths: map[string]string{"value": "bool"}, payload: `{"value":true}`, expected: "true"},
+		{paths: map[string]string{"value": "bool"}, payload: `{"value":"False"}`, expected: "false"},
+		{paths: map[string]string{"value": "string"}, payload: `{"value":"ON"}`, expected: "ON"},
+		{paths: map[string]string{"value": ""}, payload: `{"value":{"a":1}}`, expected: `{"a":1}`},
+		{paths: map[string]string{"state.value": ""}, payload: `{"state":{"value":"ON"}}`, expected: "ON"},
+		{paths: map[string]string{"": ""}, payload: `"ON"`, expected: "ON"},
+		{paths: map[string]string{"value": ""}, payload: `{"foo":1}`, err: true},
+		{paths: map[string]string{"value": "", "foo": ""}, payload: `{"value":1}`, err: true},
+		{paths: map[string]string{"value": ""}, payload: `ON`, err: true},
+	}
+	for _, c := range input {
+		result, err := (&Connector{}).handleJsonWrapInputTransformations(c.paths, []byte(c.payload))
+		if c.err {
+			if err == nil {
+				t.Error("expected error", c.paths, c.payload, string(result))
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(c.paths, c.payload, err)
+			continue
+		}
+		if string(result) != c.expected {
+			t.Error(c.paths, c.payload, string(result), c.expected)
+		}
+	}
+}
+
+func TestValueMap(t *testing.T) {
+	onOff := `{"map": {"ON": true, "OFF": false}, "unknown": "error"}`
+	numeric := `{"map": {"1": "open", "0": "closed"}}`
+	cases := []struct {
+		maps     map[string]string
+		payload  string
+		reverse  bool
+		expected string
+		err      bool
+	}{
+		{maps: map[string]string{"": onOff}, payload: `ON`, expected: `true`},
+		{maps: map[string]string{"": onOff}, payload: `"OFF"`, expected: `false`},
+		{maps: map[string]string{"": onOff}, payload: `TOGGLE`, err: true},
+		{maps: map[string]string{"": onOff}, payload: `true`, reverse: true, expected: `ON`},
+		{maps: map[string]string{"": onOff}, payload: `"foo"`, reverse: true, err: true},
+		{maps: map[string]string{"state": onOff}, payload: `{"state":"ON","power":12}`, expected: `{"power":12,"state":true}`},
+		{maps: map[string]string{"state": onOff}, payload: `{"power":12}`, expected: `{"power":12}`},
+		{maps: map[string]string{"state": onOff}, payload: `{"state":false}`, reverse: true, expected: `{"state":"OFF"}`},
+		{maps: map[string]string{"state": onOff}, payload: `{"state":"TOGGLE"}`, err: true},
+		{maps: map[string]string{"window": numeric}, payload: `{"window":1}`, expected: `{"window":"open"}`},
+		{maps: map[string]string{"window": numeric}, payload: `{"window":"0"}`, expected: `{"window":"closed"}`},
+		{maps: map[string]string{"window": numeric}, payload: `{"window":2}`, expected: `{"window":2}`},
+		{maps: map[string]string{"window": numeric}, payload: `{"window":"closed"}`, reverse: true, expected: `{"window":0}`},
+		{maps: map[string]string{"window": numeric}, payload: `{"window":"tilted"}`, reverse: true, expected: `{"window":"tilted"}`},
+		{maps: map[string]string{"code": `{"map": {"01": "a", "2": "b"}, "device_type": "string"}`}, payload: `{"code":"b"}`, reverse: true, expected: `{"code":"2"}`},
+		{maps: map[string]string{"code": `{"map": {"01": "a", "2": "b"}, "device_type": "number"}`}, payload: `{"code":"a"}`, reverse: true, expected: `{"code":1}`},
+		{maps: map[string]string{"on": `{"map": {"true": "on", "false": "off"}, "device_type": "string"}`}, payload: `{"on":"on"}`, reverse: true, expected: `{"on":"true"}`},
+		{maps: map[string]string{"on": `{"map": {"1": "on", "0": "off"}, "device_type": "bool"}`}, payload: `{"on":"on"}`, reverse: true, expected: `{"on":true}`},
+		{maps: map[string]string{"on": `{"map": {"yes": "on"}, "device_type": "bool"}`}, payload: `{"on":"on"}`, reverse: true, err: true},
+		{maps: map[string]string{"on": `{"map": {"1": "on"}, "device_type": "foo"}`}, payload: `{"on":"on"}`, reverse: true, err: true},
+		{maps: map[string]string{"a": numeric, "b.c": onOff}, payload: `{"a":0,"b":{"c":"OFF"}}`, expected: `{"a":"closed","b":{"c":false}}`},
+		{maps: map[string]string{"state": onOff}, payload: `ON`, err: true},
+		{maps: map[string]string{"state": `{"map": {}, "unknown": "foo"}`}, payload: `{"state":"ON"}`, err: true},
+		{maps: map[string]string{"state": `not json`}, payload: `{"state":"ON"}`, err: true},
+	}
+	for _, c := range cases {
+		result, err := (&Connector{}).handleValueMapTransformations(c.maps, []byte(c.payload), c.reverse)
+		if c.err {
+			if err == nil {
+				t.Error("expected error", c.maps, c.payload, c.reverse, string(result))
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(c.maps, c.payload, c.reverse, err)
+			continue
+		}
+		if string(result) != c.expected {
+			t.Error(c.maps, c.payload, c.reverse, string(result), c.expected)
+		}
+	}
+}
+
+func TestExpression(t *testing.T) {
+	scale := `{"formula": "value / 100", "inverse": "value * 100"}`
+	cases := []struct {
+		expressions map[string]string
+		payload     string
+		inverse     bool
+		expected    string
+		err         bool
+	}{
+		{expressions: map[string]string{"temp": scale}, payload: `{"temp":2351,"id":"a"}`, expected: `{"id":"a","temp":23.51}`},
+		{expressions: map[string]string{"temp": scale}, payload: `{"temp":23.51}`, inverse: true, expected: `{"temp":2351}`},
+		{expressions: map[string]string{"": scale}, payload: `2351`, expected: `23.51`},
+		{expressions: map[string]string{"temp": scale}, payload: `{"id":"a"}`, expected: `{"id":"a"}`},
+		{expressions: map[string]string{"temp": `{"formula": "value / 100"}`}, payload: `{"temp":23.51}`, inverse: true, expected: `{"temp":23.51}`},
+		{expressions: map[string]string{"temp": `{"formula": "round(value * 1.8 + 32, 1)"}`}, payload: `{"temp":21.55}`, expected: `{"temp":70.8}`},
+		{expressions: map[string]string{"a": `{"formula": "floor(value)"}`, "b": `{"formula": "abs(value)"}`}, payload: `{"a":1.5,"b":-2}`, expected: `{"a":1,"b":2}`},
+		{expressions: map[string]string{"on": `{"formula": "value > 0"}`}, payload: `{"on":3}`, expected: `{"on":true}`},
+		{expressions: map[string]string{"temp": scale}, payload: `{"temp":"foo"}`, err: true},
+		{expressions: map[string]string{"temp": `{"formula": "value / 0"}`}, payload: `{"temp":1}`, err: true},
+		{expressions: map[string]string{"temp": `{"formula": "value +"}`}, payload: `{"temp":1}`, err: true},
+		{expressions: map[string]string{"temp": `{"formula": "foo * 2"}`}, payload: `{"temp":1}`, err: true},
+		{expressions: map[string]string{"temp": `not json`}, payload: `{"temp":1}`, err: true},
+		{expressions: map[string]string{"temp": scale}, payload: `not json`, err: true},
+	}
+	for _, c := range cases {
+		result, err := (&Connector{}).handleExpressionTransformations(c.expressions, []byte(c.payload), c.inverse)
+		if c.err {
+			if err == nil {
+				t.Error("expected error", c.expressions, c.payload, c.inverse, string(result))
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(c.expressions, c.payload, c.inverse, err)
+			continue
+		}
+		if string(result) != c.expected {
+			t.Error(c.expressions, c.payload, c.inverse, string(result), c.expected)
+		}
+	}
+	if _, ok := parsedExpressions.Get("value / 100"); !ok {
+		t.Error("expected parsed formula in cache")
+	}
+	if _, ok := parsedExpressions.Get("value +"); ok {
+		t.Error("unexpected invalid formula in cache")
+	}
+}
+
+func TestExpressionDeviceError(t *testing.T) {
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+	conn, err := NewWithFactories(context.Background(), configuration.Config{}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		return []model.TopicDescription{{
+			EventTopic:      "meter/1",
+			DeviceLocalId:   "meter_1",
+			DeviceName:      "meter 1",
+			ServiceLocalId:  "energy",
+			DeviceTypeId:    "dt",
+			Transformations: []model.Transformation{{Transformation: TransformerExpression, Path: "energy", Details: `{"formula": "value / 1000"}`}},
+		}}, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.updateTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.EventHandler("meter/1", false, []byte(`{"energy":"invalid"}`))
+	if !reflect.DeepEqual(mgwClient.deviceErrors, []string{"meter_1"}) {
+		t.Error("expected device error", mgwClient.deviceErrors)
+	}
+}
+
+func TestPayloadFormat(t *testing.T) {
+	mqttClient := &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+	conn, err := NewWithFactories(context.Background(), configuration.Config{}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		return []model.TopicDescription{
+			{
+				EventTopic:      "meter/1",
+				PayloadFormat:   "cbor",
+				DeviceLocalId:   "meter_1",
+				DeviceName:      "meter 1",
+				ServiceLocalId:  "energy",
+				DeviceTypeId:    "dt",
+				Transformations: []model.Transformation{{Transformation: TransformerExpression, Path: "energy", Details: `{"formula": "value / 1000"}`}},
+			},
+			{
+				CmdTopic:       "meter/1/set",
+				PayloadFormat:  "cbor",
+				DeviceLocalId:  "meter_1",
+				DeviceName:     "meter 1",
+				ServiceLocalId: "reset",
+				DeviceTypeId:   "dt",
+			},
+		}, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return mqttClient, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.updateTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//{"energy": 1500}
+	event, _ := hex.DecodeString("a166656e657267791905dc")
+	conn.EventHandler("meter/1", false, event)
+	conn.CommandHandler("meter_1", "reset", mgw.Command{CommandId: "c1", Data: `{"counter":0}`})
+	conn.EventHandler("meter/1", false, []byte(`{"energy":1500}`))
+
+	var received []byte
+	var published []byte
+	for i := 0; i < 50 && (received == nil || published == nil); i++ {
+		time.Sleep(10 * time.Millisecond)
+		received, _ = mgwClient.getEvent("meter_1", "energy")
+		published, _ = mqttClient.getPublished("meter/1/set")
+	}
+	if string(received) != `{"energy":1.5}` {
+		t.Error(string(received))
+	}
+	//{"counter": 0}
+	if hex.EncodeToString(published) != "a167636f756e74657200" {
+		t.Error(hex.EncodeToString(published))
+	}
+	if !reflect.DeepEqual(mgwClient.deviceErrors, []string{"meter_1"}) {
+		t.Error("expected device error for invalid cbor", mgwClient.deviceErrors)
+	}
+}
+
+func TestBinaryLayout(t *testing.T) {
+	layout := map[string]string{
+		"temp":    `{"offset": 0, "length": 2, "type": "int", "scale": 0.1}`,
+		"battery": `{"offset": 2, "type": "uint"}`,
+	}
+	cases := []struct {
+		layout   map[string]string
+		payload  string //hex for events
+		input    bool
+		expected string //hex for commands
+		err      bool
+	}{
+		{layout: layout, payload: "00eb5a", expected: `{"battery":90,"temp":23.5}`},
+		{layout: layout, payload: "ff385a", expected: `{"battery":90,"temp":-20}`},
+		{layout: layout, payload: "00eb", err: true},
+		{layout: map[string]string{"": `{"offset": 0, "type": "uint"}`}, payload: "2a", expected: `42`},
+		{layout: map[string]string{"v": `{"offset": 0, "length": 2, "type": "uint", "endian": "little"}`}, payload: "3412", expected: `{"v":4660}`},
+		{layout: map[string]string{"v": `{"offset": 0, "length": 4, "type": "int", "endian": "little"}`}, payload: "feffffff", expected: `{"v":-2}`},
+		{layout: map[string]string{"v": `{"offset": 0, "length": 3, "type": "uint", "scale": 0.01}`}, payload: "000003", expected: `{"v":0.03}`},
+		{layout: map[string]string{"v": `{"offset": 0, "type": "float"}`}, payload: "41bc0000", expected: `{"v":23.5}`},
+		{layout: map[string]string{"v": `{"offset": 0, "length": 8, "type": "float"}`}, payload: "4037800000000000", expected: `{"v":23.5}`},
+		{layout: map[string]string{"a.on": `{"offset": 0, "type": "bool"}`}, payload: "01", expected: `{"a":{"on":true}}`},
+		{layout: map[string]string{"name": `{"offset": 1, "type": "string"}`}, payload: "0041420000", expected: `{"name":"AB"}`},
+		{layout: map[string]string{"v": `{"offset": 0, "type": "double"}`}, payload: "00", err: true},
+		{layout: map[string]string{"v": `{"offset": 0, "length": 3, "type": "float"}`}, payload: "000000", err: true},
+		{layout: map[string]string{"v": `{"offset": 0, "length": 9, "type": "int"}`}, payload: "000000000000000000", err: true},
+		{layout: map[string]string{"v": `{"offset": 0, "type": "int", "endian": "middle"}`}, payload: "00", err: true},
+
+		{layout: layout, input: true, payload: `{"temp":23.5,"battery":90}`, expected: "00eb5a"},
+		{layout: layout, input: true, payload: `{"temp":-20}`, expected: "ff3800"},
+		{layout: layout, input: true, payload: `{"battery":300}`, err: true},
+		{layout: layout, input: true, payload: `{"battery":-1}`, err: true},
+		{layout: layout, input: true, payload: `{"temp":"warm"}`, err: true},
+		{layout: layout, input: true, payload: `not json`, err: true},
+		{layout: map[string]string{"": `{"offset": 0, "type": "uint"}`}, input: true, payload: `42`, expected: "2a"},
+		{layout: map[string]string{"v": `{"offset": 0, "length": 2, "type": "uint", "endian": "little"}`}, input: true, payload: `{"v":4660}`, expected: "3412"},
+		{layout: map[string]string{"v": `{"offset": 0, "length": 2, "type": "int", "endian": "little"}`}, input: true, payload: `{"v":-2}`, expected: "feff"},
+		{layout: map[string]string{"v": `{"offset": 0, "type": "float"}`}, input: true, payload: `{"v":23.5}`, expected: "41bc0000"},
+		{layout: map[string]string{"on": `{"offset": 1, "type": "bool"}`}, input: true, payload: `{"on":true}`, expected: "0001"},
+		{layout: map[string]string{"name": `{"offset": 1, "type": "string"}`}, input: true, payload: `{"name":"hi"}`, expected: "006869"},
+		{layout: map[string]string{"name": `{"offset": 0, "length": 4, "type": "string"}`}, input: true, payload: `{"name":"hi"}`, expected: "68690000"},
+		{layout: map[string]string{"name": `{"offset": 0, "length": 1, "type": "string"}`}, input: true, payload: `{"name":"hi"}`, err: true},
+	}
+	for _, c := range cases {
+		var result []byte
+		var err error
+		if c.input {
+			result, err = (&Connector{}).handleBinaryLayoutInputTransformations(c.layout, []byte(c.payload))
+		} else {
+			payload, decodeErr := hex.DecodeString(c.payload)
+			if decodeErr != nil {
+				t.Fatal(decodeErr)
+			}
+			result, err = (&Connector{}).handleBinaryLayoutOutputTransformations(c.layout, payload)
+		}
+		if c.err {
+			if err == nil {
+				t.Error("expected error", c.layout, c.payload, c.input, hex.EncodeToString(result))
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(c.layout, c.payload, c.input, err)
+			continue
+		}
+		actual := string(result)
+		if c.input {
+			actual = hex.EncodeToString(result)
+		}
+		if actual != c.expected {
+			t.Error(c.layout, c.payload, c.input, actual, c.expected)
+		}
+	}
+}
@@ -0,0 +1,591 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	UpdateActionSubscribe   = "subscribe"
+	UpdateActionUnsubscribe = "unsubscribe"
+	UpdateActionSet         = "set"
+	UpdateActionRemove      = "remove"
+	UpdateActionListen      = "listen"
+	UpdateActionStopListen  = "stop-listen"
+	UpdateActionSetOffline  = "set-offline"
+)
+
+const (
+	UpdateTargetEvent           = "event"
+	UpdateTargetResponse        = "response"
+	UpdateTargetStatus          = "status"
+	UpdateTargetCommand         = "command"
+	UpdateTargetDevice          = "device"
+	UpdateTargetOrphan          = "orphan"
+	UpdateTargetLearnedDevice   = "learned-device"
+	UpdateTargetLiveness        = "liveness"
+	UpdateTargetDeviceList      = "device-list"
+	UpdateTargetRemovalGrace    = "removal-grace"
+	UpdateTargetSparkplugDevice = "sparkplug-device"
+	UpdateTargetSparkplugNode   = "sparkplug-node"
+)
+
+// UpdateOperation is a single step of an UpdatePlan.
+// apply either succeeds completely or changes nothing; revert restores the state before apply.
+type UpdateOperation struct {
+	Action string
+	Target string
+	Key    string
+	apply  func() error
+	revert func() error
+}
+
+func (this UpdateOperation) String() string {
+	if this.Key == "" {
+		return this.Action + " " + this.Target
+	}
+	return this.Action + " " + this.Target + " " + this.Key
+}
+
+// UpdatePlan is the ordered list of operations needed to get from the current registry state
+// to the state described by the loaded topic descriptions
+type UpdatePlan struct {
+	Operations []UpdateOperation
+}
+
+func (this *UpdatePlan) add(operations ...UpdateOperation) {
+	this.Operations = append(this.Operations, operations...)
+}
+
+func (this UpdatePlan) Strings() (result []string) {
+	for _, operation := range this.Operations {
+		result = append(result, operation.String())
+	}
+	return result
+}
+
+func (this UpdatePlan) String() string {
+	return strings.Join(this.Strings(), "\n")
+}
+
+// planUpdate computes the operations to update the registries, subscriptions and mgw devices.
+// the order matches the requirements of the device management:
+// subscriptions of events and statuses are added after the device registration to ensure evaluation of retained messages
+func (this *Connector) planUpdate(events []TopicDescription, commands []TopicDescription, responses []TopicDescription, statuses []TopicDescription, sparkplugs []TopicDescription) (plan UpdatePlan) {
+	oldDevices := map[string]TopicDescription{}
+	usedDevices := map[string]TopicDescription{}
+
+	// events and usedDevices
+	oldEvents := this.eventTopicRegister.GetAll()
+	usedEvents := map[string]TopicDescription{}
+	addEvents := []TopicDescription{}
+	updateEvents := []TopicDescription{}
+	for _, topic := range events {
+		usedEvents[topic.GetEventTopic()] = topic
+		for _, device := range this.getDeviceDescriptions(topic) {
+			usedDevices[device.GetLocalDeviceId()] = device
+		}
+		if old, ok := oldEvents[topic.GetEventTopic()]; !ok {
+			addEvents = append(addEvents, topic)
+		} else if !EqualTopicDesc(old, topic) {
+			updateEvents = append(updateEvents, topic)
+		}
+	}
+	for key, topic := range oldEvents {
+		for _, device := range this.getDeviceDescriptions(topic) {
+			oldDevices[device.GetLocalDeviceId()] = device
+		}
+		if _, used := usedEvents[key]; !used {
+			plan.add(this.unsubscribeEventOperation(topic))
+		}
+	}
+
+	//forget learned devices of removed wildcard topics
+	for deviceId, learned := range this.learnedDevices.GetAll() {
+		if topic, used := usedEvents[learned.GetEventTopic()]; !used || !capturesDevice(topic) {
+			plan.add(this.removeLearnedDeviceOperation(deviceId, learned))
+		}
+	}
+
+	// responses and usedDevices
+	oldResponses := this.responseTopicRegister.GetAll()
+	usedResponses := map[string]bool{}
+	for _, topic := range responses {
+		usedResponses[topic.GetResponseTopic()] = true
+		usedDevices[topic.GetLocalDeviceId()] = topic
+		if old, ok := oldResponses[topic.GetResponseTopic()]; !ok {
+			plan.add(this.subscribeResponseOperation(topic))
+		} else if !EqualTopicDesc(old, topic) {
+			plan.add(this.unsubscribeResponseOperation(old), this.subscribeResponseOperation(topic))
+		}
+	}
+	for key, topic := range oldResponses {
+		oldDevices[topic.GetLocalDeviceId()] = topic
+		if _, used := usedResponses[key]; !used {
+			plan.add(this.unsubscribeResponseOperation(topic))
+		}
+	}
+
+	// commands and usedDevices
+	oldCommands := this.commandTopicRegister.GetAll()
+	usedCommands := map[string]bool{}
+	for _, topic := range commands {
+		usedDevices[topic.GetLocalDeviceId()] = topic
+		commandId := getCommandIdFromDesc(topic)
+		usedCommands[commandId] = true
+		if old, ok := oldCommands[commandId]; !ok || !reflect.DeepEqual(old, topic) {
+			plan.add(this.setCommandOperation(commandId, topic))
+		}
+	}
+	for key, topic := range oldCommands {
+		oldDevices[topic.GetLocalDeviceId()] = topic
+		if _, used := usedCommands[key]; !used {
+			plan.add(this.removeCommandOperation(key, topic))
+		}
+	}
+
+	// sparkplug devices and usedDevices
+	oldSparkplugs := this.sparkplugRegister.GetAll()
+	usedSparkplugs := map[string][]TopicDescription{}
+	for _, topic := range sparkplugs {
+		usedDevices[topic.GetLocalDeviceId()] = topic
+		key := getSparkplugDeviceKey(topic)
+		usedSparkplugs[key] = append(usedSparkplugs[key], topic)
+	}
+	for key, topics := range usedSparkplugs {
+		if old, ok := oldSparkplugs[key]; !ok || !reflect.DeepEqual(old, topics) {
+			plan.add(this.setSparkplugDeviceOperation(key, topics))
+		}
+	}
+	for key, topics := range oldSparkplugs {
+		for _, topic := range topics {
+			oldDevices[topic.GetLocalDeviceId()] = topic
+		}
+		if _, used := usedSparkplugs[key]; !used {
+			plan.add(this.removeSparkplugDeviceOperation(key, topics))
+		}
+	}
+	oldSparkplugNodes := getSparkplugNodes(oldSparkplugs)
+	usedSparkplugNodes := getSparkplugNodes(usedSparkplugs)
+	for subscription, node := range oldSparkplugNodes {
+		if _, used := usedSparkplugNodes[subscription]; !used {
+			plan.add(this.unsubscribeSparkplugNodeOperation(node))
+		}
+	}
+
+	// usedDevices with devices only described by a status topic
+	oldStatuses := this.statusTopicRegister.GetAll()
+	usedStatuses := this.getStatusDescriptions(statuses)
+	for _, topic := range usedStatuses {
+		if _, used := usedDevices[topic.GetLocalDeviceId()]; !used {
+			usedDevices[topic.GetLocalDeviceId()] = topic
+		}
+	}
+	for key, topic := range oldStatuses {
+		if _, known := oldDevices[topic.GetLocalDeviceId()]; !known {
+			oldDevices[topic.GetLocalDeviceId()] = topic
+		}
+		if _, used := usedStatuses[key]; !used {
+			plan.add(this.unsubscribeStatusOperation(topic))
+		}
+	}
+
+	// devices
+	for id, desc := range this.devices.GetAll() {
+		//devices kept without descriptions because they are quarantined
+		if _, known := oldDevices[id]; !known {
+			oldDevices[id] = desc
+		}
+	}
+	keptDevices := map[string]TopicDescription{}
+	for id, oldDesc := range oldDevices {
+		if _, used := usedDevices[id]; used {
+			continue
+		}
+		if this.quarantinedDevices[id] {
+			//keep the registered device and its command listener until its topic descriptions are fixed
+			keptDevices[id] = oldDesc
+			continue
+		}
+		if this.removalGracePeriod > 0 {
+			device := RegisteredDevice{Name: oldDesc.GetDeviceName(), DeviceTypeId: oldDesc.GetDeviceTypeId()}
+			plan.add(this.startRemovalGraceOperations(id, device)...)
+			plan.add(this.stopListenDeviceOperation(oldDesc))
+		} else {
+			plan.add(this.removeDeviceOperations(oldDesc)...)
+		}
+	}
+	for id, device := range this.registeredDevices {
+		_, used := usedDevices[id]
+		_, known := oldDevices[id]
+		if used || known || this.heldRemovals[id] || this.quarantinedDevices[id] {
+			continue
+		}
+		since, missing := this.missingSince[id]
+		switch {
+		case this.removalGracePeriod <= 0:
+			plan.add(this.removeOrphanOperation(id, device))
+		case !missing:
+			plan.add(this.startRemovalGraceOperations(id, device)...)
+		case time.Since(since) >= this.removalGracePeriod:
+			plan.add(this.removeOrphanOperation(id, device), this.removeMissingOperation(id))
+		}
+	}
+	plan.add(this.setLivenessOperation(events, oldEvents))
+	for id, desc := range usedDevices {
+		old, known := oldDevices[id]
+		plan.add(this.setDeviceOperation(desc, old, known))
+		listening := known && !this.isLearnedOnly(old)
+		switch {
+		case !listening && !this.isLearnedOnly(desc):
+			plan.add(this.listenDeviceOperation(desc))
+		case listening && this.isLearnedOnly(desc):
+			plan.add(this.stopListenDeviceOperation(desc))
+		}
+	}
+	for id, desc := range keptDevices {
+		usedDevices[id] = desc
+	}
+	plan.add(this.setDeviceListOperation(usedDevices))
+
+	// subscriptions after device registration
+	for key, topic := range usedStatuses {
+		if old, ok := oldStatuses[key]; !ok {
+			plan.add(this.subscribeStatusOperation(topic))
+		} else if !EqualTopicDesc(old, topic) {
+			plan.add(this.unsubscribeStatusOperation(old), this.subscribeStatusOperation(topic))
+		}
+	}
+	for _, topic := range addEvents {
+		plan.add(this.subscribeEventOperation(topic))
+	}
+	for _, topic := range updateEvents {
+		plan.add(this.unsubscribeEventOperation(oldEvents[topic.GetEventTopic()]), this.subscribeEventOperation(topic))
+	}
+	for subscription, node := range usedSparkplugNodes {
+		if _, known := oldSparkplugNodes[subscription]; !known {
+			plan.add(this.subscribeSparkplugNodeOperation(node))
+		}
+	}
+	return plan
+}
+
+// applyUpdatePlan applies the operations in order; failed operations are retried with backoff
+// as long as the retries of the update do not exceed update_retry_max_duration.
+// if an operation fails finally, all previously applied operations are reverted in reverse order without retries
+func (this *Connector) applyUpdatePlan(plan UpdatePlan) error {
+	deadline := time.Now().Add(this.updateRetryMaxDuration)
+	for i, operation := range plan.Operations {
+		err := this.retryUpdateOperation(operation.String(), operation.apply, deadline)
+		if err == nil {
+			continue
+		}
+		log.Println("ERROR: update operation failed; revert update plan:", operation, err, "\n"+plan.String())
+		this.metrics.UpdateRollbacks.Inc()
+		for j := i - 1; j >= 0; j-- {
+			applied := plan.Operations[j]
+			revertErr := applied.revert()
+			if revertErr != nil {
+				log.Println("ERROR: unable to revert update operation", applied, revertErr)
+			}
+		}
+		return fmt.Errorf("update operation '%v' failed: %w", operation, err)
+	}
+	return nil
+}
+
+// retryUpdateOperation is called while updateTopicsMux is locked; retries which would end after the deadline are skipped
+// to not block other users of the lock (e.g. learned devices, refresh requests) for longer than update_retry_max_duration
+func (this *Connector) retryUpdateOperation(name string, f func() error, deadline time.Time) (err error) {
+	backoff := this.updateRetryBackoff
+	for retry := 0; ; retry++ {
+		err = f()
+		if err == nil || retry >= this.config.UpdateRetries {
+			return err
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			log.Println("WARNING: update operation failed; retry time of update exceeded", name, err)
+			return err
+		}
+		log.Println("WARNING: update operation failed; retry in", backoff, name, err)
+		this.metrics.UpdateRetries.Inc()
+		time.Sleep(backoff)
+		backoff = backoff * 2
+	}
+}
+
+func (this *Connector) subscribeEventOperation(desc TopicDescription) UpdateOperation {
+	return UpdateOperation{
+		Action: UpdateActionSubscribe,
+		Target: UpdateTargetEvent,
+		Key:    desc.GetEventTopic(),
+		apply: func() error {
+			return this.addEvent(desc)
+		},
+		revert: func() error {
+			return this.removeEvent(desc.GetEventTopic())
+		},
+	}
+}
+
+func (this *Connector) unsubscribeEventOperation(old TopicDescription) UpdateOperation {
+	return UpdateOperation{
+		Action: UpdateActionUnsubscribe,
+		Target: UpdateTargetEvent,
+		Key:    old.GetEventTopic(),
+		apply: func() error {
+			return this.removeEvent(old.GetEventTopic())
+		},
+		revert: func() error {
+			return this.addEvent(old)
+		},
+	}
+}
+
+func (this *Connector) subscribeResponseOperation(desc TopicDescription) UpdateOperation {
+	return UpdateOperation{
+		Action: UpdateActionSubscribe,
+		Target: UpdateTargetResponse,
+		Key:    desc.GetResponseTopic(),
+		apply: func() error {
+			return this.addResponse(desc)
+		},
+		revert: func() error {
+			return this.removeResponse(desc.GetResponseTopic())
+		},
+	}
+}
+
+func (this *Connector) unsubscribeResponseOperation(old TopicDescription) UpdateOperation {
+	return UpdateOperation{
+		Action: UpdateActionUnsubscribe,
+		Target: UpdateTargetResponse,
+		Key:    old.GetResponseTopic(),
+		apply: func() error {
+			return this.removeResponse(old.GetResponseTopic())
+		},
+		revert: func() error {
+			return this.addResponse(old)
+		},
+	}
+}
+
+func (this *Connector) subscribeStatusOperation(desc TopicDescription) UpdateOperation {
+	return UpdateOperation{
+		Action: UpdateActionSubscribe,
+		Target: UpdateTargetStatus,
+		Key:    desc.GetStatusTopic(),
+		apply: func() error {
+			return this.addStatus(desc)
+		},
+		revert: func() error {
+			return this.removeStatus(desc.GetStatusTopic())
+		},
+	}
+}
+
+func (this *Connector) unsubscribeStatusOperation(old TopicDescription) UpdateOperation {
+	return UpdateOperation{
+		Action: UpdateActionUnsubscribe,
+		Target: UpdateTargetStatus,
+		Key:    old.GetStatusTopic(),
+		apply: func() error {
+			return this.removeStatus(old.GetStatusTopic())
+		},
+		revert: func() error {
+			return this.addStatus(old)
+		},
+	}
+}
+
+func (this *Connector) setCommandOperation(commandId string, desc TopicDescription) UpdateOperation {
+	old, known := this.commandTopicRegister.Get(commandId)
+	return UpdateOperation{
+		Action: UpdateActionSet,
+		Target: UpdateTargetCommand,
+		Key:    commandId,
+		apply: func() error {
+			this.commandTopicRegister.Set(commandId, desc)
+			return nil
+		},
+		revert: func() error {
+			if known {
+				this.commandTopicRegister.Set(commandId, old)
+			} else {
+				this.commandTopicRegister.Remove(commandId)
+			}
+			return nil
+		},
+	}
+}
+
+func (this *Connector) removeCommandOperation(commandId string, old TopicDescription) UpdateOperation {
+	return UpdateOperation{
+		Action: UpdateActionRemove,
+		Target: UpdateTargetCommand,
+		Key:    commandId,
+		apply: func() error {
+			this.commandTopicRegister.Remove(commandId)
+			return nil
+		},
+		revert: func() error {
+			this.commandTopicRegister.Set(commandId, old)
+			return nil
+		},
+	}
+}
+
+func (this *Connector) removeLearnedDeviceOperation(deviceId string, old TopicDescription) UpdateOperation {
+	return UpdateOperation{
+		Action: UpdateActionRemove,
+		Target: UpdateTargetLearnedDevice,
+		Key:    deviceId,
+		apply: func() error {
+			this.learnedDevices.Remove(deviceId)
+			return nil
+		},
+		revert: func() error {
+			this.learnedDevices.Set(deviceId, old)
+			return nil
+		},
+	}
+}
+
+func (this *Connector) setLivenessOperation(events []TopicDescription, oldEvents map[string]TopicDescription) UpdateOperation {
+	return UpdateOperation{
+		Action: UpdateActionSet,
+		Target: UpdateTargetLiveness,
+		apply: func() error {
+			this.updateLiveness(events)
+			return nil
+		},
+		revert: func() error {
+			old := []TopicDescription{}
+			for _, event := range oldEvents {
+				old = append(old, event)
+			}
+			this.updateLiveness(old)
+			return nil
+		},
+	}
+}
+
+// removeDeviceOperations stops listening to the commands of the device and deletes the device if delete_devices is set
+func (this *Connector) removeDeviceOperations(device TopicDescription) (result []UpdateOperation) {
+	id := device.GetLocalDeviceId()
+	if this.config.DeleteDevices {
+		result = append(result, UpdateOperation{
+			Action: UpdateActionRemove,
+			Target: UpdateTargetDevice,
+			Key:    id,
+			apply: func() error {
+				log.Println("delete device", device.GetDeviceName(), id)
+				return this.mgwClient.RemoveDevice(id)
+			},
+			revert: func() error {
+				return this.mgwClient.SetDevice(id, device.GetDeviceName(), device.GetDeviceTypeId(), string(this.getDeviceState(device)))
+			},
+		})
+	} else {
+		log.Println("topic description has ben removed but device deletion is disabled", device.GetDeviceName(), id)
+	}
+	return append(result, this.stopListenDeviceOperation(device))
+}
+
+func (this *Connector) stopListenDeviceOperation(device TopicDescription) UpdateOperation {
+	id := device.GetLocalDeviceId()
+	return UpdateOperation{
+		Action: UpdateActionStopListen,
+		Target: UpdateTargetDevice,
+		Key:    id,
+		apply: func() error {
+			if this.config.Debug {
+				log.Println("DEBUG: remove device command listener", device)
+			}
+			return this.mgwClient.StopListenToDeviceCommands(id)
+		},
+		revert: func() error {
+			return this.mgwClient.ListenToDeviceCommands(id, this.CommandHandler)
+		},
+	}
+}
+
+// setDeviceOperation sends the device with its current state to the mgw;
+// reverting a new device deletes it only if delete_devices is set
+func (this *Connector) setDeviceOperation(desc TopicDescription, old TopicDescription, known bool) UpdateOperation {
+	id := desc.GetLocalDeviceId()
+	return UpdateOperation{
+		Action: UpdateActionSet,
+		Target: UpdateTargetDevice,
+		Key:    id,
+		apply: func() error {
+			return this.mgwClient.SetDevice(id, desc.GetDeviceName(), desc.GetDeviceTypeId(), string(this.getDeviceState(desc)))
+		},
+		revert: func() error {
+			if known {
+				return this.mgwClient.SetDevice(id, old.GetDeviceName(), old.GetDeviceTypeId(), string(this.getDeviceState(old)))
+			}
+			if this.config.DeleteDevices {
+				return this.mgwClient.RemoveDevice(id)
+			}
+			return nil
+		},
+	}
+}
+
+func (this *Connector) listenDeviceOperation(desc TopicDescription) UpdateOperation {
+	id := desc.GetLocalDeviceId()
+	return UpdateOperation{
+		Action: UpdateActionListen,
+		Target: UpdateTargetDevice,
+		Key:    id,
+		apply: func() error {
+			if this.config.Debug {
+				log.Println("DEBUG: add device command listener", desc)
+			}
+			return this.mgwClient.ListenToDeviceCommands(id, this.CommandHandler)
+		},
+		revert: func() error {
+			return this.mgwClient.StopListenToDeviceCommands(id)
+		},
+	}
+}
+
+func (this *Connector) setDeviceListOperation(devices map[string]TopicDescription) UpdateOperation {
+	old := this.devices.GetAll()
+	return UpdateOperation{
+		Action: UpdateActionSet,
+		Target: UpdateTargetDeviceList,
+		apply: func() error {
+			this.devices.Do(func(m *map[string]TopicDescription) {
+				*m = devices
+			})
+			return nil
+		},
+		revert: func() error {
+			this.devices.Do(func(m *map[string]TopicDescription) {
+				*m = old
+			})
+			return nil
+		},
+	}
+}
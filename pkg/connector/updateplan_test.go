@@ -0,0 +1,326 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdatePlan(t *testing.T) {
+	descriptions := []MockDesc{"e:a", "c:b"}
+	mqttClient := &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+
+	conn, err := NewWithFactories(context.Background(), configuration.Config{
+		DeleteDevices:      true,
+		UpdateRetries:      2,
+		UpdateRetryBackoff: "1ms",
+	}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]MockDesc, error) {
+		return descriptions, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return mqttClient, nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	check := func(t *testing.T, subscriptions []string, devices []string) {
+		t.Helper()
+		if !reflect.DeepEqual(sortedKeys(mqttClient.subscriptions), subscriptions) {
+			t.Error("unexpected subscriptions", sortedKeys(mqttClient.subscriptions), subscriptions)
+		}
+		if !reflect.DeepEqual(sortedKeys(mgwClient.devices), devices) {
+			t.Error("unexpected mgw devices", sortedKeys(mgwClient.devices), devices)
+		}
+		if !reflect.DeepEqual(sortedKeys(mgwClient.listening), devices) {
+			t.Error("unexpected command listeners", sortedKeys(mgwClient.listening), devices)
+		}
+		if !reflect.DeepEqual(sortedKeys(conn.devices.GetAll()), devices) {
+			t.Error("unexpected device register", sortedKeys(conn.devices.GetAll()), devices)
+		}
+	}
+
+	t.Run("initial", func(t *testing.T) {
+		err = conn.updateTopics()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		check(t, []string{"a", "b/resp"}, []string{"c:b_dlid", "e:a_dlid"})
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		descriptions = []MockDesc{"e:a", "c:b", "e:c"}
+		mqttClient.fail("c", 2)
+		err = conn.updateTopics()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		check(t, []string{"a", "b/resp", "c"}, []string{"c:b_dlid", "e:a_dlid", "e:c_dlid"})
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		descriptions = []MockDesc{"e:d", "c:e"}
+		mqttClient.fail("d", 3)
+		err = conn.updateTopics()
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+		check(t, []string{"a", "b/resp", "c"}, []string{"c:b_dlid", "e:a_dlid", "e:c_dlid"})
+		if _, ok := conn.commandTopicRegister.Get(getCommandIdFromDesc(MockDesc("c:b"))); !ok {
+			t.Error("missing command after rollback")
+		}
+		if _, ok := conn.commandTopicRegister.Get(getCommandIdFromDesc(MockDesc("c:e"))); ok {
+			t.Error("unexpected command after rollback")
+		}
+		last, _ := conn.GetLastUpdate()
+		if !slices.Contains(last.Plan, "subscribe event d") || !slices.Contains(last.Plan, "unsubscribe event a") {
+			t.Error("unexpected plan", last.Plan)
+		}
+	})
+
+	t.Run("next update", func(t *testing.T) {
+		err = conn.updateTopics()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		check(t, []string{"d", "e/resp"}, []string{"c:e_dlid", "e:d_dlid"})
+	})
+
+	t.Run("retry time exceeded", func(t *testing.T) {
+		conn.updateRetryMaxDuration = 0
+		defer func() { conn.updateRetryMaxDuration = 10 * time.Second }()
+		descriptions = []MockDesc{"e:d", "c:e", "e:f"}
+		mqttClient.fail("f", 1)
+		err = conn.updateTopics()
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+		check(t, []string{"d", "e/resp"}, []string{"c:e_dlid", "e:d_dlid"})
+	})
+}
+
+func TestEqualTopicDesc(t *testing.T) {
+	base := model.TopicDescription{CmdTopic: "c", RespTopic: "c/resp", DeviceLocalId: "d", DeviceName: "a", ServiceLocalId: "s", DeviceTypeId: "dt"}
+	if !EqualTopicDesc(base, base) {
+		t.Error("expected equal descriptions")
+	}
+	for name, change := range map[string]func(desc *model.TopicDescription){
+		"resp_timeout":        func(desc *model.TopicDescription) { desc.RespTimeout = "10s" },
+		"user_properties":     func(desc *model.TopicDescription) { desc.UserProperties = map[string]string{"a": "b"} },
+		"message_expiry":      func(desc *model.TopicDescription) { desc.MessageExpiry = "1m" },
+		"payload_format":      func(desc *model.TopicDescription) { desc.PayloadFormat = "cbor" },
+		"protobuf_descriptor": func(desc *model.TopicDescription) { desc.ProtobufDescriptor = "sensors.binpb" },
+		"protobuf_message":    func(desc *model.TopicDescription) { desc.ProtobufMessage = "factory.v1.Reading" },
+		"transformations": func(desc *model.TopicDescription) {
+			desc.Transformations = []model.Transformation{{Path: "value", Transformation: model.TransformerJsonWrapOutput}}
+		},
+	} {
+		changed := base
+		change(&changed)
+		if EqualTopicDesc(base, changed) {
+			t.Error("expected changed", name)
+		}
+	}
+}
+
+func TestUpdatePlanTransformationChange(t *testing.T) {
+	descriptions := []model.TopicDescription{{
+		EventTopic:      "a",
+		DeviceLocalId:   "d",
+		ServiceLocalId:  "s",
+		DeviceName:      "d",
+		DeviceTypeId:    "dt",
+		Transformations: []model.Transformation{{Path: "value", Transformation: model.TransformerJsonWrapOutput, Details: "number"}},
+	}}
+	mqttClient := &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+	conn, err := NewWithFactories(context.Background(), configuration.Config{}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		return descriptions, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return mqttClient, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.updateTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptions[0].Transformations = []model.Transformation{{Path: "value", Transformation: model.TransformerJsonWrapOutput, Details: "string"}}
+	err = conn.updateTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, ok := conn.eventTopicRegister.Get("a")
+	if !ok {
+		t.Fatal("missing event description")
+	}
+	if details := desc.GetTransformationDetails(model.TransformerJsonWrapOutput); details["value"] != "string" {
+		t.Error("transformation not updated", details)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) (result []string) {
+	for key := range m {
+		result = append(result, key)
+	}
+	slices.Sort(result)
+	return result
+}
+
+type recordingMqtt struct {
+	mux           sync.Mutex
+	subscriptions map[string]bool
+	failures      map[string]int
+	published     map[string][]byte //last payload of each topic
+}
+
+func (this *recordingMqtt) fail(topic string, times int) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.failures[topic] = times
+}
+
+func (this *recordingMqtt) Subscribe(topic string, qos byte, handler func(topic string, retained bool, payload []byte)) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.failures[topic] > 0 {
+		this.failures[topic] = this.failures[topic] - 1
+		return errors.New("test subscription error")
+	}
+	this.subscriptions[topic] = true
+	return nil
+}
+
+func (this *recordingMqtt) Unsubscribe(topic string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.subscriptions, topic)
+	return nil
+}
+
+func (this *recordingMqtt) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.published == nil {
+		this.published = map[string][]byte{}
+	}
+	this.published[topic] = payload
+	return nil
+}
+
+func (this *recordingMqtt) getPublished(topic string) (payload []byte, ok bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	payload, ok = this.published[topic]
+	return payload, ok
+}
+
+type recordingMgw struct {
+	MgwMock
+	mux           sync.Mutex
+	devices       map[string]string //device id to state
+	listening     map[string]bool
+	deviceErrors  []string
+	clientErrors  []string
+	commandErrors []string
+	events        map[string][]byte //last payload of each device service
+}
+
+func (this *recordingMgw) SendEvent(deviceId string, serviceId string, value []byte) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.events == nil {
+		this.events = map[string][]byte{}
+	}
+	this.events[getCommandId(deviceId, serviceId)] = value
+	return nil
+}
+
+func (this *recordingMgw) getEvent(deviceId string, serviceId string) (payload []byte, ok bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	payload, ok = this.events[getCommandId(deviceId, serviceId)]
+	return payload, ok
+}
+
+func (this *recordingMgw) SendClientError(message string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.clientErrors = append(this.clientErrors, message)
+}
+
+func (this *recordingMgw) SendCommandError(correlationId string, message string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.commandErrors = append(this.commandErrors, correlationId)
+}
+
+func (this *recordingMgw) SendDeviceError(localDeviceId string, message string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.deviceErrors = append(this.deviceErrors, localDeviceId)
+}
+
+func (this *recordingMgw) SetDevice(deviceId string, name string, deviceTypeid string, state string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.devices[deviceId] = state
+	return nil
+}
+
+func (this *recordingMgw) RemoveDevice(deviceId string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.devices, deviceId)
+	return nil
+}
+
+func (this *recordingMgw) ListenToDeviceCommands(deviceId string, commandHandler mgw.DeviceCommandHandler) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.listening[deviceId] = true
+	return nil
+}
+
+func (this *recordingMgw) StopListenToDeviceCommands(deviceId string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.listening, deviceId)
+	return nil
+}
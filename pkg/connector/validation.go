@@ -19,99 +19,535 @@ package connector
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/sparkplug"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/util"
 	"log"
+	"path"
+	"slices"
+	"strings"
+	"time"
 )
 
-func (this *Connector) validateTopicDescriptions(topics []TopicDescription) error {
-	topics = util.ListFilterDuplicates(topics, func(a TopicDescription, b TopicDescription) bool {
-		duplicate := EqualTopicDesc(a, b)
-		if duplicate {
-			log.Println("WARNING: found duplicate topic description:", descToStr(a))
-		}
-		return duplicate
-	})
-
-	eventTopicUsed := map[string]bool{}
-	respTopicUsed := map[string]bool{}
-	cmdTopicUsed := map[string]bool{}
-	cmdIdUsed := map[string]bool{}
-
-	deviceToName := map[string]string{}
-	deviceToDeviceType := map[string]string{}
-	for _, topic := range topics {
-		event := topic.GetEventTopic()
-		cmd := topic.GetCmdTopic()
-		resp := topic.GetResponseTopic()
-		deviceId := topic.GetLocalDeviceId()
-		deviceName := topic.GetDeviceName()
-		deviceTypeId := topic.GetDeviceTypeId()
-		cmdId := getCommandIdFromDesc(topic)
-
-		//check for invalid element
-		if cmd == event || (cmd != "" && event != "") {
-			j, _ := json.Marshal(map[string]string{"e": event, "c": cmd, "r": resp})
-			return errors.New("invalid topic description: expect either event or command topic: " + string(j))
-		}
-		if resp != "" && cmd == "" {
-			j, _ := json.Marshal(map[string]string{"e": event, "c": cmd, "r": resp})
-			log.Println("WARNING: response topic will not be used if command topic is not set", string(j))
+const (
+	ValidationError   = "error"
+	ValidationWarning = "warning"
+)
+
+const (
+	ValidationModeQuarantine = "quarantine"
+	ValidationModeStrict     = "strict"
+)
+
+type ValidationProblem struct {
+	Index    int    //index of the topic description in the validated list
+	Severity string //ValidationError or ValidationWarning
+	Message  string
+	DeviceId string //set if the problem concerns all descriptions of the device (e.g. conflicting names)
+}
+
+// deviceValidationError marks errors caused by conflicting definitions of a device
+type deviceValidationError struct {
+	deviceId string
+	message  string
+}
+
+func (this deviceValidationError) Error() string {
+	return this.message
+}
+
+// validateTopicDescriptions returns the topic descriptions to be used by the update.
+// in strict mode, any error rejects all descriptions; in quarantine mode, only the invalid descriptions are excluded
+func (this *Connector) validateTopicDescriptions(topics []TopicDescription) ([]TopicDescription, error) {
+	if this.config.ValidationMode == ValidationModeStrict {
+		return topics, this.validateTopicDescriptionsStrict(topics)
+	}
+	return this.keepQuarantinedDevices(this.quarantineTopicDescriptions(topics)), nil
+}
+
+func (this *Connector) validateTopicDescriptionsStrict(topics []TopicDescription) error {
+	var err error
+	for _, problem := range ValidateTopicDescriptions(this.config, topics) {
+		if problem.Severity == ValidationWarning {
+			log.Println("WARNING:", problem.Message)
+		} else if err == nil {
+			err = errors.New(problem.Message)
 		}
+	}
+	return err
+}
+
+// quarantineTopicDescriptions excludes descriptions with errors and, for conflicting device definitions, all descriptions of the device.
+// the remaining descriptions are validated again until no errors are left.
+// errors are sent to the mgw if they have not been reported by the previous update.
+func (this *Connector) quarantineTopicDescriptions(topics []TopicDescription) (valid []TopicDescription) {
+	quarantined := map[string]bool{}
+	quarantinedDevices := map[string]bool{}
+	valid = topics
+	for round := 0; ; round++ {
+		excluded := map[int]bool{}
+		excludedDevices := map[string]bool{}
+		for _, problem := range ValidateTopicDescriptions(this.config, valid) {
+			if problem.Severity == ValidationWarning {
+				if round == 0 {
+					log.Println("WARNING:", problem.Message)
+				}
+				continue
+			}
+			excluded[problem.Index] = true
+			desc := valid[problem.Index]
+			deviceId := desc.GetLocalDeviceId()
+			if problem.DeviceId != "" {
+				deviceId = problem.DeviceId
+				excludedDevices[deviceId] = true
+			}
+			if deviceId != "" {
+				quarantinedDevices[deviceId] = true
+			}
+			message := "quarantined topic description: " + problem.Message
+			if quarantined[message] {
+				continue
+			}
+			quarantined[message] = true
+			log.Println("ERROR:", message, descToStr(desc))
+			if this.quarantined[message] {
+				continue
+			}
+			if deviceId != "" {
+				this.mgwClient.SendDeviceError(deviceId, message)
+			} else {
+				this.mgwClient.SendClientError(message)
+			}
+		}
+		if len(excluded) == 0 {
+			this.quarantined = quarantined
+			this.quarantinedDevices = quarantinedDevices
+			return valid
+		}
+		remaining := []TopicDescription{}
+		for index, topic := range valid {
+			if !excluded[index] && !referencesDevice(topic, excludedDevices) {
+				remaining = append(remaining, topic)
+			}
+		}
+		valid = remaining
+	}
+}
+
+// keepQuarantinedDevices adds the descriptions of the previous update for quarantined devices without valid descriptions,
+// so that registered devices are not removed because of an invalid change.
+// kept descriptions conflicting with other descriptions are dropped.
+func (this *Connector) keepQuarantinedDevices(valid []TopicDescription) []TopicDescription {
+	used := map[string]bool{}
+	for _, topic := range valid {
+		for _, device := range this.getDeviceDescriptions(topic) {
+			used[device.GetLocalDeviceId()] = true
+		}
+	}
+	missing := map[string]bool{}
+	for deviceId := range this.quarantinedDevices {
+		if !used[deviceId] {
+			missing[deviceId] = true
+		}
+	}
+	if len(missing) == 0 {
+		return valid
+	}
+	kept := []TopicDescription{}
+	for _, topic := range this.lastTopics {
+		if referencesDevice(topic, missing) {
+			kept = append(kept, topic)
+		}
+	}
+	if len(kept) == 0 {
+		return valid
+	}
+	for len(kept) > 0 {
+		result := append(slices.Clone(valid), kept...)
+		hasErrors := false
+		dropped := map[int]bool{}
+		for _, problem := range ValidateTopicDescriptions(this.config, result) {
+			if problem.Severity == ValidationWarning {
+				continue
+			}
+			hasErrors = true
+			if problem.Index >= len(valid) {
+				dropped[problem.Index-len(valid)] = true
+			}
+			for i, topic := range kept {
+				if problem.DeviceId != "" && referencesDevice(topic, map[string]bool{problem.DeviceId: true}) {
+					dropped[i] = true
+				}
+			}
+		}
+		if !hasErrors {
+			log.Println("WARNING: keep previous topic descriptions of quarantined devices", sortedDeviceIds(missing))
+			return result
+		}
+		if len(dropped) == 0 {
+			break
+		}
+		remaining := []TopicDescription{}
+		for i, topic := range kept {
+			if !dropped[i] {
+				remaining = append(remaining, topic)
+			}
+		}
+		kept = remaining
+	}
+	log.Println("WARNING: unable to keep previous topic descriptions of quarantined devices", sortedDeviceIds(missing))
+	return valid
+}
+
+func referencesDevice(topic TopicDescription, deviceIds map[string]bool) bool {
+	if deviceIds[topic.GetLocalDeviceId()] {
+		return true
+	}
+	for _, deviceId := range topic.GetDevices() {
+		if deviceIds[deviceId] {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTopicDescriptions returns all errors and warnings of the topic descriptions.
+// the validation of a topic description stops at its first error; following descriptions are still validated.
+func ValidateTopicDescriptions(config configuration.Config, topics []TopicDescription) []ValidationProblem {
+	validator := &topicValidator{
+		config:              config,
+		eventTopicUsed:      map[string]bool{},
+		respTopicUsed:       map[string]bool{},
+		cmdTopicUsed:        map[string]bool{},
+		cmdIdUsed:           map[string]bool{},
+		statusTopicToDevice: map[string]string{},
+		statusTopicIndex:    map[string]int{},
+		deviceToName:        map[string]string{},
+		deviceToDeviceType:  map[string]string{},
+		sparkplugToDevice:   map[string]string{},
+	}
+	for index, topic := range topics {
+		if slices.ContainsFunc(topics[:index], func(other TopicDescription) bool { return EqualTopicDesc(other, topic) }) {
+			validator.warn(index, "found duplicate topic description:", descToStr(topic))
+			continue
+		}
+		err := validator.check(index, topic)
+		var deviceErr deviceValidationError
+		if errors.As(err, &deviceErr) {
+			validator.problems = append(validator.problems, ValidationProblem{Index: index, Severity: ValidationError, Message: deviceErr.message, DeviceId: deviceErr.deviceId})
+		} else if err != nil {
+			validator.error(index, err.Error())
+		}
+	}
+	validator.checkStatusTopics()
+	return validator.problems
+}
+
+type topicValidator struct {
+	config              configuration.Config
+	problems            []ValidationProblem
+	eventTopicUsed      map[string]bool
+	eventTopicPatterns  []TopicPattern
+	eventTopicWildcards []TopicPattern
+	respTopicUsed       map[string]bool
+	cmdTopicUsed        map[string]bool
+	cmdIdUsed           map[string]bool
+	statusTopicToDevice map[string]string
+	statusTopicIndex    map[string]int
+	deviceToName        map[string]string
+	deviceToDeviceType  map[string]string
+	sparkplugToDevice   map[string]string //sparkplug device key -> local device id
+	sparkplugNodes      []TopicPattern    //subscriptions of the sparkplug edge nodes
+}
+
+func (this *topicValidator) warn(index int, msg ...interface{}) {
+	this.problems = append(this.problems, ValidationProblem{Index: index, Severity: ValidationWarning, Message: strings.TrimSpace(fmt.Sprintln(msg...))})
+}
+
+func (this *topicValidator) error(index int, msg string) {
+	this.problems = append(this.problems, ValidationProblem{Index: index, Severity: ValidationError, Message: msg})
+}
+
+func (this *topicValidator) check(index int, topic TopicDescription) error {
+	event := topic.GetEventTopic()
+	cmd := topic.GetCmdTopic()
+	resp := topic.GetResponseTopic()
+	deviceId := topic.GetLocalDeviceId()
+	deviceTypeId := topic.GetDeviceTypeId()
+	cmdId := getCommandIdFromDesc(topic)
+
+	status := topic.GetStatusTopic()
+
+	//check for invalid element (descriptions with only a status topic are allowed)
+	if (cmd == event && status == "") || (cmd != "" && event != "") {
+		j, _ := json.Marshal(map[string]string{"e": event, "c": cmd, "r": resp, "s": status})
+		return errors.New("invalid topic description: expect either event, command or status topic: " + string(j))
+	}
+	if resp != "" && cmd == "" {
+		j, _ := json.Marshal(map[string]string{"e": event, "c": cmd, "r": resp})
+		this.warn(index, "response topic will not be used if command topic is not set", string(j))
+	}
+
+	//check response timeout format
+	if timeout := topic.GetResponseTimeout(); timeout != "" && timeout != "-" {
+		duration, err := time.ParseDuration(timeout)
+		if err != nil {
+			return errors.New("invalid response timeout " + timeout + ": " + err.Error())
+		}
+		if duration <= 0 {
+			return errors.New("invalid response timeout " + timeout + ": expect positive duration")
+		}
+	}
+
+	//check correlation settings
+	if path := topic.GetCorrelationPath(); path != "" {
+		if cmd == "" || resp == "" {
+			return errors.New("correlation_path is only usable with command and response topics: " + descToStr(topic))
+		}
+		if slices.Contains(strings.Split(path, "."), "") {
+			return errors.New("invalid correlation_path: " + path)
+		}
+	}
+	if token := topic.GetCorrelationToken(); token != "" && token != CorrelationTokenCommandId && token != CorrelationTokenGenerated {
+		return errors.New("invalid correlation_token " + token + ": expect " + CorrelationTokenCommandId + " or " + CorrelationTokenGenerated)
+	}
+
+	//check liveness timeout format
+	if timeout := topic.GetLivenessTimeout(); timeout != "" && timeout != "-" {
+		if _, err := time.ParseDuration(timeout); err != nil {
+			return errors.New("invalid liveness timeout " + timeout + ": " + err.Error())
+		}
+	}
+	if topic.GetLivenessTimeout() != "" && event == "" {
+		this.warn(index, "liveness_timeout is only used for event topics", descToStr(topic))
+	}
+
+	//check mqtt 5 settings
+	if expiry := topic.GetMessageExpiry(); expiry != "" && expiry != "-" {
+		if _, err := time.ParseDuration(expiry); err != nil {
+			return errors.New("invalid message expiry " + expiry + ": " + err.Error())
+		}
+	}
+	if (len(topic.GetUserProperties()) > 0 || topic.GetMessageExpiry() != "") && this.config.MqttProtocolVersion != "5" {
+		this.warn(index, "user_properties and message_expiry are only used with mqtt_protocol_version 5", descToStr(topic))
+	}
+
+	//check payload format
+	if _, err := getPayloadCodec(topic); err != nil {
+		return fmt.Errorf("%w: %v", err, descToStr(topic))
+	}
+
+	//check transformation details
+	if err := checkTransformationDetails(topic); err != nil {
+		return fmt.Errorf("%w: %v", err, descToStr(topic))
+	}
+
+	//check sparkplug settings
+	if topic.GetPayloadFormat() == sparkplug.Format {
+		if err := this.checkSparkplug(topic); err != nil {
+			return err
+		}
+	} else if len(topic.GetSparkplugMetrics()) > 0 {
+		this.warn(index, "sparkplug_metrics is only used with payload_format sparkplug", descToStr(topic))
+	}
+
+	//check for wildcards outside of event topics
+	if IsTopicPattern(cmd) || IsTopicPattern(resp) {
+		return errors.New("wildcards and captures are only supported in event topics: " + descToStr(topic))
+	}
+
+	//check status settings
+	if status != "" {
+		placeholder := "{" + TopicCaptureDevice + "}"
+		if IsTopicPattern(strings.ReplaceAll(status, placeholder, "")) {
+			return errors.New("wildcards are not supported in status topics: " + status)
+		}
+		if strings.Contains(status, placeholder) && !capturesDevice(topic) {
+			return errors.New("status topic " + status + " uses " + placeholder + " but the event topic does not capture the device")
+		}
+		if path := topic.GetStatusPath(); path != "" && slices.Contains(strings.Split(path, "."), "") {
+			return errors.New("invalid status_path: " + path)
+		}
+	} else if topic.GetStatusPath() != "" || len(topic.GetStatusOnlineValues()) > 0 || len(topic.GetStatusOfflineValues()) > 0 {
+		this.warn(index, "status_path and status values are only used with a status topic", descToStr(topic))
+	}
+
+	//check for name and device-type redefinition of all devices referenced by the description
+	devices := []TopicDescription{topic}
+	if capturesDevice(topic) {
+		devices = util.ListMap(topic.GetDevices(), func(deviceId string) TopicDescription {
+			return resolveTopicDescription(topic, deviceId, "")
+		})
+	} else if len(topic.GetDevices()) > 0 {
+		this.warn(index, "devices list is only used for event topics with a {device} capture", descToStr(topic))
+	}
+	for _, device := range devices {
+		deviceId := device.GetLocalDeviceId()
+		deviceName := device.GetDeviceName()
 
 		//check for name redefinition
-		if known, exists := deviceToName[deviceId]; exists && known != deviceName {
-			return errors.New("device " + deviceId + " has multiple name assignments: " + known + " and " + deviceName)
+		if known, exists := this.deviceToName[deviceId]; exists && known != deviceName {
+			return deviceValidationError{deviceId: deviceId, message: "device " + deviceId + " has multiple name assignments: " + known + " and " + deviceName}
 		} else {
-			deviceToName[deviceId] = deviceName
+			this.deviceToName[deviceId] = deviceName
 		}
 
 		//check for device-type redefinition
-		if known, exists := deviceToDeviceType[deviceId]; exists && known != deviceTypeId {
-			return errors.New("device " + deviceId + " has multiple device-type-id assignments: " + known + " and " + deviceTypeId)
+		if known, exists := this.deviceToDeviceType[deviceId]; exists && known != deviceTypeId {
+			return deviceValidationError{deviceId: deviceId, message: "device " + deviceId + " has multiple device-type-id assignments: " + known + " and " + deviceTypeId}
 		} else {
-			deviceToDeviceType[deviceId] = deviceTypeId
+			this.deviceToDeviceType[deviceId] = deviceTypeId
 		}
+	}
 
-		//check for response topic reuse for commands
-		if cmd != "" {
-			cmdTopicUsed[cmd] = true
-		}
-		if resp != "" {
-			if exists := cmdTopicUsed[resp]; exists {
-				return errors.New("collision between command and response topic: " + resp)
+	//check for status topic reuse by other devices and collisions with event and response topics
+	if status != "" {
+		for _, device := range devices {
+			deviceStatus := device.GetStatusTopic()
+			if known, exists := this.statusTopicToDevice[deviceStatus]; exists && known != device.GetLocalDeviceId() {
+				return deviceValidationError{deviceId: device.GetLocalDeviceId(), message: "status topic " + deviceStatus + " is used by multiple devices: " + known + " and " + device.GetLocalDeviceId()}
 			}
+			this.statusTopicToDevice[deviceStatus] = device.GetLocalDeviceId()
+			this.statusTopicIndex[deviceStatus] = index
 		}
+	}
 
-		//check for device-id + service-id reuse in commands (a command topic can be used for mor than one service)
-		if cmd != "" {
-			if exists := cmdIdUsed[cmdId]; exists {
-				return errors.New("reused device-id/service-id: " + cmdId)
-			}
-			cmdIdUsed[cmdId] = true
+	//check for response topic reuse for commands
+	if cmd != "" {
+		this.cmdTopicUsed[cmd] = true
+	}
+	if resp != "" {
+		if exists := this.cmdTopicUsed[resp]; exists {
+			return errors.New("collision between command and response topic: " + resp)
 		}
+	}
 
-		//check for event topic reuse for other events --> error
-		if event != "" {
-			if exists := eventTopicUsed[event]; exists {
-				return errors.New("reused event topic: " + event)
-			}
-			eventTopicUsed[event] = true
+	//check for device-id + service-id reuse in commands (a command topic can be used for mor than one service)
+	if cmd != "" {
+		if exists := this.cmdIdUsed[cmdId]; exists {
+			return errors.New("reused device-id/service-id: " + cmdId)
 		}
+		this.cmdIdUsed[cmdId] = true
+	}
 
-		//WARN if event and response topic collide (it's but warning would be nice)
-		if resp != "" {
-			respTopicUsed[resp] = true
-			if eventTopicUsed[resp] {
-				log.Println("WARNING: response topic is also used as event topic", resp)
+	//check for event topic reuse for other events --> error (sparkplug devices may be split into multiple services)
+	if event != "" && !isSparkplugEvent(topic) {
+		if exists := this.eventTopicUsed[event]; exists {
+			return errors.New("reused event topic: " + event)
+		}
+		this.eventTopicUsed[event] = true
+	}
+
+	//check for overlapping event topic patterns --> error (a message would be handled more than once)
+	if event != "" && !isSparkplugEvent(topic) {
+		pattern, err := ParseTopicPattern(event)
+		if err != nil {
+			return errors.New("invalid event topic: " + err.Error())
+		}
+		if pattern.IsWildcard() && !pattern.HasCapture(TopicCaptureDevice) && deviceId == "" {
+			return errors.New("missing device id for event topic: " + event)
+		}
+		compareWith := this.eventTopicWildcards
+		if pattern.IsWildcard() {
+			compareWith = this.eventTopicPatterns
+		}
+		for _, other := range compareWith {
+			if pattern.Overlaps(other) {
+				return errors.New("overlapping event topics: " + other.Topic + " and " + event)
+			}
+		}
+		for _, node := range this.sparkplugNodes {
+			if pattern.Overlaps(node) {
+				return errors.New("event topic " + event + " overlaps the sparkplug node subscription " + node.Topic)
 			}
 		}
-		if event != "" && respTopicUsed[event] {
-			log.Println("WARNING: event topic is also used as response topic", event)
+		this.eventTopicPatterns = append(this.eventTopicPatterns, pattern)
+		if pattern.IsWildcard() {
+			this.eventTopicWildcards = append(this.eventTopicWildcards, pattern)
+		}
+	}
+
+	//WARN if event and response topic collide (it's but warning would be nice)
+	if resp != "" {
+		this.respTopicUsed[resp] = true
+		if this.eventTopicUsed[resp] {
+			this.warn(index, "response topic is also used as event topic", resp)
+		}
+	}
+	if event != "" && this.respTopicUsed[event] {
+		this.warn(index, "event topic is also used as response topic", event)
+	}
+	return nil
+}
+
+// checkSparkplug checks the topics of payload_format sparkplug; event topics are DDATA and command topics DCMD topics of a device
+func (this *topicValidator) checkSparkplug(topic TopicDescription) error {
+	if topic.GetResponseTopic() != "" || topic.GetStatusTopic() != "" {
+		return errors.New("response and status topics are not supported with payload_format sparkplug: " + descToStr(topic))
+	}
+	if cmd := topic.GetCmdTopic(); cmd != "" {
+		parsed, err := sparkplug.ParseTopic(cmd)
+		if err != nil {
+			return err
+		}
+		if parsed.MessageType != sparkplug.MessageDeviceCommand {
+			return errors.New("expect " + sparkplug.MessageDeviceCommand + " topic as sparkplug command topic: " + cmd)
+		}
+		return nil
+	}
+	event := topic.GetEventTopic()
+	parsed, err := sparkplug.ParseTopic(event)
+	if err != nil {
+		return err
+	}
+	if parsed.MessageType != sparkplug.MessageDeviceData {
+		return errors.New("expect " + sparkplug.MessageDeviceData + " topic as sparkplug event topic: " + event)
+	}
+	deviceId := topic.GetLocalDeviceId()
+	if deviceId == "" {
+		return errors.New("missing device id for event topic: " + event)
+	}
+	for _, pattern := range topic.GetSparkplugMetrics() {
+		if _, err = path.Match(pattern, ""); err != nil {
+			return errors.New("invalid sparkplug_metrics pattern " + pattern + ": " + err.Error())
+		}
+	}
+	if known, exists := this.sparkplugToDevice[parsed.DeviceKey()]; exists && known != deviceId {
+		return deviceValidationError{deviceId: deviceId, message: "sparkplug device " + parsed.DeviceKey() + " is used by multiple devices: " + known + " and " + deviceId}
+	}
+	this.sparkplugToDevice[parsed.DeviceKey()] = deviceId
+	node, err := ParseTopicPattern(parsed.NodeSubscription())
+	if err != nil {
+		return err
+	}
+	for _, other := range this.eventTopicPatterns {
+		if node.Overlaps(other) {
+			return errors.New("event topic " + other.Topic + " overlaps the sparkplug node subscription " + node.Topic)
 		}
 	}
+	if !slices.ContainsFunc(this.sparkplugNodes, func(known TopicPattern) bool { return known.Topic == node.Topic }) {
+		this.sparkplugNodes = append(this.sparkplugNodes, node)
+	}
 	return nil
 }
 
+func (this *topicValidator) checkStatusTopics() {
+	//status topics are subscribed separately and may not be handled as events or responses
+	for status := range this.statusTopicToDevice {
+		if this.respTopicUsed[status] {
+			this.error(this.statusTopicIndex[status], "status topic is also used as response topic: "+status)
+			continue
+		}
+		for _, pattern := range this.eventTopicPatterns {
+			if _, ok := pattern.Match(status); ok {
+				this.error(this.statusTopicIndex[status], "status topic is also used as event topic: "+status)
+				break
+			}
+		}
+	}
+}
+
 func descToStr(desc TopicDescription) string {
 	event := desc.GetEventTopic()
 	cmd := desc.GetCmdTopic()
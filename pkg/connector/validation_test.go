@@ -0,0 +1,311 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestValidationMode(t *testing.T) {
+	descriptions := []model.TopicDescription{
+		{EventTopic: "q/1", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		{EventTopic: "q/2", DeviceLocalId: "d1", DeviceName: "b", ServiceLocalId: "s2", DeviceTypeId: "dt"},
+		{EventTopic: "q/3", DeviceLocalId: "d2", DeviceName: "c", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		{EventTopic: "q/3", DeviceLocalId: "d3", DeviceName: "d", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		{CmdTopic: "q/cmd", DeviceLocalId: "d2", DeviceName: "c", ServiceLocalId: "cmd", DeviceTypeId: "dt"},
+	}
+
+	newConnector := func(t *testing.T, mode string) (*Connector, *recordingMqtt, *recordingMgw) {
+		mqttClient := &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}
+		mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+		conn, err := NewWithFactories(context.Background(), configuration.Config{
+			ValidationMode: mode,
+		}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+			return descriptions, nil
+		}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+			return mgwClient, nil
+		}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+			return mqttClient, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, mqttClient, mgwClient
+	}
+
+	t.Run("quarantine", func(t *testing.T) {
+		conn, mqttClient, mgwClient := newConnector(t, ValidationModeQuarantine)
+		err := conn.updateTopics()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !reflect.DeepEqual(sortedKeys(mgwClient.devices), []string{"d2"}) {
+			t.Error("unexpected mgw devices", sortedKeys(mgwClient.devices))
+		}
+		if !reflect.DeepEqual(sortedKeys(mqttClient.subscriptions), []string{"q/3"}) {
+			t.Error("unexpected subscriptions", sortedKeys(mqttClient.subscriptions))
+		}
+		if _, ok := conn.commandTopicRegister.Get(getCommandId("d2", "cmd")); !ok {
+			t.Error("missing command")
+		}
+		deviceErrors := slices.Clone(mgwClient.deviceErrors)
+		slices.Sort(deviceErrors)
+		if !reflect.DeepEqual(deviceErrors, []string{"d1", "d3"}) {
+			t.Error("unexpected device errors", deviceErrors)
+		}
+		last, _ := conn.GetLastUpdate()
+		if len(last.Quarantined) != 2 {
+			t.Error("unexpected quarantined descriptions", last.Quarantined)
+		}
+
+		//problems are only reported once
+		err = conn.updateTopics()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(mgwClient.deviceErrors) != 2 {
+			t.Error("unexpected device errors", mgwClient.deviceErrors)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		conn, _, mgwClient := newConnector(t, ValidationModeStrict)
+		err := conn.updateTopics()
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+		if len(mgwClient.devices) != 0 {
+			t.Error("unexpected mgw devices", sortedKeys(mgwClient.devices))
+		}
+	})
+}
+
+func TestValidatePayloadFormat(t *testing.T) {
+	problems := ValidateTopicDescriptions(configuration.Config{}, []TopicDescription{
+		model.TopicDescription{EventTopic: "a", PayloadFormat: "cbor", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		model.TopicDescription{EventTopic: "b", PayloadFormat: "foo", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s2", DeviceTypeId: "dt"},
+		model.TopicDescription{EventTopic: "c", PayloadFormat: "protobuf", ProtobufDescriptor: "../codec/testdata/sensors.binpb", ProtobufMessage: "factory.v1.Reading", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s3", DeviceTypeId: "dt"},
+		model.TopicDescription{EventTopic: "d", PayloadFormat: "protobuf", ProtobufDescriptor: "../codec/testdata/sensors.binpb", ProtobufMessage: "factory.v1.Unknown", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s4", DeviceTypeId: "dt"},
+		model.TopicDescription{EventTopic: "e", PayloadFormat: "protobuf", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s5", DeviceTypeId: "dt"},
+	})
+	if len(problems) != 3 || problems[0].Index != 1 || problems[1].Index != 3 || problems[2].Index != 4 {
+		t.Error("unexpected problems", problems)
+	}
+}
+
+func TestValidateSparkplug(t *testing.T) {
+	problems := ValidateTopicDescriptions(configuration.Config{}, []TopicDescription{
+		model.TopicDescription{EventTopic: "spBv1.0/plant/DDATA/gw1/plc1", PayloadFormat: "sparkplug", SparkplugMetrics: []string{"temp*"}, DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		model.TopicDescription{EventTopic: "spBv1.0/plant/DDATA/gw1/plc1", PayloadFormat: "sparkplug", SparkplugMetrics: []string{"state/*"}, DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s2", DeviceTypeId: "dt"},
+		model.TopicDescription{CmdTopic: "spBv1.0/plant/DCMD/gw1/plc1", PayloadFormat: "sparkplug", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s3", DeviceTypeId: "dt"},
+		model.TopicDescription{EventTopic: "spBv1.0/plant/DBIRTH/gw1/plc1", PayloadFormat: "sparkplug", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s4", DeviceTypeId: "dt"},
+		model.TopicDescription{EventTopic: "spBv1.0/plant/DDATA/gw1/plc1", PayloadFormat: "sparkplug", DeviceLocalId: "d2", DeviceName: "b", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		model.TopicDescription{EventTopic: "spBv1.0/plant/DDATA/gw1/plc2", PayloadFormat: "sparkplug", SparkplugMetrics: []string{"[a"}, DeviceLocalId: "d3", DeviceName: "c", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		model.TopicDescription{CmdTopic: "spBv1.0/plant/DCMD/gw1/plc1", RespTopic: "spBv1.0/plant/DDATA/gw1/plc1", PayloadFormat: "sparkplug", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s5", DeviceTypeId: "dt"},
+		model.TopicDescription{EventTopic: "spBv1.0/plant/+/gw1/plc3", DeviceLocalId: "d4", DeviceName: "d", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		model.TopicDescription{EventTopic: "a", SparkplugMetrics: []string{"temp"}, DeviceLocalId: "d5", DeviceName: "e", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+	})
+	indexes := []int{}
+	for _, problem := range problems {
+		indexes = append(indexes, problem.Index)
+		if problem.Index == 8 && problem.Severity != ValidationWarning {
+			t.Error("expected warning", problem)
+		}
+	}
+	if !reflect.DeepEqual(indexes, []int{3, 4, 5, 6, 7, 8}) {
+		t.Error("unexpected problems", problems)
+	}
+	if problems[1].DeviceId != "d2" {
+		t.Error("expected device problem", problems[1])
+	}
+}
+
+func TestQuarantineKeepsRegisteredDevices(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "registered.json")
+	mgwDevices := map[string]string{}
+	valid := []model.TopicDescription{
+		{EventTopic: "q/1", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		{EventTopic: "q/2", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s2", DeviceTypeId: "dt"},
+		{EventTopic: "q/3", DeviceLocalId: "d2", DeviceName: "b", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+	}
+	//conflicting names of d1
+	invalid := slices.Clone(valid)
+	invalid[1].DeviceName = "c"
+
+	start := func(t *testing.T, descriptions *[]model.TopicDescription) (*Connector, *recordingMqtt) {
+		mqttClient := &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}
+		conn, err := NewWithFactories(context.Background(), configuration.Config{
+			ConnectorId:           "test",
+			DeleteDevices:         true,
+			RegisteredDevicesFile: file,
+		}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+			return *descriptions, nil
+		}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+			return &recordingMgw{devices: mgwDevices, listening: map[string]bool{}}, nil
+		}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+			return mqttClient, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, mqttClient
+	}
+	update := func(t *testing.T, conn *Connector) {
+		t.Helper()
+		err := conn.updateTopics()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sortedKeys(mgwDevices), []string{"d1", "d2"}) {
+			t.Error("unexpected mgw devices", sortedKeys(mgwDevices))
+		}
+	}
+
+	t.Run("keep previous descriptions", func(t *testing.T) {
+		descriptions := valid
+		conn, mqttClient := start(t, &descriptions)
+		update(t, conn)
+		descriptions = invalid
+		update(t, conn)
+		if !reflect.DeepEqual(sortedKeys(mqttClient.subscriptions), []string{"q/1", "q/2", "q/3"}) {
+			t.Error("unexpected subscriptions", sortedKeys(mqttClient.subscriptions))
+		}
+	})
+
+	t.Run("restart with quarantined device", func(t *testing.T) {
+		descriptions := invalid
+		conn, mqttClient := start(t, &descriptions)
+		update(t, conn)
+		if !reflect.DeepEqual(sortedKeys(mqttClient.subscriptions), []string{"q/3"}) {
+			t.Error("unexpected subscriptions", sortedKeys(mqttClient.subscriptions))
+		}
+		descriptions = valid
+		update(t, conn)
+		if !reflect.DeepEqual(sortedKeys(mqttClient.subscriptions), []string{"q/1", "q/2", "q/3"}) {
+			t.Error("unexpected subscriptions", sortedKeys(mqttClient.subscriptions))
+		}
+	})
+}
+
+func TestQuarantineKeepsCommandListener(t *testing.T) {
+	descriptions := []model.TopicDescription{
+		{CmdTopic: "c/1", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		{StatusTopic: "status/1", DeviceLocalId: "d1", DeviceName: "a", DeviceTypeId: "dt"},
+	}
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+	conn, err := NewWithFactories(context.Background(), configuration.Config{DeleteDevices: true}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		return descriptions, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.updateTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//d1 is quarantined because of conflicting names; its previous status topic is used by the new device d2
+	descriptions = []model.TopicDescription{
+		{CmdTopic: "c/1", DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+		{EventTopic: "q/1", DeviceLocalId: "d1", DeviceName: "b", ServiceLocalId: "s2", DeviceTypeId: "dt"},
+		{EventTopic: "q/2", StatusTopic: "status/1", DeviceLocalId: "d2", DeviceName: "c", ServiceLocalId: "s1", DeviceTypeId: "dt"},
+	}
+	err = conn.updateTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sortedKeys(mgwClient.devices), []string{"d1", "d2"}) {
+		t.Error("unexpected mgw devices", sortedKeys(mgwClient.devices))
+	}
+	if !reflect.DeepEqual(sortedKeys(mgwClient.listening), []string{"d1", "d2"}) {
+		t.Error("unexpected command listeners", sortedKeys(mgwClient.listening))
+	}
+	if _, ok := conn.devices.Get("d1"); !ok {
+		t.Error("expected quarantined device in device register")
+	}
+
+	t.Run("still quarantined", func(t *testing.T) {
+		err = conn.updateTopics()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sortedKeys(mgwClient.listening), []string{"d1", "d2"}) {
+			t.Error("unexpected command listeners", sortedKeys(mgwClient.listening))
+		}
+		if _, ok := conn.devices.Get("d1"); !ok {
+			t.Error("expected quarantined device in device register")
+		}
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		descriptions = descriptions[2:]
+		err = conn.updateTopics()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sortedKeys(mgwClient.devices), []string{"d2"}) {
+			t.Error("unexpected mgw devices", sortedKeys(mgwClient.devices))
+		}
+		if !reflect.DeepEqual(sortedKeys(mgwClient.listening), []string{"d2"}) {
+			t.Error("unexpected command listeners", sortedKeys(mgwClient.listening))
+		}
+	})
+}
+
+func TestValidateTransformationDetails(t *testing.T) {
+	transformations := []model.Transformation{
+		{Transformation: TransformerValueMap, Path: "state", Details: `{"map": {"1": "on", "0": "off"}, "device_type": "number"}`},
+		{Transformation: TransformerValueMap, Path: "state", Details: `{"map": {"on": true}`},
+		{Transformation: TransformerValueMap, Path: "state", Details: `{"map": {"on": true}, "unknown": "drop"}`},
+		{Transformation: TransformerValueMap, Path: "state", Details: `{"map": {"on": true}, "device_type": "int"}`},
+		{Transformation: TransformerValueMap, Path: "state", Details: `{"map": {"on": true}, "device_type": "number"}`},
+		{Transformation: TransformerExpression, Path: "energy", Details: `{"formula": "round(value / 1000, 2)", "inverse": "value * 1000"}`},
+		{Transformation: TransformerExpression, Path: "energy", Details: `{"formula": "value / 1000"`},
+		{Transformation: TransformerExpression, Path: "energy", Details: `{"formula": "value / (1000"}`},
+		{Transformation: TransformerExpression, Path: "energy", Details: `{"formula": "value / 1000", "inverse": "value *"}`},
+		{Transformation: TransformerBinaryLayout, Path: "temp", Details: `{"offset": 0, "length": 2, "type": "int", "scale": 0.1}`},
+		{Transformation: TransformerBinaryLayout, Path: "temp", Details: `{"offset": 0, "type": "float", "length": 2}`},
+		{Transformation: TransformerBinaryLayout, Path: "temp", Details: `{"offset": -1, "type": "int"}`},
+		{Transformation: TransformerBinaryLayout, Path: "temp", Details: `{"offset": 0, "type": "double"}`},
+	}
+	topics := []TopicDescription{}
+	for i, transformation := range transformations {
+		topics = append(topics, model.TopicDescription{EventTopic: "e/" + strconv.Itoa(i), DeviceLocalId: "d1", DeviceName: "a", ServiceLocalId: "s" + strconv.Itoa(i), DeviceTypeId: "dt", Transformations: []model.Transformation{transformation}})
+	}
+	indexes := []int{}
+	for _, problem := range ValidateTopicDescriptions(configuration.Config{}, topics) {
+		indexes = append(indexes, problem.Index)
+	}
+	if !reflect.DeepEqual(indexes, []int{1, 2, 3, 4, 6, 7, 8, 10, 11, 12}) {
+		t.Error("unexpected problems", indexes)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+const ValueMapUnknownPass = "pass"
+const ValueMapUnknownError = "error"
+
+// json types of device values in commands; by default, device values looking like numbers, bools or null are restored to their json type
+const (
+	ValueMapDeviceTypeString = "string"
+	ValueMapDeviceTypeNumber = "number"
+	ValueMapDeviceTypeBool   = "bool"
+)
+
+// ValueMap is the details of a value-map transformation
+type ValueMap struct {
+	Map        map[string]interface{} `json:"map"`         //device value -> platform value
+	Unknown    string                 `json:"unknown"`     //ValueMapUnknownPass (default) or ValueMapUnknownError
+	DeviceType string                 `json:"device_type"` //json type of device values in commands: ValueMapDeviceTypeString, ValueMapDeviceTypeNumber, ValueMapDeviceTypeBool or "" (derived from the value)
+}
+
+// handleValueMapTransformations maps device values to platform values or, with reverse, platform values back to device values.
+// maps contains the json encoded ValueMap of each dot separated path ("" for the whole payload, which may be a raw string)
+func (this *Connector) handleValueMapTransformations(maps map[string]string, payload []byte, reverse bool) ([]byte, error) {
+	if len(maps) == 0 {
+		return payload, nil
+	}
+	var value interface{}
+	err := json.Unmarshal(payload, &value)
+	if err != nil {
+		if _, ok := maps[""]; !ok || reverse {
+			return nil, fmt.Errorf("payload is not valid json: %w", err)
+		}
+		value = string(payload)
+	}
+	paths := []string{}
+	for path := range maps {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		valueMap, err := parseValueMap(maps[path])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value-map for %v: %w", path, err)
+		}
+		if path == "" {
+			value, err = valueMap.apply(value, reverse)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		fieldValue, ok := getJsonPath(value, path)
+		if !ok {
+			continue
+		}
+		fieldValue, err = valueMap.apply(fieldValue, reverse)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		value, err = setJsonPath(value, path, fieldValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if reverse {
+		if _, ok := maps[""]; ok {
+			//the whole payload is a device value and sent as raw string
+			if text, ok := value.(string); ok {
+				return []byte(text), nil
+			}
+		}
+	}
+	return json.Marshal(value)
+}
+
+// parseValueMap decodes the details of a value-map transformation and checks its settings and device values
+func parseValueMap(details string) (result ValueMap, err error) {
+	err = json.Unmarshal([]byte(details), &result)
+	if err != nil {
+		return result, err
+	}
+	switch result.Unknown {
+	case ValueMapUnknownPass, ValueMapUnknownError, "":
+	default:
+		return result, fmt.Errorf("invalid value-map unknown handling %v", result.Unknown)
+	}
+	switch result.DeviceType {
+	case ValueMapDeviceTypeString, ValueMapDeviceTypeNumber, ValueMapDeviceTypeBool, "":
+	default:
+		return result, fmt.Errorf("invalid value-map device_type %v", result.DeviceType)
+	}
+	for key := range result.Map {
+		_, err = result.deviceValue(key)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (this ValueMap) apply(value interface{}, reverse bool) (interface{}, error) {
+	if reverse {
+		return this.toDevice(value)
+	}
+	return this.toPlatform(value)
+}
+
+func (this ValueMap) toPlatform(value interface{}) (interface{}, error) {
+	key := valueMapKey(value)
+	if result, ok := this.Map[key]; ok {
+		return result, nil
+	}
+	return this.unknown(value)
+}
+
+func (this ValueMap) toDevice(value interface{}) (interface{}, error) {
+	keys := []string{}
+	for key := range this.Map {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if reflect.DeepEqual(this.Map[key], value) {
+			return this.deviceValue(key)
+		}
+	}
+	return this.unknown(value)
+}
+
+// deviceValue converts the device value, declared as string key of the map, to the device type
+func (this ValueMap) deviceValue(key string) (interface{}, error) {
+	switch this.DeviceType {
+	case ValueMapDeviceTypeString:
+		return key, nil
+	case ValueMapDeviceTypeNumber:
+		result, err := strconv.ParseFloat(key, 64)
+		if err != nil {
+			return nil, fmt.Errorf("device value %v is not a number", key)
+		}
+		return result, nil
+	case ValueMapDeviceTypeBool:
+		result, err := strconv.ParseBool(key)
+		if err != nil {
+			return nil, fmt.Errorf("device value %v is not a bool", key)
+		}
+		return result, nil
+	case "":
+		//numbers, bools and null are restored
+		var result interface{}
+		if json.Unmarshal([]byte(key), &result) != nil {
+			return key, nil
+		}
+		switch result.(type) {
+		case float64, bool, nil:
+			return result, nil
+		default:
+			return key, nil
+		}
+	default:
+		return nil, fmt.Errorf("invalid value-map device_type %v", this.DeviceType)
+	}
+}
+
+func (this ValueMap) unknown(value interface{}) (interface{}, error) {
+	switch this.Unknown {
+	case ValueMapUnknownPass, "":
+		return value, nil
+	case ValueMapUnknownError:
+		return nil, fmt.Errorf("unknown value %v", valueMapKey(value))
+	default:
+		return nil, fmt.Errorf("invalid value-map unknown handling %v", this.Unknown)
+	}
+}
+
+// valueMapKey returns strings unchanged and the json encoding of other values
+func valueMapKey(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
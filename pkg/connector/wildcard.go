@@ -0,0 +1,335 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+)
+
+const TopicCaptureDevice = "device"
+const TopicCaptureService = "service"
+
+// TopicPattern is a mqtt topic filter which may contain the wildcards '+' and '#'
+// and named single level captures like '{device}' and '{service}'
+type TopicPattern struct {
+	Topic        string
+	Subscription string
+	segments     []string
+}
+
+func IsTopicPattern(topic string) bool {
+	return strings.ContainsAny(topic, "+#{}")
+}
+
+func ParseTopicPattern(topic string) (result TopicPattern, err error) {
+	if topic == "" {
+		return result, errors.New("empty topic")
+	}
+	result = TopicPattern{Topic: topic, segments: strings.Split(topic, "/")}
+	subscription := []string{}
+	usedCaptures := map[string]bool{}
+	for i, segment := range result.segments {
+		switch {
+		case segment == "#":
+			if i != len(result.segments)-1 {
+				return result, errors.New("'#' wildcard must be the last topic level: " + topic)
+			}
+			subscription = append(subscription, segment)
+		case segment == "+":
+			subscription = append(subscription, segment)
+		case isCaptureSegment(segment):
+			name := segment[1 : len(segment)-1]
+			if name == "" {
+				return result, errors.New("empty capture name in topic: " + topic)
+			}
+			if usedCaptures[name] {
+				return result, errors.New("capture {" + name + "} is used more than once in topic: " + topic)
+			}
+			usedCaptures[name] = true
+			subscription = append(subscription, "+")
+		case strings.ContainsAny(segment, "+#{}"):
+			return result, errors.New("wildcards and captures must occupy a whole topic level: " + topic)
+		default:
+			subscription = append(subscription, segment)
+		}
+	}
+	result.Subscription = strings.Join(subscription, "/")
+	return result, nil
+}
+
+func isCaptureSegment(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") && len(segment) >= 2
+}
+
+func (this TopicPattern) IsWildcard() bool {
+	return this.Topic != this.Subscription || strings.ContainsAny(this.Topic, "+#")
+}
+
+func (this TopicPattern) HasCapture(name string) bool {
+	return slices.Contains(this.segments, "{"+name+"}")
+}
+
+// Match checks if the concrete topic matches the pattern and returns the values of named captures
+func (this TopicPattern) Match(topic string) (captures map[string]string, ok bool) {
+	levels := strings.Split(topic, "/")
+	captures = map[string]string{}
+	for i, segment := range this.segments {
+		if segment == "#" {
+			return captures, true
+		}
+		if i >= len(levels) {
+			return nil, false
+		}
+		switch {
+		case segment == "+":
+		case isCaptureSegment(segment):
+			if levels[i] == "" {
+				return nil, false
+			}
+			captures[segment[1:len(segment)-1]] = levels[i]
+		case segment != levels[i]:
+			return nil, false
+		}
+	}
+	if len(levels) != len(this.segments) {
+		return nil, false
+	}
+	return captures, true
+}
+
+// Overlaps checks if at least one concrete topic could match both patterns
+func (this TopicPattern) Overlaps(other TopicPattern) bool {
+	for i := 0; i < len(this.segments) && i < len(other.segments); i++ {
+		a := this.segments[i]
+		b := other.segments[i]
+		if a == "#" || b == "#" {
+			return true
+		}
+		if isSingleLevelWildcard(a) || isSingleLevelWildcard(b) {
+			continue
+		}
+		if a != b {
+			return false
+		}
+	}
+	switch {
+	case len(this.segments) == len(other.segments):
+		return true
+	case len(this.segments) == len(other.segments)+1:
+		//'a/#' matches 'a'
+		return this.segments[len(this.segments)-1] == "#"
+	case len(other.segments) == len(this.segments)+1:
+		return other.segments[len(other.segments)-1] == "#"
+	default:
+		return false
+	}
+}
+
+func isSingleLevelWildcard(segment string) bool {
+	return segment == "+" || isCaptureSegment(segment)
+}
+
+// resolvedTopicDescription is a TopicDescription with a wildcard event topic,
+// where device and service id have been resolved for a concrete device
+type resolvedTopicDescription struct {
+	TopicDescription
+	localDeviceId  string
+	localServiceId string
+}
+
+func resolveTopicDescription(desc TopicDescription, deviceId string, serviceId string) TopicDescription {
+	if deviceId == "" {
+		deviceId = desc.GetLocalDeviceId()
+	}
+	if serviceId == "" {
+		serviceId = desc.GetLocalServiceId()
+	}
+	return resolvedTopicDescription{
+		TopicDescription: desc,
+		localDeviceId:    deviceId,
+		localServiceId:   serviceId,
+	}
+}
+
+func (this resolvedTopicDescription) GetLocalDeviceId() string {
+	return this.localDeviceId
+}
+
+func (this resolvedTopicDescription) GetLocalServiceId() string {
+	return this.localServiceId
+}
+
+func (this resolvedTopicDescription) GetDeviceName() string {
+	return strings.ReplaceAll(this.TopicDescription.GetDeviceName(), "{"+TopicCaptureDevice+"}", this.localDeviceId)
+}
+
+func (this resolvedTopicDescription) GetStatusTopic() string {
+	return strings.ReplaceAll(this.TopicDescription.GetStatusTopic(), "{"+TopicCaptureDevice+"}", this.localDeviceId)
+}
+
+// capturesDevice checks if the device id of the description is extracted from the event topic
+func capturesDevice(desc TopicDescription) bool {
+	event := desc.GetEventTopic()
+	if event == "" || !IsTopicPattern(event) {
+		return false
+	}
+	pattern, err := ParseTopicPattern(event)
+	if err != nil {
+		return false
+	}
+	return pattern.HasCapture(TopicCaptureDevice)
+}
+
+// getDeviceDescriptions returns the concrete devices a topic description refers to.
+// descriptions capturing the device id from the event topic refer to their declared devices
+// and the devices learned from received events
+func (this *Connector) getDeviceDescriptions(desc TopicDescription) (result []TopicDescription) {
+	if !capturesDevice(desc) {
+		return []TopicDescription{desc}
+	}
+	for _, deviceId := range desc.GetDevices() {
+		result = append(result, resolveTopicDescription(desc, deviceId, ""))
+	}
+	for deviceId, learned := range this.learnedDevices.GetAll() {
+		if learned.GetEventTopic() == desc.GetEventTopic() && !slices.Contains(desc.GetDevices(), deviceId) {
+			result = append(result, resolveTopicDescription(desc, deviceId, ""))
+		}
+	}
+	return result
+}
+
+// getEventDescription finds the topic description for a received event topic
+// and resolves captured device and service ids
+func (this *Connector) getEventDescription(topic string) (desc TopicDescription, ok bool) {
+	desc, ok = this.eventTopicRegister.Get(topic)
+	if ok {
+		return desc, true
+	}
+	for key, pattern := range this.eventPatternRegister.GetAll() {
+		captures, match := pattern.Match(topic)
+		if !match {
+			continue
+		}
+		desc, ok = this.eventTopicRegister.Get(key)
+		if !ok {
+			continue
+		}
+		deviceId := captures[TopicCaptureDevice]
+		if deviceId != "" && len(desc.GetDevices()) > 0 && !slices.Contains(desc.GetDevices(), deviceId) {
+			if this.config.Debug {
+				log.Println("DEBUG: ignore event of undeclared device", topic, deviceId)
+			}
+			return desc, false
+		}
+		return resolveTopicDescription(desc, deviceId, captures[TopicCaptureService]), true
+	}
+	return desc, false
+}
+
+// learnDevice registers devices whose ids are captured from event topics and are not known yet.
+// known devices are checked without the update lock, which is only taken to learn a new device
+func (this *Connector) learnDevice(desc TopicDescription) {
+	if !capturesDevice(desc) {
+		return
+	}
+	deviceId := desc.GetLocalDeviceId()
+	if slices.Contains(desc.GetDevices(), deviceId) {
+		return
+	}
+	if this.isKnownDevice(deviceId) {
+		return
+	}
+	this.updateTopicsMux.Lock()
+	defer this.updateTopicsMux.Unlock()
+	if this.isKnownDevice(deviceId) {
+		//learned by a concurrent event while waiting for the lock
+		return
+	}
+	resolved, ok := desc.(resolvedTopicDescription)
+	if !ok {
+		return
+	}
+	if current, ok := this.eventTopicRegister.Get(desc.GetEventTopic()); !ok || !EqualTopicDesc(current, resolved.TopicDescription) {
+		//topic description has been removed or changed in the meantime
+		return
+	}
+	log.Println("learned new device", deviceId, "from", desc.GetEventTopic())
+	state := this.getDeviceState(desc)
+	err := this.mgwClient.SetDevice(deviceId, desc.GetDeviceName(), desc.GetDeviceTypeId(), string(state))
+	if err != nil {
+		log.Println("ERROR: unable to send device info to mgw", err)
+		this.mgwClient.SendClientError("unable to send device info to mgw: " + err.Error())
+		return
+	}
+	//learned devices have no command descriptions; commands are only listened to if a description adds them later
+	this.learnedDevices.Set(deviceId, desc)
+	this.setRegisteredDevices()
+	this.liveness.Add(deviceId, this.getLivenessTimeout(desc))
+	if statusTopic := desc.GetStatusTopic(); statusTopic != "" {
+		if _, registered := this.statusTopicRegister.Get(statusTopic); !registered {
+			err = this.addStatus(desc)
+			if err != nil {
+				log.Println("ERROR: unable to subscribe to status topic of learned device", statusTopic, err)
+				this.mgwClient.SendClientError("unable to subscribe to status topic of learned device: " + err.Error())
+			}
+		}
+	}
+}
+
+// isLearnedOnly checks if the device description has been resolved for a learned device;
+// such devices are not described by any command description and do not listen to commands
+func (this *Connector) isLearnedOnly(desc TopicDescription) bool {
+	if _, resolved := desc.(resolvedTopicDescription); !resolved {
+		return false
+	}
+	deviceId := desc.GetLocalDeviceId()
+	if slices.Contains(desc.GetDevices(), deviceId) {
+		return false
+	}
+	_, learned := this.learnedDevices.Get(deviceId)
+	return learned
+}
+
+func (this *Connector) isKnownDevice(deviceId string) bool {
+	if _, known := this.learnedDevices.Get(deviceId); known {
+		return true
+	}
+	_, registered := this.devices.Get(deviceId)
+	return registered
+}
+
+// restoreLearnedDevices learns the registered devices again, which have been learned from a wildcard event topic
+// before the last shutdown, if the topic is still described; otherwise they are handled like removed devices
+func (this *Connector) restoreLearnedDevices(events []TopicDescription) {
+	for deviceId, device := range this.registeredDevices {
+		if device.LearnedFrom == "" {
+			continue
+		}
+		if _, known := this.learnedDevices.Get(deviceId); known {
+			continue
+		}
+		for _, desc := range events {
+			if desc.GetEventTopic() == device.LearnedFrom && capturesDevice(desc) && len(desc.GetDevices()) == 0 {
+				this.learnedDevices.Set(deviceId, resolveTopicDescription(desc, deviceId, ""))
+				break
+			}
+		}
+	}
+}
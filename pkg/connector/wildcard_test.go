@@ -0,0 +1,173 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"reflect"
+	"testing"
+)
+
+func TestTopicPatternMatch(t *testing.T) {
+	type testCase struct {
+		pattern  string
+		topic    string
+		match    bool
+		captures map[string]string
+	}
+	for _, tc := range []testCase{
+		{pattern: "sensors/{device}/{service}", topic: "sensors/d1/temp", match: true, captures: map[string]string{"device": "d1", "service": "temp"}},
+		{pattern: "sensors/{device}/{service}", topic: "sensors/d1", match: false},
+		{pattern: "sensors/{device}/{service}", topic: "sensors/d1/temp/foo", match: false},
+		{pattern: "sensors/{device}/{service}", topic: "sensors//temp", match: false},
+		{pattern: "sensors/+/{device}", topic: "sensors/foo/d2", match: true, captures: map[string]string{"device": "d2"}},
+		{pattern: "sensors/{device}/#", topic: "sensors/d3/a/b/c", match: true, captures: map[string]string{"device": "d3"}},
+		{pattern: "sensors/{device}/#", topic: "sensors/d3", match: true, captures: map[string]string{"device": "d3"}},
+		{pattern: "sensors/{device}/#", topic: "other/d3/a", match: false},
+		{pattern: "sensors/d1/temp", topic: "sensors/d1/temp", match: true, captures: map[string]string{}},
+	} {
+		pattern, err := ParseTopicPattern(tc.pattern)
+		if err != nil {
+			t.Error(tc.pattern, err)
+			continue
+		}
+		captures, match := pattern.Match(tc.topic)
+		if match != tc.match {
+			t.Error(tc.pattern, tc.topic, match)
+			continue
+		}
+		if match && !reflect.DeepEqual(captures, tc.captures) {
+			t.Error(tc.pattern, tc.topic, captures, tc.captures)
+		}
+	}
+}
+
+func TestTopicPatternParse(t *testing.T) {
+	for topic, expected := range map[string]string{
+		"sensors/{device}/{service}": "sensors/+/+",
+		"sensors/+/x/#":              "sensors/+/x/#",
+		"sensors/d1/temp":            "sensors/d1/temp",
+	} {
+		pattern, err := ParseTopicPattern(topic)
+		if err != nil {
+			t.Error(topic, err)
+			continue
+		}
+		if pattern.Subscription != expected {
+			t.Error(topic, pattern.Subscription, expected)
+		}
+	}
+	for _, topic := range []string{"a/#/b", "a/{}/b", "a/{device}/{device}", "a/x{device}/b", "a/b+", ""} {
+		_, err := ParseTopicPattern(topic)
+		if err == nil {
+			t.Error("expected error for", topic)
+		}
+	}
+}
+
+func TestTopicPatternOverlaps(t *testing.T) {
+	type testCase struct {
+		a        string
+		b        string
+		overlaps bool
+	}
+	for _, tc := range []testCase{
+		{a: "sensors/{device}/{service}", b: "sensors/d1/temp", overlaps: true},
+		{a: "sensors/{device}/{service}", b: "sensors/+/temp", overlaps: true},
+		{a: "sensors/{device}/{service}", b: "sensors/d1", overlaps: false},
+		{a: "sensors/{device}/temp", b: "sensors/{device}/hum", overlaps: false},
+		{a: "sensors/#", b: "sensors/a/b/c", overlaps: true},
+		{a: "sensors/#", b: "sensors", overlaps: true},
+		{a: "sensors/#", b: "other/a", overlaps: false},
+		{a: "#", b: "other/a", overlaps: true},
+		{a: "a/+/c", b: "a/b/+", overlaps: true},
+		{a: "a/+/c", b: "a/b/d", overlaps: false},
+	} {
+		a, err := ParseTopicPattern(tc.a)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		b, err := ParseTopicPattern(tc.b)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if a.Overlaps(b) != tc.overlaps || b.Overlaps(a) != tc.overlaps {
+			t.Error(tc.a, tc.b, tc.overlaps)
+		}
+	}
+}
+
+func TestLearnedDeviceCommandListener(t *testing.T) {
+	descriptions := []model.TopicDescription{
+		{EventTopic: "sensors/{device}/temp", DeviceLocalId: "{device}", DeviceName: "sensor {device}", ServiceLocalId: "temp", DeviceTypeId: "dt"},
+	}
+	mgwClient := &recordingMgw{devices: map[string]string{}, listening: map[string]bool{}}
+	conn, err := NewWithFactories(context.Background(), configuration.Config{DeleteDevices: true}, NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		return descriptions, nil
+	}), func(ctx context.Context, config configuration.Config, refreshNotifier func()) (MgwClient, error) {
+		return mgwClient, nil
+	}, func(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (MqttClient, error) {
+		return &recordingMqtt{subscriptions: map[string]bool{}, failures: map[string]int{}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := func(t *testing.T) {
+		t.Helper()
+		err := conn.updateTopics()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	update(t)
+	desc, ok := conn.getEventDescription("sensors/s1/temp")
+	if !ok {
+		t.Fatal("missing event description")
+	}
+	conn.learnDevice(desc)
+	update(t)
+	if !reflect.DeepEqual(sortedKeys(mgwClient.devices), []string{"s1"}) {
+		t.Error("unexpected mgw devices", sortedKeys(mgwClient.devices))
+	}
+	if len(mgwClient.listening) != 0 {
+		t.Error("learned devices without command descriptions should not listen to commands", sortedKeys(mgwClient.listening))
+	}
+
+	t.Run("command description of learned device", func(t *testing.T) {
+		descriptions = append(descriptions, model.TopicDescription{CmdTopic: "sensors/s1/set", DeviceLocalId: "s1", DeviceName: "sensor s1", ServiceLocalId: "set", DeviceTypeId: "dt"})
+		update(t)
+		if !reflect.DeepEqual(sortedKeys(mgwClient.listening), []string{"s1"}) {
+			t.Error("unexpected command listeners", sortedKeys(mgwClient.listening))
+		}
+	})
+
+	t.Run("command description removed", func(t *testing.T) {
+		descriptions = descriptions[:1]
+		update(t)
+		if !reflect.DeepEqual(sortedKeys(mgwClient.devices), []string{"s1"}) {
+			t.Error("unexpected mgw devices", sortedKeys(mgwClient.devices))
+		}
+		if len(mgwClient.listening) != 0 {
+			t.Error("unexpected command listeners", sortedKeys(mgwClient.listening))
+		}
+	})
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package integrationtests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/api"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/connector"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/integrationtests/docker"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/integrationtests/mocks"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestApi(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mqttPort, _, err := docker.Mqtt(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf := configuration.Config{
+		ConnectorId:         "test",
+		MgwMqttBroker:       "tcp://localhost:" + mqttPort,
+		MgwMqttClientId:     "mgwclientid",
+		Debug:               true,
+		MqttCmdClientId:     "mqttcmdclientid",
+		MqttEventClientId:   "mqtteventclientid",
+		MqttBroker:          "tcp://localhost:" + mqttPort,
+		MaxCorrelationIdAge: "1m",
+	}
+
+	conn, err := connector.NewWithFactories(ctx, conf, connector.NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]mocks.TopicDesc, error) {
+		return []mocks.TopicDesc{
+			{
+				DeviceName: "d1",
+				DeviceType: "dt1",
+				DeviceId:   "1",
+				ServiceId:  "1",
+				EventTopic: "1/1",
+			},
+			{
+				DeviceName: "d2",
+				DeviceType: "dt1",
+				DeviceId:   "2",
+				ServiceId:  "1",
+				CmdTopic:   "2/1/cmd",
+				RespTopic:  "2/1/resp",
+			},
+		}, nil
+	}), connector.NewMgwFactory(mgw.New), connector.NewMqttFactory(mqtt.New))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	server := httptest.NewServer(api.New(conf, conn))
+	defer server.Close()
+
+	mgwClient, err := mqtt.New(ctx, conf.MgwMqttBroker, "testmgw", "", "", false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(1 * time.Second)
+
+	err = mgwClient.Publish("command/2/1", 2, false, []byte(`{"command_id":"c1","data":"foo"}`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	eventClient, err := mqtt.New(ctx, conf.MqttBroker, "testevents", "", "", false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = eventClient.Publish("1/1", 2, false, []byte(`{"value":1}`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	t.Run("registries", func(t *testing.T) {
+		result := connector.RegistryInfo{}
+		err = apiGet(server.URL+"/registries", &result)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if result.EventTopics["1/1"].DeviceId != "1" || result.ResponseTopics["2/1/resp"].DeviceId != "2" || len(result.Commands) != 1 || len(result.Devices) != 2 {
+			t.Errorf("%#v", result)
+		}
+	})
+
+	t.Run("correlations", func(t *testing.T) {
+		result := map[string][]connector.CorrelationInfo{}
+		err = apiGet(server.URL+"/correlations", &result)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(result["2/1"]) != 1 || result["2/1"][0].CommandId != "c1" {
+			t.Errorf("%#v", result)
+		}
+	})
+
+	t.Run("states", func(t *testing.T) {
+		result := map[string]connector.DeviceStateInfo{}
+		err = apiGet(server.URL+"/states", &result)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(result) != 2 || result["1"].State != mgw.Online || result["2"].State != mgw.Online {
+			t.Errorf("%#v", result)
+		}
+	})
+
+	t.Run("last update", func(t *testing.T) {
+		result := connector.UpdateResult{}
+		err = apiGet(server.URL+"/updates/last", &result)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if result.Error != "" || result.Devices != 2 || result.Time.IsZero() {
+			t.Errorf("%#v", result)
+		}
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/metrics")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, expected := range []string{
+			"mgw_mqtt_dc_events_received_total 1",
+			"mgw_mqtt_dc_events_forwarded_total 1",
+			"mgw_mqtt_dc_commands_received_total 1",
+			"mgw_mqtt_dc_commands_published_total 1",
+			"mgw_mqtt_dc_update_duration_seconds_count 1",
+			`mgw_mqtt_dc_mqtt_connected{client="mgw"} 1`,
+			`mgw_mqtt_dc_mqtt_connected{client="mqtt_cmd"} 1`,
+			`mgw_mqtt_dc_mqtt_connected{client="mqtt_event"} 1`,
+		} {
+			if !strings.Contains(string(body), expected) {
+				t.Error("missing", expected)
+			}
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		before, _ := conn.GetLastUpdate()
+		resp, err := http.Post(server.URL+"/refresh", "", nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer resp.Body.Close()
+		result := connector.UpdateResult{}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if resp.StatusCode != http.StatusOK || !result.Time.After(before.Time) || result.Devices != 2 {
+			t.Errorf("%v %#v", resp.StatusCode, result)
+		}
+	})
+
+	t.Run("token", func(t *testing.T) {
+		tokenServer := httptest.NewServer(api.New(configuration.Config{ApiToken: "secret"}, conn))
+		defer tokenServer.Close()
+		err = apiGet(tokenServer.URL+"/registries", &connector.RegistryInfo{})
+		if err == nil || err.Error() != "401 Unauthorized" {
+			t.Error(err)
+		}
+		req, err := http.NewRequest(http.MethodGet, tokenServer.URL+"/registries", nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Error(resp.Status)
+		}
+	})
+}
+
+func apiGet(url string, result interface{}) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -26,8 +26,10 @@ import (
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/integrationtests/mocks"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt5"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/util"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -152,6 +154,10 @@ func TestCorrelationIdMatching(t *testing.T) {
 					t.Error(err)
 					return
 				}
+				if !withRespExpectation && desc.RespTopic != "" {
+					errTopic := "error/command/" + cmdObj.CommandId
+					expectedMgwMsg[errTopic] = append(expectedMgwMsg[errTopic], "device response timeout")
+				}
 				if withRespExpectation {
 					respTopic := "response/" + desc.DeviceId + "/" + desc.ServiceId
 					respObj := mgw.Command{
@@ -230,3 +236,309 @@ func TestCorrelationIdMatching(t *testing.T) {
 		}
 	})
 }
+
+func TestPayloadCorrelation(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mqttPort, _, err := docker.Mqtt(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mgwPort, _, err := docker.Mqtt(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf := configuration.Config{
+		ConnectorId:         "test",
+		MgwMqttBroker:       "tcp://localhost:" + mgwPort,
+		MgwMqttClientId:     "mgwclientid",
+		Debug:               true,
+		MqttCmdClientId:     "mqttcmdclientid",
+		MqttEventClientId:   "mqtteventclientid",
+		MqttBroker:          "tcp://localhost:" + mqttPort,
+		MaxCorrelationIdAge: "5s",
+	}
+
+	topicDescriptions := []mocks.TopicDesc{
+		{
+			DeviceName:      "d1",
+			DeviceType:      "dt1",
+			DeviceId:        "1",
+			ServiceId:       "4",
+			CmdTopic:        "d1/s4/cmd",
+			RespTopic:       "d1/s4",
+			CorrelationPath: "meta.id",
+		},
+		{
+			DeviceName:       "d1",
+			DeviceType:       "dt1",
+			DeviceId:         "1",
+			ServiceId:        "5",
+			CmdTopic:         "d1/s5/cmd",
+			RespTopic:        "d1/s5",
+			CorrelationPath:  "meta.id",
+			CorrelationToken: connector.CorrelationTokenGenerated,
+		},
+	}
+
+	mqttClient, err := mqtt.New(ctx, conf.MqttBroker, "testpublisher", "", "", conf.MqttInsecureSkipVerify)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	mqttCommands := util.NewSyncMap[[]map[string]interface{}]()
+	for _, desc := range topicDescriptions {
+		err = mqttClient.Subscribe(desc.CmdTopic, 2, func(topic string, _ bool, payload []byte) {
+			var cmd map[string]interface{}
+			err := json.Unmarshal(payload, &cmd)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mqttCommands.Update(topic, func(commands []map[string]interface{}) []map[string]interface{} {
+				return append(commands, cmd)
+			})
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	mgwMqttClient, err := mqtt.New(ctx, conf.MgwMqttBroker, "testlistener", "", "", conf.MqttInsecureSkipVerify)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	mgwMessages := util.NewSyncMap[[]string]()
+	err = mgwMqttClient.Subscribe("#", 2, func(topic string, _ bool, payload []byte) {
+		if strings.HasPrefix(topic, "response/") || strings.HasPrefix(topic, "error/") {
+			mgwMessages.Update(topic, func(messages []string) []string {
+				return append(messages, string(payload))
+			})
+		}
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = connector.NewWithFactories(ctx, conf, connector.NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]mocks.TopicDesc, error) {
+		return topicDescriptions, nil
+	}), connector.NewMgwFactory(mgw.New), connector.NewMqttFactory(mqtt.New))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(1 * time.Second)
+
+	expectedMgwMsg := map[string][]string{}
+	for _, desc := range topicDescriptions {
+		for i := 0; i < 3; i++ {
+			cmdMsg, _ := json.Marshal(mgw.Command{
+				CommandId: desc.CmdTopic + "_" + strconv.Itoa(i),
+				Data:      `{"value":` + strconv.Itoa(i) + `}`,
+			})
+			err = mgwMqttClient.Publish("command/"+desc.DeviceId+"/"+desc.ServiceId, 2, false, cmdMsg)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}
+
+	time.Sleep(2 * time.Second)
+
+	//respond in reverse order; the device echoes the correlation token
+	for _, desc := range topicDescriptions {
+		commands, _ := mqttCommands.Get(desc.CmdTopic)
+		if len(commands) != 3 {
+			t.Error("unexpected command count", desc.CmdTopic, commands)
+			return
+		}
+		respTopic := "response/" + desc.DeviceId + "/" + desc.ServiceId
+		for i := len(commands) - 1; i >= 0; i-- {
+			//commands may arrive in any order; the value identifies the command
+			value, _ := commands[i]["value"].(float64)
+			commandId := desc.CmdTopic + "_" + strconv.Itoa(int(value))
+			meta, _ := commands[i]["meta"].(map[string]interface{})
+			token, _ := meta["id"].(string)
+			if desc.CorrelationToken == connector.CorrelationTokenGenerated {
+				if token == "" || token == commandId {
+					t.Error("expected generated token", commands[i])
+				}
+			} else if token != commandId {
+				t.Error("expected command id as token", commands[i])
+			}
+			resp := `{"meta":{"id":"` + token + `"},"result":` + strconv.Itoa(int(value)) + `}`
+			err = mqttClient.Publish(desc.RespTopic, 2, false, []byte(resp))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			respMsg, _ := json.Marshal(mgw.Command{
+				CommandId: commandId,
+				Data:      resp,
+			})
+			expectedMgwMsg[respTopic] = append(expectedMgwMsg[respTopic], string(respMsg))
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+
+	//unknown token
+	err = mqttClient.Publish(topicDescriptions[0].RespTopic, 2, false, []byte(`{"meta":{"id":"unknown"}}`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(6 * time.Second)
+	mgwMessages.Do(func(m *map[string][]string) {
+		if !reflect.DeepEqual(*m, expectedMgwMsg) {
+			t.Error("\n", *m, "\n", expectedMgwMsg)
+		}
+	})
+}
+
+func TestMqtt5Correlation(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mqttPort, _, err := docker.Mqtt(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mgwPort, _, err := docker.Mqtt(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf := configuration.Config{
+		ConnectorId:         "test",
+		MgwMqttBroker:       "tcp://localhost:" + mgwPort,
+		MgwMqttClientId:     "mgwclientid",
+		Debug:               true,
+		MqttCmdClientId:     "mqttcmdclientid",
+		MqttEventClientId:   "mqtteventclientid",
+		MqttBroker:          "tcp://localhost:" + mqttPort,
+		MqttProtocolVersion: "5",
+		MaxCorrelationIdAge: "5s",
+	}
+
+	topicDescriptions := []mocks.TopicDesc{
+		{
+			DeviceName:     "d1",
+			DeviceType:     "dt1",
+			DeviceId:       "1",
+			ServiceId:      "4",
+			CmdTopic:       "d1/s4/cmd",
+			RespTopic:      "d1/s4",
+			UserProperties: map[string]string{"foo": "bar"},
+			MessageExpiry:  "10s",
+		},
+	}
+
+	device, err := mqtt5.New(ctx, conf.MqttBroker, "testdevice", "", "", conf.MqttInsecureSkipVerify)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	commandsMux := sync.Mutex{}
+	commands := []mqtt5.Properties{}
+	err = device.SubscribeWithProperties(topicDescriptions[0].CmdTopic, 2, func(topic string, _ bool, payload []byte, properties mqtt5.Properties) {
+		commandsMux.Lock()
+		defer commandsMux.Unlock()
+		commands = append(commands, properties)
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mgwMqttClient, err := mqtt.New(ctx, conf.MgwMqttBroker, "testlistener", "", "", conf.MqttInsecureSkipVerify)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	mgwMessages := util.NewSyncMap[[]string]()
+	err = mgwMqttClient.Subscribe("#", 2, func(topic string, _ bool, payload []byte) {
+		if strings.HasPrefix(topic, "response/") || strings.HasPrefix(topic, "error/") {
+			mgwMessages.Update(topic, func(messages []string) []string {
+				return append(messages, string(payload))
+			})
+		}
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = connector.NewWithFactories(ctx, conf, connector.NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]mocks.TopicDesc, error) {
+		return topicDescriptions, nil
+	}), connector.NewMgwFactory(mgw.New), connector.NewMqttFactory(mqtt5.New))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(1 * time.Second)
+
+	for i := 0; i < 3; i++ {
+		cmdMsg, _ := json.Marshal(mgw.Command{CommandId: "cmd_" + strconv.Itoa(i), Data: "data"})
+		err = mgwMqttClient.Publish("command/1/4", 2, false, cmdMsg)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	time.Sleep(2 * time.Second)
+
+	commandsMux.Lock()
+	received := append([]mqtt5.Properties{}, commands...)
+	commandsMux.Unlock()
+	if len(received) != 3 {
+		t.Error("unexpected command count", len(received))
+		return
+	}
+
+	//respond in reverse order and leave the first received command unanswered
+	//(commands are handled concurrently and may be received in any order)
+	expectedMgwMsg := map[string][]string{}
+	for i := len(received) - 1; i >= 1; i-- {
+		properties := received[i]
+		if properties.ResponseTopic != topicDescriptions[0].RespTopic || properties.UserProperties["foo"] != "bar" || properties.MessageExpiry <= 0 {
+			t.Error("unexpected properties", properties)
+		}
+		resp := "resp_" + string(properties.CorrelationData)
+		err = device.PublishWithProperties(properties.ResponseTopic, 2, false, []byte(resp), mqtt5.Properties{CorrelationData: properties.CorrelationData})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		respMsg, _ := json.Marshal(mgw.Command{CommandId: string(properties.CorrelationData), Data: resp})
+		expectedMgwMsg["response/1/4"] = append(expectedMgwMsg["response/1/4"], string(respMsg))
+		time.Sleep(200 * time.Millisecond)
+	}
+	expectedMgwMsg["error/command/"+string(received[0].CorrelationData)] = []string{"device response timeout"}
+
+	time.Sleep(6 * time.Second)
+	mgwMessages.Do(func(m *map[string][]string) {
+		if !reflect.DeepEqual(*m, expectedMgwMsg) {
+			t.Error("\n", *m, "\n", expectedMgwMsg)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package integrationtests
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/connector"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/integrationtests/docker"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/integrationtests/mocks"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/util"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLivenessTimeout(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mqttPort, _, err := docker.Mqtt(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf := configuration.Config{
+		ConnectorId:         "test",
+		MgwMqttBroker:       "tcp://localhost:" + mqttPort,
+		MgwMqttClientId:     "mgwclientid",
+		Debug:               true,
+		MqttCmdClientId:     "mqttcmdclientid",
+		MqttEventClientId:   "mqtteventclientid",
+		MqttBroker:          "tcp://localhost:" + mqttPort,
+		MaxCorrelationIdAge: "1m",
+		LivenessTimeout:     "2s",
+	}
+
+	mqttClient, err := mqtt.New(ctx, conf.MqttBroker, "testlistener", "", "", conf.MqttInsecureSkipVerify)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	deviceStates := util.NewSyncMap[[]string]()
+	err = mqttClient.Subscribe(mgw.DeviceManagerTopic+"/"+conf.ConnectorId, 2, func(topic string, _ bool, payload []byte) {
+		info := mgw.DeviceInfoUpdate{}
+		err := json.Unmarshal(payload, &info)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		deviceStates.Update(info.DeviceId, func(states []string) []string {
+			return append(states, string(info.Data.State))
+		})
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = connector.NewWithFactories(ctx, conf, connector.NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]mocks.TopicDesc, error) {
+		return []mocks.TopicDesc{
+			{
+				DeviceName: "d1",
+				DeviceType: "dt1",
+				DeviceId:   "1",
+				ServiceId:  "1",
+				EventTopic: "1/1",
+			},
+			{
+				DeviceName:      "d2",
+				DeviceType:      "dt1",
+				DeviceId:        "2",
+				ServiceId:       "1",
+				EventTopic:      "2/1",
+				LivenessTimeout: "-",
+			},
+			{
+				DeviceName:      "d3",
+				DeviceType:      "dt1",
+				DeviceId:        "3",
+				ServiceId:       "1",
+				EventTopic:      "3/1",
+				LivenessTimeout: "1s",
+			},
+			{
+				DeviceName: "d4",
+				DeviceType: "dt1",
+				DeviceId:   "4",
+				ServiceId:  "1",
+				CmdTopic:   "4/1/cmd",
+			},
+		}, nil
+	}), connector.NewMgwFactory(mgw.New), connector.NewMqttFactory(mqtt.New))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(1 * time.Second)
+	err = mqttClient.Publish("1/1", 2, false, []byte("1"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(3 * time.Second)
+	err = mqttClient.Publish("1/1", 2, false, []byte("2"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = mqttClient.Publish("2/1", 2, false, []byte("2"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	expected := map[string][]string{
+		"1": {"online", "offline", "online"},
+		"2": {"online"},
+		"3": {"online", "offline"},
+		"4": {"online"},
+	}
+	deviceStates.Do(func(m *map[string][]string) {
+		if !reflect.DeepEqual(*m, expected) {
+			t.Error("\n", *m, "\n", expected)
+		}
+	})
+}
@@ -19,19 +19,35 @@ package mocks
 import "strings"
 
 type TopicDesc struct {
-	DeviceName      string
-	DeviceType      string
-	DeviceId        string
-	ServiceId       string
-	EventTopic      string
-	CmdTopic        string
-	RespTopic       string
-	Transformations []Transformation
+	DeviceName          string
+	DeviceType          string
+	DeviceId            string
+	ServiceId           string
+	EventTopic          string
+	CmdTopic            string
+	RespTopic           string
+	RespTimeout         string
+	CorrelationPath     string
+	CorrelationToken    string
+	UserProperties      map[string]string
+	MessageExpiry       string
+	LivenessTimeout     string
+	StatusTopic         string
+	StatusPath          string
+	StatusOnlineValues  []string
+	StatusOfflineValues []string
+	Transformations     []Transformation
+	PayloadFormat       string
+	ProtobufDescriptor  string
+	ProtobufMessage     string
+	SparkplugMetrics    []string
+	Devices             []string
 }
 
 type Transformation struct {
 	Path           string
 	Transformation string
+	Details        string
 }
 
 func (this TopicDesc) GetDeviceName() string {
@@ -58,10 +74,70 @@ func (this TopicDesc) GetResponseTopic() string {
 	return this.RespTopic
 }
 
+func (this TopicDesc) GetResponseTimeout() string {
+	return this.RespTimeout
+}
+
+func (this TopicDesc) GetCorrelationPath() string {
+	return this.CorrelationPath
+}
+
+func (this TopicDesc) GetCorrelationToken() string {
+	return this.CorrelationToken
+}
+
+func (this TopicDesc) GetUserProperties() map[string]string {
+	return this.UserProperties
+}
+
+func (this TopicDesc) GetMessageExpiry() string {
+	return this.MessageExpiry
+}
+
+func (this TopicDesc) GetLivenessTimeout() string {
+	return this.LivenessTimeout
+}
+
+func (this TopicDesc) GetStatusTopic() string {
+	return this.StatusTopic
+}
+
+func (this TopicDesc) GetStatusPath() string {
+	return this.StatusPath
+}
+
+func (this TopicDesc) GetStatusOnlineValues() []string {
+	return this.StatusOnlineValues
+}
+
+func (this TopicDesc) GetStatusOfflineValues() []string {
+	return this.StatusOfflineValues
+}
+
 func (this TopicDesc) GetLocalServiceId() string {
 	return this.ServiceId
 }
 
+func (this TopicDesc) GetDevices() []string {
+	return this.Devices
+}
+
+func (this TopicDesc) GetPayloadFormat() string {
+	return this.PayloadFormat
+}
+
+func (this TopicDesc) GetProtobufDescriptor() string {
+	return this.ProtobufDescriptor
+}
+
+func (this TopicDesc) GetProtobufMessage() string {
+	return this.ProtobufMessage
+}
+
+func (this TopicDesc) GetSparkplugMetrics() []string {
+	return this.SparkplugMetrics
+}
+
 func (this TopicDesc) HasTransformations() bool {
 	return len(this.Transformations) > 0
 }
@@ -77,3 +153,13 @@ func (this TopicDesc) GetTransformations(kind string) (result []string) {
 	}
 	return result
 }
+
+func (this TopicDesc) GetTransformationDetails(kind string) (result map[string]string) {
+	result = map[string]string{}
+	for _, trans := range this.Transformations {
+		if trans.Transformation == kind {
+			result[trans.Path] = trans.Details
+		}
+	}
+	return result
+}
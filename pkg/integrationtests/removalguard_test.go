@@ -0,0 +1,176 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package integrationtests
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/api"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/connector"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/integrationtests/docker"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/integrationtests/mocks"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRemovalGuard(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mqttPort, _, err := docker.Mqtt(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf := configuration.Config{
+		ConnectorId:          "test",
+		MgwMqttBroker:        "tcp://localhost:" + mqttPort,
+		MgwMqttClientId:      "mgwclientid",
+		Debug:                true,
+		MqttCmdClientId:      "mqttcmdclientid",
+		MqttEventClientId:    "mqtteventclientid",
+		MqttBroker:           "tcp://localhost:" + mqttPort,
+		DeleteDevices:        true,
+		RemovalGuardMaxCount: 1,
+	}
+
+	descMux := sync.Mutex{}
+	topicDescriptions := []mocks.TopicDesc{}
+	for _, id := range []string{"1", "2", "3", "4"} {
+		topicDescriptions = append(topicDescriptions, mocks.TopicDesc{
+			DeviceName: "d" + id,
+			DeviceType: "dt1",
+			DeviceId:   id,
+			ServiceId:  "s",
+			EventTopic: id + "/s",
+		})
+	}
+
+	mgwClient, err := mqtt.New(ctx, conf.MgwMqttBroker, "testmgw", "", "", false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	deletedDevices := util.NewSyncMap[bool]()
+	err = mgwClient.Subscribe(mgw.DeviceManagerTopic+"/"+conf.ConnectorId, 2, func(topic string, retained bool, payload []byte) {
+		msg := mgw.DeviceInfoUpdate{}
+		_ = json.Unmarshal(payload, &msg)
+		if msg.Method == "delete" {
+			deletedDevices.Set(msg.DeviceId, true)
+		}
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conn, err := connector.NewWithFactories(ctx, conf, connector.NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]mocks.TopicDesc, error) {
+		descMux.Lock()
+		defer descMux.Unlock()
+		return topicDescriptions, nil
+	}), connector.NewMgwFactory(mgw.New), connector.NewMqttFactory(mqtt.New))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	server := httptest.NewServer(api.New(conf, conn))
+	defer server.Close()
+
+	time.Sleep(1 * time.Second)
+
+	descMux.Lock()
+	topicDescriptions = topicDescriptions[:1]
+	descMux.Unlock()
+
+	t.Run("hold back", func(t *testing.T) {
+		conn.RefreshDeviceInfo()
+		time.Sleep(500 * time.Millisecond)
+		held := connector.HeldRemovalsInfo{}
+		err = apiGet(server.URL+"/removals", &held)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if strings.Join(held.Devices, ",") != "2,3,4" {
+			t.Errorf("%#v", held)
+		}
+		if len(deletedDevices.GetKeys()) != 0 {
+			t.Error("unexpected deleted devices", deletedDevices.GetKeys())
+		}
+	})
+
+	t.Run("confirm by control message", func(t *testing.T) {
+		err = mgwClient.Publish(mgw.ControlTopicPrefix+conf.ConnectorId+"/"+connector.ControlConfirmRemovals, 2, false, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		time.Sleep(1 * time.Second)
+		if len(deletedDevices.GetKeys()) != 3 {
+			t.Error("unexpected deleted devices", deletedDevices.GetKeys())
+		}
+		//without api_token removals may only be confirmed by control message
+		resp, err := http.Post(server.URL+"/removals/confirm", "", nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Error("unexpected confirmation status", resp.Status)
+		}
+	})
+
+	t.Run("confirm by api with token", func(t *testing.T) {
+		tokenServer := httptest.NewServer(api.New(configuration.Config{ApiToken: "secret"}, conn))
+		defer tokenServer.Close()
+		req, err := http.NewRequest(http.MethodPost, tokenServer.URL+"/removals/confirm", nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer resp.Body.Close()
+		result := map[string]int{}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if result["confirmed"] != 0 {
+			t.Error("unexpected confirmation result", result)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package integrationtests
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/connector"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/integrationtests/docker"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/integrationtests/mocks"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mqtt"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/util"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatusTopic(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mqttPort, _, err := docker.Mqtt(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	conf := configuration.Config{
+		ConnectorId:         "test",
+		MgwMqttBroker:       "tcp://localhost:" + mqttPort,
+		MgwMqttClientId:     "mgwclientid",
+		Debug:               true,
+		MqttCmdClientId:     "mqttcmdclientid",
+		MqttEventClientId:   "mqtteventclientid",
+		MqttBroker:          "tcp://localhost:" + mqttPort,
+		MaxCorrelationIdAge: "1m",
+	}
+
+	mqttClient, err := mqtt.New(ctx, conf.MqttBroker, "testlistener", "", "", conf.MqttInsecureSkipVerify)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	//last will of device 1 is retained before the connector starts
+	err = mqttClient.Publish("tele/1/LWT", 2, true, []byte("Offline"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	deviceStates := util.NewSyncMap[[]string]()
+	err = mqttClient.Subscribe(mgw.DeviceManagerTopic+"/"+conf.ConnectorId, 2, func(topic string, _ bool, payload []byte) {
+		info := mgw.DeviceInfoUpdate{}
+		err := json.Unmarshal(payload, &info)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		deviceStates.Update(info.DeviceId, func(states []string) []string {
+			return append(states, string(info.Data.State))
+		})
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = connector.NewWithFactories(ctx, conf, connector.NewTopicDescriptionProvider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]mocks.TopicDesc, error) {
+		return []mocks.TopicDesc{
+			{
+				DeviceName:  "d1",
+				DeviceType:  "dt1",
+				DeviceId:    "1",
+				ServiceId:   "1",
+				EventTopic:  "tele/1/SENSOR",
+				StatusTopic: "tele/1/LWT",
+			},
+			{
+				DeviceName:  "d2",
+				DeviceType:  "dt1",
+				DeviceId:    "2",
+				StatusTopic: "2/availability",
+				StatusPath:  "state",
+			},
+			{
+				DeviceName:          "{device}",
+				DeviceType:          "dt1",
+				ServiceId:           "1",
+				EventTopic:          "z2m/{device}",
+				Devices:             []string{"a"},
+				StatusTopic:         "z2m/{device}/availability",
+				StatusOnlineValues:  []string{"up"},
+				StatusOfflineValues: []string{"down"},
+			},
+		}, nil
+	}), connector.NewMgwFactory(mgw.New), connector.NewMqttFactory(mqtt.New))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(1 * time.Second)
+	for _, msg := range []struct {
+		topic   string
+		payload string
+	}{
+		{topic: "tele/1/LWT", payload: "Online"},
+		{topic: "2/availability", payload: `{"state":"online"}`},
+		{topic: "2/availability", payload: `{"state":"offline"}`},
+		{topic: "z2m/a/availability", payload: "down"},
+		{topic: "z2m/a/availability", payload: "unknown"},
+		{topic: "z2m/a/availability", payload: "up"},
+	} {
+		err = mqttClient.Publish(msg.topic, 2, false, []byte(msg.payload))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	expected := map[string][]string{
+		"1": {"online", "offline", "online"},
+		"2": {"online", "offline"},
+		"a": {"online", "offline", "online"},
+	}
+	deviceStates.Do(func(m *map[string][]string) {
+		if !reflect.DeepEqual(*m, expected) {
+			t.Error("\n", *m, "\n", expected)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lint
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/connector"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"io"
+	"os"
+)
+
+const (
+	ExitValid   = 0
+	ExitInvalid = 1
+	ExitFailure = 2
+)
+
+type Problem struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+type Report struct {
+	Dir          string    `json:"dir"`
+	Valid        bool      `json:"valid"`
+	Descriptions int       `json:"descriptions"`
+	Errors       int       `json:"errors"`
+	Warnings     int       `json:"warnings"`
+	Problems     []Problem `json:"problems"`
+}
+
+// Lint loads and validates all topic descriptions in dir like the connector does on startup
+func Lint(config configuration.Config, dir string) (report Report, err error) {
+	descriptions, sources, loadProblems, err := topicdescription.LoadDirWithSources(dir)
+	if err != nil {
+		return report, err
+	}
+	report = Report{Dir: dir, Descriptions: len(descriptions), Problems: []Problem{}}
+	for _, problem := range loadProblems {
+		severity := connector.ValidationError
+		if problem.Warning {
+			severity = connector.ValidationWarning
+		}
+		report.add(Problem{File: problem.File, Line: problem.Line, Severity: severity, Message: problem.Message})
+	}
+	validationProblems := connector.ValidateTopicDescriptions(config, connector.TopicDescriptionsConverter[model.TopicDescription](descriptions))
+	for _, problem := range validationProblems {
+		source := sources[problem.Index]
+		report.add(Problem{File: source.File, Line: source.Line, Severity: problem.Severity, Message: problem.Message})
+	}
+	report.Valid = report.Errors == 0
+	return report, nil
+}
+
+func (this *Report) add(problem Problem) {
+	if problem.Severity == connector.ValidationWarning {
+		this.Warnings++
+	} else {
+		this.Errors++
+	}
+	this.Problems = append(this.Problems, problem)
+}
+
+// Command implements the lint subcommand: lint <dir>
+// the report is written as json to out; the returned exit code is ExitInvalid if the descriptions contain errors
+func Command(configLocation string, args []string, out io.Writer, errOut io.Writer) int {
+	if len(args) != 1 {
+		io.WriteString(errOut, "usage: mgw-mqtt-dc [-config config.json] lint <dir>\n")
+		return ExitFailure
+	}
+	config := configuration.Config{}
+	if _, err := os.Stat(configLocation); err == nil {
+		config, err = configuration.Load(configLocation)
+		if err != nil {
+			io.WriteString(errOut, "unable to load config: "+err.Error()+"\n")
+			return ExitFailure
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		io.WriteString(errOut, "unable to load config: "+err.Error()+"\n")
+		return ExitFailure
+	}
+	report, err := Lint(config, args[0])
+	if err != nil {
+		io.WriteString(errOut, "unable to load topic descriptions: "+err.Error()+"\n")
+		return ExitFailure
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "    ")
+	err = encoder.Encode(report)
+	if err != nil {
+		io.WriteString(errOut, "unable to write report: "+err.Error()+"\n")
+		return ExitFailure
+	}
+	if !report.Valid {
+		return ExitInvalid
+	}
+	return ExitValid
+}
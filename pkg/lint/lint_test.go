@@ -0,0 +1,167 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.json": `[
+    {
+        "event_topic": "sensor/1",
+        "device_type_id": "dt",
+        "device_local_id": "d1",
+        "service_local_id": "s1",
+        "device_name": "d1"
+    },
+    {
+        "event_topic": "sensor/1",
+        "device_type_id": "dt",
+        "device_local_id": "d2",
+        "service_local_id": "s1",
+        "device_name": "d2"
+    }
+]`,
+		"sub/b.yaml": `- cmd_topic: cmd/1
+  device_type_id: dt
+  device_local_id: d1
+  service_local_id: s2
+  device_name: d1
+- cmd_topic: cmd/2
+  resp_topic: cmd/1
+  device_type_id: dt
+  device_local_id: d3
+  service_local_id: s2
+  device_name: d3
+- cmd_topic: cmd/5
+  device_type_id: dt
+  device_local_id: d1
+  service_local_id: s5
+  device_name: other name
+`,
+		"sub/c.csv": `# comment
+cmd/3,,d4,d4,dt,s1
+cmd/4,,d4,d4,dt
+`,
+		"sub/d.json": `[
+    {
+        "event_topic": "sensor/5",
+    }
+]`,
+		"sub/.d.json.swp": `foo`,
+		"e.txt":           `foo`,
+	}
+	for name, content := range files {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := Lint(configuration.Config{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Report{
+		Dir:          dir,
+		Valid:        false,
+		Descriptions: 5,
+		Errors:       5,
+		Warnings:     1,
+		Problems: []Problem{
+			{File: filepath.Join(dir, "e.txt"), Severity: "warning", Message: "unknown file type in topic-descriptions directory"},
+			{File: filepath.Join(dir, "sub/c.csv"), Line: 3, Severity: "error", Message: "invalid cow count (expect 6 or 7 rows)"},
+			{File: filepath.Join(dir, "sub/d.json"), Line: 4, Severity: "error", Message: "invalid character '}' looking for beginning of object key string"},
+			{File: filepath.Join(dir, "a.json"), Line: 9, Severity: "error", Message: "reused event topic: sensor/1"},
+			{File: filepath.Join(dir, "sub/b.yaml"), Line: 6, Severity: "error", Message: "collision between command and response topic: cmd/1"},
+			{File: filepath.Join(dir, "sub/b.yaml"), Line: 12, Severity: "error", Message: "device d1 has multiple name assignments: d1 and other name"},
+		},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		a, _ := json.MarshalIndent(report, "", "  ")
+		e, _ := json.MarshalIndent(expected, "", "  ")
+		t.Error("\n", string(a), "\n", string(e))
+	}
+
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	if code := Command("missing_config.json", []string{dir}, out, errOut); code != ExitInvalid {
+		t.Error(code, errOut.String())
+	}
+	result := Report{}
+	err = json.Unmarshal(out.Bytes(), &result)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Error(result)
+	}
+
+	for _, name := range []string{"e.txt", "sub"} {
+		err = os.RemoveAll(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = os.WriteFile(filepath.Join(dir, "a.json"), []byte(`[{"event_topic": "sensor/1", "device_type_id": "dt", "device_local_id": "d1", "service_local_id": "s1", "device_name": "d1"}]`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := Command("missing_config.json", []string{dir}, &bytes.Buffer{}, errOut); code != ExitValid {
+		t.Error(code, errOut.String())
+	}
+	if code := Command("missing_config.json", []string{}, &bytes.Buffer{}, &bytes.Buffer{}); code != ExitFailure {
+		t.Error(code)
+	}
+}
+
+func TestLintTransformationDetails(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte(`- event_topic: sensor/1
+  device_type_id: dt
+  device_local_id: d1
+  service_local_id: s1
+  device_name: d1
+  transformations:
+    - path: state
+      transformation: value-map
+      details: '{"map": {"on": true}, "device_type": "number"}'
+`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Lint(configuration.Config{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Errors != 1 || len(report.Problems) != 1 || report.Problems[0].Line != 1 {
+		t.Errorf("%#v", report)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+const (
+	DropReasonUnregisteredTopic = "unregistered_topic"
+	DropReasonTransformFailure  = "transform_failure"
+	DropReasonDecodeFailure     = "decode_failure"
+	DropReasonSendFailure       = "send_failure"
+)
+
+const (
+	CommandFailureUnknownDevice  = "unknown_device"
+	CommandFailureTransform      = "transform_failure"
+	CommandFailureEncode         = "encode_failure"
+	CommandFailureCorrelation    = "correlation_failure"
+	CommandFailurePublish        = "publish_failure"
+	CommandFailureEmptyResponse  = "empty_response_failure"
+	ResponseUnmatchedUnknown     = "unknown_correlation"
+	ResponseUnmatchedInvalid     = "invalid_correlation"
+	ResponseUnmatchedTransform   = "transform_failure"
+	ResponseUnmatchedDecode      = "decode_failure"
+	ResponseUnmatchedSendFailure = "send_failure"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	EventsReceived  prometheus.Counter
+	EventsForwarded prometheus.Counter
+	EventsBuffered  prometheus.Counter
+	EventsDropped   *prometheus.CounterVec
+
+	CommandsReceived  prometheus.Counter
+	CommandsPublished prometheus.Counter
+	CommandsFailed    *prometheus.CounterVec
+
+	ResponsesMatched   prometheus.Counter
+	ResponsesUnmatched *prometheus.CounterVec
+
+	CorrelationIdsExpired prometheus.Counter
+
+	UpdateDuration  prometheus.Histogram
+	UpdateFailures  prometheus.Counter
+	UpdateRetries   prometheus.Counter
+	UpdateRollbacks prometheus.Counter
+
+	DeviceStateTransitions *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	reg := prometheus.NewRegistry()
+	result := &Metrics{
+		registry: reg,
+		EventsReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_events_received_total",
+			Help: "events received from the mapped mqtt broker",
+		}),
+		EventsForwarded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_events_forwarded_total",
+			Help: "events sent to the mgw",
+		}),
+		EventsBuffered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_events_buffered_total",
+			Help: "events buffered on disk while the mgw broker is unreachable; replayed events are not counted as forwarded",
+		}),
+		EventsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_events_dropped_total",
+			Help: "events not sent to the mgw, by reason",
+		}, []string{"reason"}),
+		CommandsReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_commands_received_total",
+			Help: "commands received from the mgw",
+		}),
+		CommandsPublished: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_commands_published_total",
+			Help: "commands published to the mapped mqtt broker",
+		}),
+		CommandsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_commands_failed_total",
+			Help: "commands which could not be handled, by reason",
+		}, []string{"reason"}),
+		ResponsesMatched: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_responses_matched_total",
+			Help: "device responses matched to a command and sent to the mgw",
+		}),
+		ResponsesUnmatched: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_responses_unmatched_total",
+			Help: "device responses not sent to the mgw, by reason",
+		}, []string{"reason"}),
+		CorrelationIdsExpired: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_correlation_ids_expired_total",
+			Help: "commands where the device did not respond in time",
+		}),
+		UpdateDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "mgw_mqtt_dc_update_duration_seconds",
+			Help:    "duration of device registry updates",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		}),
+		UpdateFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_update_failures_total",
+			Help: "failed device registry updates",
+		}),
+		UpdateRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_update_retries_total",
+			Help: "retried operations of device registry updates",
+		}),
+		UpdateRollbacks: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_update_rollbacks_total",
+			Help: "device registry updates reverted to the previous state",
+		}),
+		DeviceStateTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mgw_mqtt_dc_device_state_transitions_total",
+			Help: "device online state changes sent to the mgw, by new state",
+		}, []string{"state"}),
+	}
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		result.EventsReceived,
+		result.EventsForwarded,
+		result.EventsBuffered,
+		result.EventsDropped,
+		result.CommandsReceived,
+		result.CommandsPublished,
+		result.CommandsFailed,
+		result.ResponsesMatched,
+		result.ResponsesUnmatched,
+		result.CorrelationIdsExpired,
+		result.UpdateDuration,
+		result.UpdateFailures,
+		result.UpdateRetries,
+		result.UpdateRollbacks,
+		result.DeviceStateTransitions,
+	)
+	return result
+}
+
+// RegisterConnectionState adds a gauge reporting the connection state (1 = connected) of an mqtt client
+func (this *Metrics) RegisterConnectionState(client string, isConnected func() bool) {
+	this.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "mgw_mqtt_dc_mqtt_connected",
+		Help:        "connection state of the mqtt clients (1 = connected)",
+		ConstLabels: prometheus.Labels{"client": client},
+	}, func() float64 {
+		if isConnected() {
+			return 1
+		}
+		return 0
+	}))
+}
+
+func (this *Metrics) ObserveUpdate(start time.Time, err error) {
+	this.UpdateDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		this.UpdateFailures.Inc()
+	}
+}
+
+func (this *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(this.registry, promhttp.HandlerOpts{})
+}
@@ -19,9 +19,11 @@ package mgw
 import (
 	"context"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw/eventbuffer"
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,14 +36,39 @@ type Client struct {
 	subscriptions                map[string]paho.MessageHandler
 	subscriptionsMux             sync.Mutex
 	deviceManagerRefreshNotifier func()
+	eventBuffer                  *eventbuffer.Buffer
+	eventBufferMux               sync.Mutex
+	replaying                    atomic.Bool
+	replayRequested              atomic.Bool
+	publishTimeout               time.Duration
 }
 
+const DefaultPublishTimeout = 10 * time.Second
+
 func New(ctx context.Context, config configuration.Config, refreshNotifier func()) (*Client, error) {
 	client := &Client{
 		connectorId:                  config.ConnectorId,
 		debug:                        config.Debug,
 		deviceManagerRefreshNotifier: refreshNotifier,
 		subscriptions:                map[string]paho.MessageHandler{},
+		publishTimeout:               DefaultPublishTimeout,
+	}
+	if config.EventBufferFile != "" && config.EventBufferFile != "-" {
+		var maxAge time.Duration
+		if config.EventBufferMaxAge != "" && config.EventBufferMaxAge != "-" {
+			var err error
+			maxAge, err = time.ParseDuration(config.EventBufferMaxAge)
+			if err != nil {
+				log.Println("ERROR: unable to parse event buffer max age as duration")
+				return nil, err
+			}
+		}
+		var err error
+		client.eventBuffer, err = eventbuffer.New(config.EventBufferFile, int(config.EventBufferMaxSize), maxAge)
+		if err != nil {
+			log.Println("ERROR: unable to load event buffer", err)
+			return nil, err
+		}
 	}
 	lwt := "device-manager/device/" + config.ConnectorId + "/lw"
 	options := paho.NewClientOptions().
@@ -66,6 +93,7 @@ func New(ctx context.Context, config configuration.Config, refreshNotifier func(
 			if client.deviceManagerRefreshNotifier != nil {
 				client.deviceManagerRefreshNotifier()
 			}
+			go client.replayBufferedEvents()
 		}).SetWill(lwt, "offline", 2, false)
 
 	client.mqtt = paho.NewClient(options)
@@ -77,11 +105,18 @@ func New(ctx context.Context, config configuration.Config, refreshNotifier func(
 	go func() {
 		<-ctx.Done()
 		client.mqtt.Disconnect(0)
+		if client.eventBuffer != nil {
+			client.eventBuffer.Close()
+		}
 	}()
 
 	return client, nil
 }
 
+func (this *Client) IsConnected() bool {
+	return this.mqtt.IsConnectionOpen()
+}
+
 func (this *Client) NotifyDeviceManagerRefresh(f func()) {
 	this.deviceManagerRefreshNotifier = f
 }
@@ -0,0 +1,54 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mgw
+
+import (
+	"errors"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"log"
+	"strings"
+)
+
+const ControlTopicPrefix = "control/"
+
+type ControlHandler func(command string, payload []byte)
+
+// ListenToControlMessages subscribes to control/{connector_id}/{command};
+// the subscription is registered even if it fails, to be (re-)subscribed on the next connect
+func (this *Client) ListenToControlMessages(handler ControlHandler) error {
+	topic := ControlTopicPrefix + this.connectorId + "/+"
+
+	messageHandler := func(client paho.Client, message paho.Message) {
+		if this.debug {
+			log.Println("get control message:", message.Topic(), string(message.Payload()))
+		}
+		parts := strings.Split(message.Topic(), "/")
+		handler(parts[len(parts)-1], message.Payload())
+	}
+	this.registerSubscription(topic, messageHandler)
+
+	if !this.mqtt.IsConnected() {
+		log.Println("WARNING: mqtt client not connected")
+		return errors.New("mqtt client not connected")
+	}
+	token := this.mqtt.Subscribe(topic, 2, messageHandler)
+	if token.Wait() && token.Error() != nil {
+		log.Println("Error on Subscribe: ", topic, token.Error())
+		return token.Error()
+	}
+	return nil
+}
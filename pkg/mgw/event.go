@@ -19,7 +19,10 @@ package mgw
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw/eventbuffer"
 	"log"
+	"time"
 )
 
 func (this *Client) MarshalAndSendEvent(deviceId string, serviceId string, value interface{}) error {
@@ -30,8 +33,70 @@ func (this *Client) MarshalAndSendEvent(deviceId string, serviceId string, value
 	return this.SendEvent(deviceId, serviceId, msg)
 }
 
+// SendEvent publishes the event to the mgw broker.
+// if an event buffer is configured, events which can not be published are buffered and replayed on reconnect
 func (this *Client) SendEvent(deviceId string, serviceId string, msg []byte) error {
-	if !this.mqtt.IsConnected() {
+	_, err := this.SendOrBufferEvent(deviceId, serviceId, msg)
+	return err
+}
+
+// SendOrBufferEvent is SendEvent, reporting if the event has only been buffered and is not yet published
+func (this *Client) SendOrBufferEvent(deviceId string, serviceId string, msg []byte) (buffered bool, err error) {
+	if this.eventBuffer == nil {
+		return false, this.publishEvent(deviceId, serviceId, msg)
+	}
+	this.eventBufferMux.Lock()
+	defer this.eventBufferMux.Unlock()
+	//events are buffered as long as older events wait for replay, to preserve the order
+	if this.eventBuffer.Len() == 0 {
+		err = this.publishEvent(deviceId, serviceId, msg)
+		if err == nil {
+			return false, nil
+		}
+		log.Println("WARNING: unable to send event to mgw; buffer event:", err)
+	}
+	err = this.eventBuffer.Push(eventbuffer.Event{
+		DeviceId:  deviceId,
+		ServiceId: serviceId,
+		Payload:   msg,
+		Time:      time.Now(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to buffer event: %w", err)
+	}
+	if this.mqtt.IsConnectionOpen() {
+		//the connection has been restored without a replay of the on-connect handler (e.g. after a publish timeout)
+		go this.replayBufferedEvents()
+	}
+	return true, nil
+}
+
+// replayBufferedEvents publishes the buffered events in the background;
+// calls while a replay is running are handled by another replay round of the running call
+func (this *Client) replayBufferedEvents() {
+	if this.eventBuffer == nil {
+		return
+	}
+	this.replayRequested.Store(true)
+	for this.replayRequested.Load() && this.replaying.CompareAndSwap(false, true) {
+		this.replayRequested.Store(false)
+		count, err := this.eventBuffer.Replay(func(event eventbuffer.Event) error {
+			return this.publishEvent(event.DeviceId, event.ServiceId, event.Payload)
+		})
+		this.replaying.Store(false)
+		if count > 0 {
+			log.Println("replayed", count, "buffered events")
+		}
+		if err != nil {
+			log.Println("WARNING: unable to replay buffered events:", err)
+		}
+	}
+}
+
+// publishEvent fails if the connection is not open (e.g. while the client reconnects) or the publish is not confirmed within publishTimeout,
+// to prevent events from waiting in the in-memory store of the mqtt client instead of the event buffer
+func (this *Client) publishEvent(deviceId string, serviceId string, msg []byte) error {
+	if !this.mqtt.IsConnectionOpen() {
 		log.Println("WARNING: mqtt client not connected")
 		return errors.New("mqtt client not connected")
 	}
@@ -40,7 +105,11 @@ func (this *Client) SendEvent(deviceId string, serviceId string, msg []byte) err
 		log.Println("DEBUG: publish ", topic, string(msg))
 	}
 	token := this.mqtt.Publish(topic, 2, false, string(msg))
-	if token.Wait() && token.Error() != nil {
+	if !token.WaitTimeout(this.publishTimeout) {
+		log.Println("Error on Client.Publish(): timeout")
+		return errors.New("timeout while publishing event")
+	}
+	if token.Error() != nil {
 		log.Println("Error on Client.Publish(): ", token.Error())
 		return token.Error()
 	}
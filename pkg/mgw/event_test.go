@@ -0,0 +1,171 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mgw
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/mgw/eventbuffer"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSendEventWhileBrokerIsDown(t *testing.T) {
+	buffer, err := eventbuffer.New(filepath.Join(t.TempDir(), "buffer.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mqtt := &reconnectingMqtt{}
+	client := &Client{mqtt: mqtt, eventBuffer: buffer, publishTimeout: 50 * time.Millisecond}
+
+	send := func(t *testing.T, payload string, expectBuffered bool) {
+		t.Helper()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			buffered, err := client.SendOrBufferEvent("d", "s", []byte(payload))
+			if err != nil {
+				t.Error(err)
+			}
+			if buffered != expectBuffered {
+				t.Error("unexpected buffered result", payload, buffered)
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("send event blocks")
+		}
+	}
+
+	t.Run("connected", func(t *testing.T) {
+		mqtt.set(true, false)
+		send(t, "1", false)
+	})
+
+	t.Run("publish timeout", func(t *testing.T) {
+		//paho keeps publishes in its in-memory store while the connection is lost before the reconnect is noticed
+		mqtt.set(true, true)
+		send(t, "2", true)
+	})
+
+	t.Run("reconnecting", func(t *testing.T) {
+		mqtt.set(false, false)
+		send(t, "3", true)
+		send(t, "4", true)
+		if buffer.Len() != 3 {
+			t.Error(buffer.Len())
+		}
+	})
+
+	t.Run("reconnected", func(t *testing.T) {
+		mqtt.set(true, false)
+		send(t, "5", true)
+		time.Sleep(200 * time.Millisecond)
+		if buffer.Len() != 0 {
+			t.Error(buffer.Len())
+		}
+		send(t, "6", false)
+		if !reflect.DeepEqual(mqtt.getPublished(), []string{"1", "2", "3", "4", "5", "6"}) {
+			t.Error(mqtt.getPublished())
+		}
+	})
+}
+
+// reconnectingMqtt simulates a paho client with auto reconnect:
+// IsConnected is true while the client reconnects and publishes may wait until the reconnect
+type reconnectingMqtt struct {
+	paho.Client
+	mux       sync.Mutex
+	open      bool
+	hang      bool
+	published []string
+}
+
+func (this *reconnectingMqtt) set(open bool, hang bool) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.open = open
+	this.hang = hang
+}
+
+func (this *reconnectingMqtt) getPublished() []string {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return append([]string{}, this.published...)
+}
+
+func (this *reconnectingMqtt) IsConnected() bool {
+	return true
+}
+
+func (this *reconnectingMqtt) IsConnectionOpen() bool {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.open
+}
+
+func (this *reconnectingMqtt) Publish(topic string, qos byte, retained bool, payload interface{}) paho.Token {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.hang {
+		return pendingToken{}
+	}
+	this.published = append(this.published, payload.(string))
+	return doneToken{}
+}
+
+type pendingToken struct{}
+
+func (pendingToken) Wait() bool {
+	select {}
+}
+
+func (pendingToken) WaitTimeout(duration time.Duration) bool {
+	time.Sleep(duration)
+	return false
+}
+
+func (pendingToken) Done() <-chan struct{} {
+	return make(chan struct{})
+}
+
+func (pendingToken) Error() error {
+	return nil
+}
+
+type doneToken struct{}
+
+func (doneToken) Wait() bool {
+	return true
+}
+
+func (doneToken) WaitTimeout(time.Duration) bool {
+	return true
+}
+
+func (doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+func (doneToken) Error() error {
+	return nil
+}
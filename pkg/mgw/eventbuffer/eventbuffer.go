@@ -0,0 +1,259 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventbuffer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type Event struct {
+	DeviceId  string    `json:"device_id"`
+	ServiceId string    `json:"service_id"`
+	Payload   []byte    `json:"payload"`
+	Time      time.Time `json:"time"`
+	seq       uint64    //identifies the event while it is replayed without lock
+}
+
+// Buffer is a bounded fifo queue of events, persisted as json lines in a file.
+// If the buffer is full, the oldest event is dropped.
+type Buffer struct {
+	location  string
+	maxSize   int
+	maxAge    time.Duration
+	mux       sync.Mutex
+	events    []Event
+	fileLines int
+	file      *os.File
+	lastSeq   uint64
+}
+
+// New loads the buffer stored in location; maxSize <= 0 and maxAge <= 0 disable the respective limit
+func New(location string, maxSize int, maxAge time.Duration) (result *Buffer, err error) {
+	result = &Buffer{
+		location: location,
+		maxSize:  maxSize,
+		maxAge:   maxAge,
+	}
+	err = result.load()
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (this *Buffer) Len() int {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return len(this.events)
+}
+
+func (this *Buffer) Push(event Event) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	this.lastSeq++
+	event.seq = this.lastSeq
+	this.events = append(this.events, event)
+	if this.applyLimits() {
+		//dropped events are still stored in the file; compact if the file grows too much
+		if this.maxSize > 0 && this.fileLines >= 2*this.maxSize {
+			return this.store()
+		}
+	}
+	return this.append(event)
+}
+
+// Replay sends buffered events in order, until send returns an error.
+// The failed event and all following events remain in the buffer.
+// The buffer is not locked while send is called; events pushed during the replay are replayed as well.
+func (this *Buffer) Replay(send func(event Event) error) (count int, err error) {
+	defer func() {
+		if count == 0 {
+			return
+		}
+		this.mux.Lock()
+		defer this.mux.Unlock()
+		storeErr := this.store()
+		if err == nil {
+			err = storeErr
+		}
+	}()
+	for {
+		this.mux.Lock()
+		this.applyLimits()
+		if len(this.events) == 0 {
+			this.mux.Unlock()
+			return count, nil
+		}
+		event := this.events[0]
+		this.mux.Unlock()
+
+		err = send(event)
+		if err != nil {
+			return count, err
+		}
+		count++
+
+		this.mux.Lock()
+		//the event may have been dropped by the limits of a concurrent push
+		if len(this.events) > 0 && this.events[0].seq == event.seq {
+			this.events = this.events[1:]
+		}
+		this.mux.Unlock()
+	}
+}
+
+func (this *Buffer) Close() error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.file == nil {
+		return nil
+	}
+	err := this.file.Close()
+	this.file = nil
+	return err
+}
+
+// applyLimits drops events exceeding maxSize or maxAge and returns true if events have been dropped
+func (this *Buffer) applyLimits() (dropped bool) {
+	if this.maxSize > 0 && len(this.events) > this.maxSize {
+		log.Println("WARNING: event buffer is full, drop", len(this.events)-this.maxSize, "oldest events")
+		this.events = this.events[len(this.events)-this.maxSize:]
+		dropped = true
+	}
+	if this.maxAge > 0 {
+		kept := this.events[:0]
+		for _, event := range this.events {
+			if time.Since(event.Time) <= this.maxAge {
+				kept = append(kept, event)
+			}
+		}
+		if len(kept) < len(this.events) {
+			log.Println("WARNING: drop", len(this.events)-len(kept), "buffered events older than", this.maxAge.String())
+			this.events = kept
+			dropped = true
+		}
+	}
+	return dropped
+}
+
+func (this *Buffer) load() error {
+	file, err := os.Open(this.location)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		event := Event{}
+		err = json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			//may happen if the process was stopped while writing the last line
+			log.Println("WARNING: ignore invalid line in event buffer file", this.location, err)
+			continue
+		}
+		this.lastSeq++
+		event.seq = this.lastSeq
+		this.events = append(this.events, event)
+	}
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+	if len(this.events) > 0 {
+		log.Println("loaded", len(this.events), "buffered events from", this.location)
+	}
+	this.applyLimits()
+	return this.store()
+}
+
+func (this *Buffer) append(event Event) (err error) {
+	if this.file == nil {
+		this.file, err = os.OpenFile(this.location, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+		if err != nil {
+			return err
+		}
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = this.file.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+	this.fileLines++
+	return nil
+}
+
+// store replaces the buffer file with the current events
+func (this *Buffer) store() (err error) {
+	if this.file != nil {
+		this.file.Close()
+		this.file = nil
+	}
+	temp, err := os.CreateTemp(filepath.Dir(this.location), filepath.Base(this.location)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	writer := bufio.NewWriter(temp)
+	encoder := json.NewEncoder(writer)
+	for _, event := range this.events {
+		err = encoder.Encode(event)
+		if err != nil {
+			temp.Close()
+			return err
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		temp.Close()
+		return err
+	}
+	err = temp.Sync()
+	if err != nil {
+		temp.Close()
+		return err
+	}
+	err = temp.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(temp.Name(), this.location)
+	if err != nil {
+		return err
+	}
+	this.fileLines = len(this.events)
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventbuffer
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBuffer(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "buffer.jsonl")
+
+	buffer, err := New(location, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		err = buffer.Push(Event{DeviceId: "d", ServiceId: "s", Payload: []byte(strconv.Itoa(i))})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = buffer.Push(Event{DeviceId: "d", ServiceId: "s", Payload: []byte("old"), Time: time.Now().Add(-2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = buffer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("reload drops oldest and outdated events", func(t *testing.T) {
+		buffer, err = New(location, 3, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buffer.Len() != 2 {
+			t.Error(buffer.Len())
+		}
+	})
+
+	t.Run("replay stops on error", func(t *testing.T) {
+		sent := []string{}
+		count, err := buffer.Replay(func(event Event) error {
+			if len(sent) == 1 {
+				return errors.New("test")
+			}
+			sent = append(sent, string(event.Payload))
+			return nil
+		})
+		if err == nil || count != 1 {
+			t.Error(count, err)
+		}
+		if !reflect.DeepEqual(sent, []string{"3"}) {
+			t.Error(sent)
+		}
+	})
+
+	t.Run("remaining events are persisted", func(t *testing.T) {
+		err = buffer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffer, err = New(location, 3, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent := []string{}
+		count, err := buffer.Replay(func(event Event) error {
+			sent = append(sent, string(event.Payload))
+			return nil
+		})
+		if err != nil || count != 1 {
+			t.Error(count, err)
+		}
+		if !reflect.DeepEqual(sent, []string{"4"}) {
+			t.Error(sent)
+		}
+		if buffer.Len() != 0 {
+			t.Error(buffer.Len())
+		}
+	})
+}
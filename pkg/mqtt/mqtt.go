@@ -88,3 +88,7 @@ func (this *Mqtt) init(ctx context.Context) error {
 	}()
 	return nil
 }
+
+func (this *Mqtt) IsConnected() bool {
+	return this.mqtt.IsConnectionOpen()
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt5
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+)
+
+// Properties are the mqtt 5 publish properties used by the connector
+type Properties struct {
+	ResponseTopic   string
+	CorrelationData []byte
+	UserProperties  map[string]string
+	MessageExpiry   time.Duration //0 = no expiry
+}
+
+type Handler = func(topic string, retained bool, payload []byte, properties Properties)
+
+func (this *Mqtt) Subscribe(topic string, qos byte, handler func(topic string, retained bool, payload []byte)) error {
+	return this.SubscribeWithProperties(topic, qos, func(topic string, retained bool, payload []byte, _ Properties) {
+		handler(topic, retained, payload)
+	})
+}
+
+func (this *Mqtt) SubscribeWithProperties(topic string, qos byte, handler Handler) error {
+	//register handler before subscribing to receive retained messages
+	this.registerSubscription(topic, qos, handler)
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+	_, err := this.mqtt.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: topic, QoS: qos}},
+	})
+	if err != nil {
+		log.Println("Error on Subscribe: ", topic, err)
+		this.unregisterSubscriptions(topic)
+		return err
+	}
+	return nil
+}
+
+func (this *Mqtt) Unsubscribe(topic string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+	_, err := this.mqtt.Unsubscribe(ctx, &paho.Unsubscribe{Topics: []string{topic}})
+	if err != nil {
+		log.Println("Error on Unsubscribe: ", topic, err)
+		return err
+	}
+	this.unregisterSubscriptions(topic)
+	return nil
+}
+
+func (this *Mqtt) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	return this.PublishWithProperties(topic, qos, retained, payload, Properties{})
+}
+
+func (this *Mqtt) PublishWithProperties(topic string, qos byte, retained bool, payload []byte, properties Properties) error {
+	ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+	defer cancel()
+	_, err := this.mqtt.Publish(ctx, &paho.Publish{
+		QoS:        qos,
+		Retain:     retained,
+		Topic:      topic,
+		Properties: properties.toPaho(),
+		Payload:    payload,
+	})
+	if err != nil {
+		log.Println("Error on Mqtt5.Publish(): ", err)
+		return err
+	}
+	return nil
+}
+
+func (this Properties) toPaho() *paho.PublishProperties {
+	result := &paho.PublishProperties{
+		ResponseTopic:   this.ResponseTopic,
+		CorrelationData: this.CorrelationData,
+	}
+	for key, value := range this.UserProperties {
+		result.User.Add(key, value)
+	}
+	if this.MessageExpiry > 0 {
+		expiry := uint32((this.MessageExpiry + time.Second - 1) / time.Second)
+		result.MessageExpiry = &expiry
+	}
+	return result
+}
+
+func propertiesFromPaho(properties *paho.PublishProperties) (result Properties) {
+	if properties == nil {
+		return result
+	}
+	result.ResponseTopic = properties.ResponseTopic
+	result.CorrelationData = properties.CorrelationData
+	if len(properties.User) > 0 {
+		result.UserProperties = map[string]string{}
+		for _, prop := range properties.User {
+			result.UserProperties[prop.Key] = prop.Value
+		}
+	}
+	if properties.MessageExpiry != nil {
+		result.MessageExpiry = time.Duration(*properties.MessageExpiry) * time.Second
+	}
+	return result
+}
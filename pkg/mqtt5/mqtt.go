@@ -0,0 +1,115 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt5
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+// New creates a mqtt 5 client with the same signature as mqtt.New to be usable as connector.MqttFactory
+func New(ctx context.Context, brokerUrl string, clientId string, username string, password string, insecureSkipVerify bool) (client *Mqtt, err error) {
+	client = &Mqtt{
+		subscriptions:      map[string]Subscription{},
+		subscriptionsMux:   sync.Mutex{},
+		mqtt:               nil,
+		brokerUrl:          brokerUrl,
+		clientId:           clientId,
+		username:           username,
+		password:           password,
+		insecureSkipVerify: insecureSkipVerify,
+		timeout:            10 * time.Second,
+	}
+	return client, client.init(ctx)
+}
+
+type Mqtt struct {
+	subscriptions      map[string]Subscription
+	subscriptionsMux   sync.Mutex
+	mqtt               *autopaho.ConnectionManager
+	brokerUrl          string
+	clientId           string
+	username           string
+	password           string
+	insecureSkipVerify bool
+	timeout            time.Duration
+	connected          atomic.Bool
+}
+
+func (this *Mqtt) init(ctx context.Context) (err error) {
+	broker, err := url.Parse(this.brokerUrl)
+	if err != nil {
+		return err
+	}
+	this.mqtt, err = autopaho.NewConnection(ctx, autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{broker},
+		TlsCfg:                        &tls.Config{InsecureSkipVerify: this.insecureSkipVerify},
+		KeepAlive:                     30,
+		CleanStartOnInitialConnection: true,
+		ReconnectBackoff:              autopaho.NewConstantBackoff(time.Second),
+		ConnectUsername:               this.username,
+		ConnectPassword:               []byte(this.password),
+		OnConnectionUp: func(_ *autopaho.ConnectionManager, _ *paho.Connack) {
+			log.Println("connected to mqtt broker (mqtt 5)")
+			this.connected.Store(true)
+			err := this.loadOldSubscriptions()
+			if err != nil {
+				log.Fatal("FATAL: ", err)
+			}
+		},
+		OnConnectError: func(err error) {
+			log.Println("WARNING: unable to connect to mqtt broker", err)
+			this.connected.Store(false)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID:          this.clientId,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){this.handlePublish},
+			OnClientError: func(err error) {
+				log.Println("connection to mqtt broker lost", err)
+				this.connected.Store(false)
+			},
+			OnServerDisconnect: func(disconnect *paho.Disconnect) {
+				log.Println("connection to mqtt broker lost: disconnected by server", disconnect.ReasonCode)
+				this.connected.Store(false)
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	timeout, cancel := context.WithTimeout(ctx, this.timeout)
+	defer cancel()
+	err = this.mqtt.AwaitConnection(timeout)
+	if err != nil {
+		log.Println("Error on Mqtt5Start.Connect(): ", err)
+		return errors.New("unable to connect to mqtt broker: " + err.Error())
+	}
+	return nil
+}
+
+func (this *Mqtt) IsConnected() bool {
+	return this.connected.Load()
+}
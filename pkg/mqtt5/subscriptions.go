@@ -0,0 +1,104 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt5
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/eclipse/paho.golang/paho"
+)
+
+type Subscription struct {
+	Topic   string
+	Qos     byte
+	Handler Handler
+}
+
+func (this *Mqtt) loadOldSubscriptions() error {
+	subs := this.getSubscriptions()
+	for _, sub := range subs {
+		log.Println("resubscribe to", sub.Topic)
+		ctx, cancel := context.WithTimeout(context.Background(), this.timeout)
+		_, err := this.mqtt.Subscribe(ctx, &paho.Subscribe{
+			Subscriptions: []paho.SubscribeOptions{{Topic: sub.Topic, QoS: sub.Qos}},
+		})
+		cancel()
+		if err != nil {
+			log.Println("Error on Subscribe: ", sub.Topic, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// handlePublish passes received messages to the handlers of all matching subscriptions
+func (this *Mqtt) handlePublish(received paho.PublishReceived) (bool, error) {
+	msg := received.Packet
+	handled := false
+	for _, sub := range this.getSubscriptions() {
+		if matchTopic(sub.Topic, msg.Topic) {
+			sub.Handler(msg.Topic, msg.Retain, msg.Payload, propertiesFromPaho(msg.Properties))
+			handled = true
+		}
+	}
+	return handled, nil
+}
+
+func (this *Mqtt) registerSubscription(topic string, qos byte, handler Handler) {
+	this.subscriptionsMux.Lock()
+	defer this.subscriptionsMux.Unlock()
+	this.subscriptions[topic] = Subscription{Topic: topic, Qos: qos, Handler: handler}
+}
+
+func (this *Mqtt) unregisterSubscriptions(topic string) {
+	this.subscriptionsMux.Lock()
+	defer this.subscriptionsMux.Unlock()
+	delete(this.subscriptions, topic)
+}
+
+func (this *Mqtt) getSubscriptions() (result []Subscription) {
+	this.subscriptionsMux.Lock()
+	defer this.subscriptionsMux.Unlock()
+	for _, sub := range this.subscriptions {
+		result = append(result, sub)
+	}
+	return
+}
+
+// matchTopic checks if a topic matches a subscription filter with the wildcards '+' and '#'
+func matchTopic(filter string, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	//wildcards at the first level do not match topics beginning with '$'
+	if strings.HasPrefix(topic, "$") && (filterLevels[0] == "+" || filterLevels[0] == "#") {
+		return false
+	}
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
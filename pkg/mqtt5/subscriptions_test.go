@@ -0,0 +1,63 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt5
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{filter: "a/b", topic: "a/b", match: true},
+		{filter: "a/b", topic: "a/c", match: false},
+		{filter: "a/+", topic: "a/b", match: true},
+		{filter: "a/+", topic: "a/b/c", match: false},
+		{filter: "a/#", topic: "a", match: true},
+		{filter: "a/#", topic: "a/b/c", match: true},
+		{filter: "#", topic: "a/b", match: true},
+		{filter: "#", topic: "$SYS/a", match: false},
+		{filter: "+/b", topic: "a/b", match: true},
+		{filter: "a/b/c", topic: "a/b", match: false},
+	}
+	for _, c := range cases {
+		if matchTopic(c.filter, c.topic) != c.match {
+			t.Error(c.filter, c.topic, c.match)
+		}
+	}
+}
+
+func TestProperties(t *testing.T) {
+	props := Properties{
+		ResponseTopic:   "resp",
+		CorrelationData: []byte("id"),
+		UserProperties:  map[string]string{"foo": "bar"},
+		MessageExpiry:   1500 * time.Millisecond,
+	}
+	p := props.toPaho()
+	if p.MessageExpiry == nil || *p.MessageExpiry != 2 {
+		t.Error(p.MessageExpiry)
+	}
+	result := propertiesFromPaho(p)
+	if result.ResponseTopic != "resp" || string(result.CorrelationData) != "id" || result.UserProperties["foo"] != "bar" || result.MessageExpiry != 2*time.Second {
+		t.Error(result)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sparkplug
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/codec"
+	"slices"
+	"time"
+)
+
+const Format = "sparkplug"
+
+func init() {
+	codec.Register(Format, Codec{})
+}
+
+// Codec translates between sparkplug b payloads and json objects with a field per metric name.
+// Encode uses DataTypes to type the metrics; metrics without known data type are derived from the json value
+type Codec struct {
+	DataTypes map[string]DataType
+}
+
+func (this Codec) Decode(payload []byte) (jsonPayload []byte, err error) {
+	decoded, err := Unmarshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return MetricsToJson(decoded.Metrics)
+}
+
+func (this Codec) Encode(jsonPayload []byte) (payload []byte, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(jsonPayload))
+	decoder.UseNumber()
+	values := map[string]interface{}{}
+	err = decoder.Decode(&values)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not a json object: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, errors.New("payload contains no metric")
+	}
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	result := Payload{Timestamp: uint64(time.Now().UnixMilli())}
+	for _, name := range names {
+		metric, err := NewMetric(name, this.DataTypes[name], values[name])
+		if err != nil {
+			return nil, err
+		}
+		result.Metrics = append(result.Metrics, metric)
+	}
+	return Marshal(result), nil
+}
+
+// MetricsToJson creates a json object with the value of each named metric
+func MetricsToJson(metrics []Metric) ([]byte, error) {
+	result := map[string]interface{}{}
+	for _, metric := range metrics {
+		if metric.Name != "" {
+			result[metric.Name] = metric.Value()
+		}
+	}
+	return json.Marshal(result)
+}
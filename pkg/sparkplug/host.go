@@ -0,0 +1,196 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sparkplug
+
+import (
+	"sync"
+	"time"
+)
+
+const MetricBdSeq = "bdSeq"
+const MetricRebirth = "Node Control/Rebirth"
+
+// Host tracks the sessions of edge nodes as a sparkplug host application:
+// sequence numbers, birth/death sequence numbers, metric aliases and data types announced by birth certificates
+type Host struct {
+	mux              sync.Mutex
+	sessions         map[string]*session //node key -> session
+	rebirthRequested map[string]bool     //node key -> rebirth requested without a following NBIRTH
+}
+
+type session struct {
+	bdSeq     uint64
+	hasBdSeq  bool
+	seq       uint64
+	aliases   map[uint64]Metric              //alias -> metric without value
+	dataTypes map[string]map[string]DataType //device ("" for the node) -> metric name -> data type
+}
+
+// Result is the outcome of handling a message
+type Result struct {
+	Metrics []Metric //metrics of the message with resolved aliases; metrics with unknown aliases are dropped
+	Rebirth bool     //a rebirth of the node should be requested (unknown node or sequence gap)
+	Stale   bool     //death certificate of an older session; the node is still online
+}
+
+func NewHost() *Host {
+	return &Host{
+		sessions:         map[string]*session{},
+		rebirthRequested: map[string]bool{},
+	}
+}
+
+// Handle updates the session of the edge node of the topic with the message
+func (this *Host) Handle(topic Topic, payload Payload) (result Result) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	node := topic.NodeKey()
+	switch topic.MessageType {
+	case MessageNodeBirth:
+		current := &session{
+			seq:       payload.Seq,
+			aliases:   map[uint64]Metric{},
+			dataTypes: map[string]map[string]DataType{},
+		}
+		current.bdSeq, current.hasBdSeq = getBdSeq(payload)
+		this.sessions[node] = current
+		delete(this.rebirthRequested, node)
+		current.register(topic.Device, payload.Metrics)
+		result.Metrics = current.resolve(payload.Metrics)
+		return result
+	case MessageNodeDeath:
+		current, ok := this.sessions[node]
+		if !ok {
+			return result
+		}
+		if bdSeq, found := getBdSeq(payload); found && current.hasBdSeq && bdSeq != current.bdSeq {
+			result.Stale = true
+			return result
+		}
+		delete(this.sessions, node)
+		return result
+	}
+	current, ok := this.sessions[node]
+	if !ok {
+		//no birth certificate: aliases are unknown
+		result.Rebirth = this.requestRebirth(node)
+		result.Metrics = (&session{}).resolve(payload.Metrics)
+		return result
+	}
+	if payload.HasSeq {
+		if payload.Seq != (current.seq+1)%256 {
+			result.Rebirth = this.requestRebirth(node)
+		}
+		current.seq = payload.Seq
+	}
+	if topic.MessageType == MessageDeviceBirth {
+		current.register(topic.Device, payload.Metrics)
+	}
+	result.Metrics = current.resolve(payload.Metrics)
+	return result
+}
+
+// RequestRebirth returns true if no rebirth of the node has been requested since its last NBIRTH and marks the rebirth as requested
+func (this *Host) RequestRebirth(node string) bool {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.requestRebirth(node)
+}
+
+func (this *Host) requestRebirth(node string) bool {
+	if this.rebirthRequested[node] {
+		return false
+	}
+	this.rebirthRequested[node] = true
+	return true
+}
+
+// Forget removes the session of the node
+func (this *Host) Forget(node string) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.sessions, node)
+	delete(this.rebirthRequested, node)
+}
+
+// DataTypes returns the metric data types announced by the birth certificate of the device of the topic
+func (this *Host) DataTypes(topic Topic) map[string]DataType {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	result := map[string]DataType{}
+	current, ok := this.sessions[topic.NodeKey()]
+	if !ok {
+		return result
+	}
+	for name, dataType := range current.dataTypes[topic.Device] {
+		result[name] = dataType
+	}
+	return result
+}
+
+func (this *session) register(device string, metrics []Metric) {
+	dataTypes := map[string]DataType{}
+	for _, metric := range metrics {
+		if metric.Name == "" {
+			continue
+		}
+		if metric.HasAlias {
+			this.aliases[metric.Alias] = Metric{Name: metric.Name, DataType: metric.DataType}
+		}
+		dataTypes[metric.Name] = metric.DataType
+	}
+	this.dataTypes[device] = dataTypes
+}
+
+func (this *session) resolve(metrics []Metric) (result []Metric) {
+	for _, metric := range metrics {
+		if metric.Name == "" {
+			known, ok := this.aliases[metric.Alias]
+			if !metric.HasAlias || !ok {
+				continue
+			}
+			metric.Name = known.Name
+			if metric.DataType == Unknown {
+				metric.DataType = known.DataType
+			}
+		}
+		result = append(result, metric)
+	}
+	return result
+}
+
+func getBdSeq(payload Payload) (uint64, bool) {
+	for _, metric := range payload.Metrics {
+		if metric.Name == MetricBdSeq {
+			if value, ok := metric.RawValue.(uint64); ok {
+				return value, true
+			}
+			if value, ok := metric.RawValue.(uint32); ok {
+				return uint64(value), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// RebirthRequest is the NCMD payload requesting new birth certificates of an edge node
+func RebirthRequest() []byte {
+	return Marshal(Payload{
+		Timestamp: uint64(time.Now().UnixMilli()),
+		Metrics:   []Metric{{Name: MetricRebirth, DataType: Boolean, RawValue: true}},
+	})
+}
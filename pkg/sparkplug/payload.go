@@ -0,0 +1,439 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sparkplug
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"strconv"
+)
+
+type DataType uint32
+
+// data types of the Sparkplug B specification; data sets, templates and property sets are not supported
+const (
+	Unknown  DataType = 0
+	Int8     DataType = 1
+	Int16    DataType = 2
+	Int32    DataType = 3
+	Int64    DataType = 4
+	UInt8    DataType = 5
+	UInt16   DataType = 6
+	UInt32   DataType = 7
+	UInt64   DataType = 8
+	Float    DataType = 9
+	Double   DataType = 10
+	Boolean  DataType = 11
+	String   DataType = 12
+	DateTime DataType = 13
+	Text     DataType = 14
+	UUID     DataType = 15
+	Bytes    DataType = 17
+)
+
+// field numbers of org.eclipse.tahu.protobuf.Payload
+const (
+	payloadTimestamp protowire.Number = 1
+	payloadMetrics   protowire.Number = 2
+	payloadSeq       protowire.Number = 3
+)
+
+// field numbers of org.eclipse.tahu.protobuf.Payload.Metric
+const (
+	metricName         protowire.Number = 1
+	metricAlias        protowire.Number = 2
+	metricTimestamp    protowire.Number = 3
+	metricDataType     protowire.Number = 4
+	metricIsNull       protowire.Number = 7
+	metricIntValue     protowire.Number = 10
+	metricLongValue    protowire.Number = 11
+	metricFloatValue   protowire.Number = 12
+	metricDoubleValue  protowire.Number = 13
+	metricBooleanValue protowire.Number = 14
+	metricStringValue  protowire.Number = 15
+	metricBytesValue   protowire.Number = 16
+)
+
+type Payload struct {
+	Timestamp uint64 //ms since epoch
+	Metrics   []Metric
+	Seq       uint64
+	HasSeq    bool //commands and death certificates have no sequence number
+}
+
+// Metric holds its value as encoded: uint32 (int_value), uint64 (long_value), float32, float64, bool, string or []byte;
+// Value() interprets it with the DataType
+type Metric struct {
+	Name      string
+	Alias     uint64
+	HasAlias  bool
+	Timestamp uint64
+	DataType  DataType
+	IsNull    bool
+	RawValue  interface{}
+}
+
+// Value returns the metric value as json compatible value according to the data type; bytes are encoded as base64 by json
+func (this Metric) Value() interface{} {
+	if this.IsNull {
+		return nil
+	}
+	switch raw := this.RawValue.(type) {
+	case uint32:
+		switch this.DataType {
+		case Int8:
+			return int64(int8(raw))
+		case Int16:
+			return int64(int16(raw))
+		case Int32:
+			return int64(int32(raw))
+		default:
+			return uint64(raw)
+		}
+	case uint64:
+		if this.DataType == Int64 {
+			return int64(raw)
+		}
+		return raw
+	case float32:
+		//shortest representation of the float32 (e.g. 0.1 instead of 0.10000000149011612)
+		result, _ := strconv.ParseFloat(strconv.FormatFloat(float64(raw), 'g', -1, 32), 64)
+		return result
+	default:
+		return raw
+	}
+}
+
+// NewMetric creates a metric with a json value (e.g. float64, bool, string or json.Number) converted to the data type;
+// an unknown data type is derived from the value
+func NewMetric(name string, dataType DataType, value interface{}) (result Metric, err error) {
+	result = Metric{Name: name, DataType: dataType}
+	if value == nil {
+		result.IsNull = true
+		if dataType == Unknown {
+			return result, errors.New("unable to derive data type of null value for " + name)
+		}
+		return result, nil
+	}
+	if dataType == Unknown {
+		result.DataType = deriveDataType(value)
+	}
+	switch result.DataType {
+	case Int8, Int16, Int32, Int64, UInt8, UInt16, UInt32, UInt64, DateTime:
+		number, err := toInt(value, result.DataType)
+		if err != nil {
+			return result, fmt.Errorf("%v: %w", name, err)
+		}
+		if result.DataType == Int64 || result.DataType == UInt64 || result.DataType == DateTime {
+			result.RawValue = uint64(number)
+		} else {
+			result.RawValue = uint32(number)
+		}
+	case Float, Double:
+		number, err := toFloat(value)
+		if err != nil {
+			return result, fmt.Errorf("%v: %w", name, err)
+		}
+		if result.DataType == Float {
+			result.RawValue = float32(number)
+		} else {
+			result.RawValue = number
+		}
+	case Boolean:
+		flag, ok := value.(bool)
+		if !ok {
+			return result, fmt.Errorf("%v: %v is not a bool", name, value)
+		}
+		result.RawValue = flag
+	case String, Text, UUID:
+		text, ok := value.(string)
+		if !ok {
+			return result, fmt.Errorf("%v: %v is not a string", name, value)
+		}
+		result.RawValue = text
+	case Bytes:
+		text, ok := value.(string)
+		if !ok {
+			return result, fmt.Errorf("%v: %v is not a base64 string", name, value)
+		}
+		result.RawValue, err = base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return result, fmt.Errorf("%v: %w", name, err)
+		}
+	default:
+		return result, fmt.Errorf("%v: unsupported data type %v", name, result.DataType)
+	}
+	return result, nil
+}
+
+type jsonNumber interface {
+	Int64() (int64, error)
+	Float64() (float64, error)
+	String() string
+}
+
+func deriveDataType(value interface{}) DataType {
+	switch v := value.(type) {
+	case bool:
+		return Boolean
+	case string:
+		return String
+	case jsonNumber:
+		if _, err := v.Int64(); err == nil {
+			return Int64
+		}
+		return Double
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return Int64
+		}
+		return Double
+	default:
+		return Unknown
+	}
+}
+
+var intRanges = map[DataType][2]float64{
+	Int8:     {math.MinInt8, math.MaxInt8},
+	Int16:    {math.MinInt16, math.MaxInt16},
+	Int32:    {math.MinInt32, math.MaxInt32},
+	Int64:    {math.MinInt64, math.MaxInt64},
+	UInt8:    {0, math.MaxUint8},
+	UInt16:   {0, math.MaxUint16},
+	UInt32:   {0, math.MaxUint32},
+	UInt64:   {0, math.MaxUint64},
+	DateTime: {0, math.MaxUint64},
+}
+
+func toInt(value interface{}, dataType DataType) (int64, error) {
+	var number float64
+	switch v := value.(type) {
+	case jsonNumber:
+		if i, err := v.Int64(); err == nil {
+			if (dataType == UInt64 || dataType == DateTime) && i < 0 {
+				return 0, fmt.Errorf("%v is out of range", v)
+			}
+			number = float64(i)
+			if intRanges[dataType][0] <= number && number <= intRanges[dataType][1] {
+				return i, nil
+			}
+			return 0, fmt.Errorf("%v is out of range", v)
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil && (dataType == UInt64 || dataType == DateTime) {
+			return int64(u), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("%v is not a number", v)
+		}
+		number = f
+	case float64:
+		number = v
+	default:
+		return 0, fmt.Errorf("%v is not a number", value)
+	}
+	if number != math.Trunc(number) {
+		return 0, fmt.Errorf("%v is not an integer", number)
+	}
+	if number < intRanges[dataType][0] || number > intRanges[dataType][1] {
+		return 0, fmt.Errorf("%v is out of range", number)
+	}
+	//float64(math.MaxInt64) and float64(math.MaxUint64) are rounded up to 2^63 and 2^64, which pass the range check but do not fit
+	if dataType == UInt64 || dataType == DateTime {
+		if number >= 1<<64 {
+			return 0, fmt.Errorf("%v is out of range", number)
+		}
+		return int64(uint64(number)), nil
+	}
+	if number >= 1<<63 {
+		return 0, fmt.Errorf("%v is out of range", number)
+	}
+	return int64(number), nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case jsonNumber:
+		return v.Float64()
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("%v is not a number", value)
+	}
+}
+
+// Unmarshal decodes the protobuf encoded Sparkplug B payload; unsupported metric values are ignored
+func Unmarshal(data []byte) (result Payload, err error) {
+	err = consumeFields(data, func(number protowire.Number, typ protowire.Type, value []byte) (n int, err error) {
+		switch {
+		case number == payloadTimestamp && typ == protowire.VarintType:
+			result.Timestamp, n = protowire.ConsumeVarint(value)
+		case number == payloadSeq && typ == protowire.VarintType:
+			result.Seq, n = protowire.ConsumeVarint(value)
+			result.HasSeq = true
+		case number == payloadMetrics && typ == protowire.BytesType:
+			var content []byte
+			content, n = protowire.ConsumeBytes(value)
+			if n < 0 {
+				return n, nil
+			}
+			metric, err := unmarshalMetric(content)
+			if err != nil {
+				return n, err
+			}
+			result.Metrics = append(result.Metrics, metric)
+		default:
+			n = protowire.ConsumeFieldValue(number, typ, value)
+		}
+		return n, nil
+	})
+	return result, err
+}
+
+func unmarshalMetric(data []byte) (result Metric, err error) {
+	err = consumeFields(data, func(number protowire.Number, typ protowire.Type, value []byte) (n int, err error) {
+		var v uint64
+		switch {
+		case number == metricName && typ == protowire.BytesType:
+			var name []byte
+			name, n = protowire.ConsumeBytes(value)
+			result.Name = string(name)
+		case number == metricAlias && typ == protowire.VarintType:
+			result.Alias, n = protowire.ConsumeVarint(value)
+			result.HasAlias = true
+		case number == metricTimestamp && typ == protowire.VarintType:
+			result.Timestamp, n = protowire.ConsumeVarint(value)
+		case number == metricDataType && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(value)
+			result.DataType = DataType(v)
+		case number == metricIsNull && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(value)
+			result.IsNull = v != 0
+		case number == metricIntValue && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(value)
+			result.RawValue = uint32(v)
+		case number == metricLongValue && typ == protowire.VarintType:
+			result.RawValue, n = protowire.ConsumeVarint(value)
+		case number == metricFloatValue && typ == protowire.Fixed32Type:
+			var bits uint32
+			bits, n = protowire.ConsumeFixed32(value)
+			result.RawValue = math.Float32frombits(bits)
+		case number == metricDoubleValue && typ == protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(value)
+			result.RawValue = math.Float64frombits(v)
+		case number == metricBooleanValue && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(value)
+			result.RawValue = v != 0
+		case number == metricStringValue && typ == protowire.BytesType:
+			var text []byte
+			text, n = protowire.ConsumeBytes(value)
+			result.RawValue = string(text)
+		case number == metricBytesValue && typ == protowire.BytesType:
+			var content []byte
+			content, n = protowire.ConsumeBytes(value)
+			result.RawValue = append([]byte{}, content...)
+		default:
+			n = protowire.ConsumeFieldValue(number, typ, value)
+		}
+		return n, nil
+	})
+	return result, err
+}
+
+func consumeFields(data []byte, f func(number protowire.Number, typ protowire.Type, value []byte) (int, error)) error {
+	for len(data) > 0 {
+		number, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("invalid sparkplug payload: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+		n, err := f(number, typ, data)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid sparkplug payload: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// Marshal encodes the payload as protobuf
+func Marshal(payload Payload) (result []byte) {
+	result = protowire.AppendTag(result, payloadTimestamp, protowire.VarintType)
+	result = protowire.AppendVarint(result, payload.Timestamp)
+	for _, metric := range payload.Metrics {
+		result = protowire.AppendTag(result, payloadMetrics, protowire.BytesType)
+		result = protowire.AppendBytes(result, marshalMetric(metric))
+	}
+	if payload.HasSeq {
+		result = protowire.AppendTag(result, payloadSeq, protowire.VarintType)
+		result = protowire.AppendVarint(result, payload.Seq)
+	}
+	return result
+}
+
+func marshalMetric(metric Metric) (result []byte) {
+	if metric.Name != "" {
+		result = protowire.AppendTag(result, metricName, protowire.BytesType)
+		result = protowire.AppendString(result, metric.Name)
+	}
+	if metric.HasAlias {
+		result = protowire.AppendTag(result, metricAlias, protowire.VarintType)
+		result = protowire.AppendVarint(result, metric.Alias)
+	}
+	if metric.Timestamp != 0 {
+		result = protowire.AppendTag(result, metricTimestamp, protowire.VarintType)
+		result = protowire.AppendVarint(result, metric.Timestamp)
+	}
+	if metric.DataType != Unknown {
+		result = protowire.AppendTag(result, metricDataType, protowire.VarintType)
+		result = protowire.AppendVarint(result, uint64(metric.DataType))
+	}
+	if metric.IsNull {
+		result = protowire.AppendTag(result, metricIsNull, protowire.VarintType)
+		return protowire.AppendVarint(result, 1)
+	}
+	switch value := metric.RawValue.(type) {
+	case uint32:
+		result = protowire.AppendTag(result, metricIntValue, protowire.VarintType)
+		result = protowire.AppendVarint(result, uint64(value))
+	case uint64:
+		result = protowire.AppendTag(result, metricLongValue, protowire.VarintType)
+		result = protowire.AppendVarint(result, value)
+	case float32:
+		result = protowire.AppendTag(result, metricFloatValue, protowire.Fixed32Type)
+		result = protowire.AppendFixed32(result, math.Float32bits(value))
+	case float64:
+		result = protowire.AppendTag(result, metricDoubleValue, protowire.Fixed64Type)
+		result = protowire.AppendFixed64(result, math.Float64bits(value))
+	case bool:
+		result = protowire.AppendTag(result, metricBooleanValue, protowire.VarintType)
+		result = protowire.AppendVarint(result, protowire.EncodeBool(value))
+	case string:
+		result = protowire.AppendTag(result, metricStringValue, protowire.BytesType)
+		result = protowire.AppendString(result, value)
+	case []byte:
+		result = protowire.AppendTag(result, metricBytesValue, protowire.BytesType)
+		result = protowire.AppendBytes(result, value)
+	}
+	return result
+}
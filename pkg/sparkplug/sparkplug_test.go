@@ -0,0 +1,220 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sparkplug
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTopic(t *testing.T) {
+	topic, err := ParseTopic("spBv1.0/plant/DDATA/gateway1/plc1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic != (Topic{Group: "plant", MessageType: MessageDeviceData, Node: "gateway1", Device: "plc1"}) {
+		t.Errorf("%#v", topic)
+	}
+	if topic.String() != "spBv1.0/plant/DDATA/gateway1/plc1" {
+		t.Error(topic.String())
+	}
+	if topic.DeviceKey() != "plant/gateway1/plc1" || topic.NodeKey() != "plant/gateway1" {
+		t.Error(topic.DeviceKey(), topic.NodeKey())
+	}
+	if topic.NodeSubscription() != "spBv1.0/plant/+/gateway1/#" {
+		t.Error(topic.NodeSubscription())
+	}
+	if topic.RebirthTopic() != "spBv1.0/plant/NCMD/gateway1" {
+		t.Error(topic.RebirthTopic())
+	}
+	if topic.WithMessageType(MessageDeviceCommand).String() != "spBv1.0/plant/DCMD/gateway1/plc1" {
+		t.Error(topic.WithMessageType(MessageDeviceCommand).String())
+	}
+	for _, invalid := range []string{
+		"spAv1.0/plant/DDATA/gateway1/plc1",
+		"spBv1.0/plant/DDATA/gateway1",
+		"spBv1.0/plant/NDATA/gateway1/plc1",
+		"spBv1.0/plant/DDATA/+/plc1",
+		"spBv1.0/plant/UNKNOWN/gateway1/plc1",
+		"spBv1.0/plant//gateway1/plc1",
+	} {
+		if _, err = ParseTopic(invalid); err == nil {
+			t.Error("expected error for", invalid)
+		}
+	}
+}
+
+func TestPayload(t *testing.T) {
+	payload := Payload{Timestamp: 1700000000000, Seq: 5, HasSeq: true}
+	for _, metric := range []struct {
+		name     string
+		dataType DataType
+		value    interface{}
+	}{
+		{"temperature", Float, 21.5},
+		{"offset", Int16, float64(-3)},
+		{"counter", UInt64, float64(42)},
+		{"running", Boolean, true},
+		{"mode", String, "auto"},
+		{"ratio", Double, 0.1},
+	} {
+		m, err := NewMetric(metric.name, metric.dataType, metric.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload.Metrics = append(payload.Metrics, m)
+	}
+	payload.Metrics = append(payload.Metrics, Metric{Alias: 7, HasAlias: true, DataType: Int32, IsNull: true})
+
+	decoded, err := Unmarshal(Marshal(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, payload) {
+		t.Errorf("\n%#v\n%#v", decoded, payload)
+	}
+	j, err := MetricsToJson(decoded.Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"counter":42,"mode":"auto","offset":-3,"ratio":0.1,"running":true,"temperature":21.5}`
+	if string(j) != expected {
+		t.Error(string(j))
+	}
+
+	_, err = Unmarshal([]byte{0x12, 0x05, 0x01})
+	if err == nil {
+		t.Error("expected error for truncated payload")
+	}
+}
+
+func TestCodecEncode(t *testing.T) {
+	encoded, err := Codec{DataTypes: map[string]DataType{"level": UInt8}}.Encode([]byte(`{"level": 5, "setpoint": 20.5, "enabled": false}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Unmarshal(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataTypes := map[string]DataType{}
+	for _, metric := range decoded.Metrics {
+		dataTypes[metric.Name] = metric.DataType
+	}
+	if !reflect.DeepEqual(dataTypes, map[string]DataType{"enabled": Boolean, "level": UInt8, "setpoint": Double}) {
+		t.Error(dataTypes)
+	}
+	j, _ := MetricsToJson(decoded.Metrics)
+	if string(j) != `{"enabled":false,"level":5,"setpoint":20.5}` {
+		t.Error(string(j))
+	}
+
+	for _, invalid := range []string{`{"level": 300}`, `{"level": -1}`, `{"level": 1.5}`, `{"level": "high"}`, `{}`, `[1]`} {
+		_, err = Codec{DataTypes: map[string]DataType{"level": UInt8}}.Encode([]byte(invalid))
+		if err == nil {
+			t.Error("expected error for", invalid)
+		}
+	}
+}
+
+func TestHost(t *testing.T) {
+	host := NewHost()
+	node := Topic{Group: "plant", MessageType: MessageNodeBirth, Node: "gateway1"}
+	device := Topic{Group: "plant", MessageType: MessageDeviceBirth, Node: "gateway1", Device: "plc1"}
+	data := device.WithMessageType(MessageDeviceData)
+
+	//data before birth: request rebirth once, forward named metrics only
+	result := host.Handle(data, Payload{Seq: 3, HasSeq: true, Metrics: []Metric{
+		{Name: "temperature", DataType: Float, RawValue: float32(20)},
+		{Alias: 1, HasAlias: true, RawValue: float32(21)},
+	}})
+	if !result.Rebirth || len(result.Metrics) != 1 {
+		t.Errorf("%#v", result)
+	}
+	result = host.Handle(data, Payload{Seq: 4, HasSeq: true})
+	if result.Rebirth {
+		t.Error("expected only one rebirth request")
+	}
+
+	result = host.Handle(node, Payload{Seq: 0, HasSeq: true, Metrics: []Metric{{Name: MetricBdSeq, DataType: Int64, RawValue: uint64(2)}}})
+	if result.Rebirth || result.Stale {
+		t.Errorf("%#v", result)
+	}
+	result = host.Handle(device, Payload{Seq: 1, HasSeq: true, Metrics: []Metric{
+		{Name: "temperature", Alias: 1, HasAlias: true, DataType: Float, RawValue: float32(20)},
+		{Name: "level", Alias: 2, HasAlias: true, DataType: UInt8, RawValue: uint32(3)},
+	}})
+	if result.Rebirth || len(result.Metrics) != 2 {
+		t.Errorf("%#v", result)
+	}
+	if !reflect.DeepEqual(host.DataTypes(device), map[string]DataType{"temperature": Float, "level": UInt8}) {
+		t.Error(host.DataTypes(device))
+	}
+
+	//aliases are resolved with the birth certificate
+	result = host.Handle(data, Payload{Seq: 2, HasSeq: true, Metrics: []Metric{{Alias: 1, HasAlias: true, RawValue: float32(21.5)}, {Alias: 9, HasAlias: true, RawValue: uint32(1)}}})
+	if result.Rebirth || len(result.Metrics) != 1 || result.Metrics[0].Name != "temperature" || result.Metrics[0].Value() != 21.5 {
+		t.Errorf("%#v", result)
+	}
+
+	//sequence gap
+	result = host.Handle(data, Payload{Seq: 5, HasSeq: true})
+	if !result.Rebirth {
+		t.Error("expected rebirth on sequence gap")
+	}
+	result = host.Handle(data, Payload{Seq: 7, HasSeq: true})
+	if result.Rebirth {
+		t.Error("expected only one rebirth request until the next birth")
+	}
+
+	//death certificate of an older session
+	death := node.WithMessageType(MessageNodeDeath)
+	result = host.Handle(death, Payload{Metrics: []Metric{{Name: MetricBdSeq, DataType: Int64, RawValue: uint64(1)}}})
+	if !result.Stale {
+		t.Error("expected stale death certificate")
+	}
+	result = host.Handle(death, Payload{Metrics: []Metric{{Name: MetricBdSeq, DataType: Int64, RawValue: uint64(2)}}})
+	if result.Stale {
+		t.Error("unexpected stale death certificate")
+	}
+	if len(host.DataTypes(device)) != 0 {
+		t.Error(host.DataTypes(device))
+	}
+}
+
+func TestIntRange(t *testing.T) {
+	for _, c := range []struct {
+		dataType DataType
+		value    float64
+		valid    bool
+	}{
+		{Int64, -(1 << 63), true},
+		{Int64, 1 << 63, false},
+		{UInt64, 1 << 63, true},
+		{UInt64, 1 << 64, false},
+		{DateTime, 1 << 64, false},
+	} {
+		_, err := NewMetric("value", c.dataType, c.value)
+		if (err == nil) != c.valid {
+			t.Error(c.dataType, c.value, err)
+		}
+	}
+	_, err := Codec{DataTypes: map[string]DataType{"value": Int64}}.Encode([]byte(`{"value": 9223372036854775808}`))
+	if err == nil {
+		t.Error("expected error for 2^63 as Int64")
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sparkplug
+
+import (
+	"errors"
+	"strings"
+)
+
+const Namespace = "spBv1.0"
+
+const (
+	MessageNodeBirth     = "NBIRTH"
+	MessageNodeDeath     = "NDEATH"
+	MessageNodeData      = "NDATA"
+	MessageNodeCommand   = "NCMD"
+	MessageDeviceBirth   = "DBIRTH"
+	MessageDeviceDeath   = "DDEATH"
+	MessageDeviceData    = "DDATA"
+	MessageDeviceCommand = "DCMD"
+	MessageState         = "STATE"
+)
+
+// Topic is a parsed Sparkplug B topic: spBv1.0/<group>/<message type>/<edge node>[/<device>]
+type Topic struct {
+	Group       string
+	MessageType string
+	Node        string
+	Device      string
+}
+
+func ParseTopic(topic string) (result Topic, err error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) < 4 || len(parts) > 5 || parts[0] != Namespace {
+		return result, errors.New("not a sparkplug b topic: " + topic)
+	}
+	for _, part := range parts[1:] {
+		if part == "" || strings.ContainsAny(part, "+#") {
+			return result, errors.New("invalid sparkplug b topic: " + topic)
+		}
+	}
+	result = Topic{Group: parts[1], MessageType: parts[2], Node: parts[3]}
+	if len(parts) == 5 {
+		result.Device = parts[4]
+	}
+	switch result.MessageType {
+	case MessageNodeBirth, MessageNodeDeath, MessageNodeData, MessageNodeCommand:
+		if result.Device != "" {
+			return result, errors.New("unexpected device in sparkplug b node topic: " + topic)
+		}
+	case MessageDeviceBirth, MessageDeviceDeath, MessageDeviceData, MessageDeviceCommand:
+		if result.Device == "" {
+			return result, errors.New("missing device in sparkplug b device topic: " + topic)
+		}
+	default:
+		return result, errors.New("unknown sparkplug b message type " + result.MessageType + ": " + topic)
+	}
+	return result, nil
+}
+
+func (this Topic) String() string {
+	result := Namespace + "/" + this.Group + "/" + this.MessageType + "/" + this.Node
+	if this.Device != "" {
+		result = result + "/" + this.Device
+	}
+	return result
+}
+
+// WithMessageType returns the topic of another message type of the same node or device
+func (this Topic) WithMessageType(messageType string) Topic {
+	this.MessageType = messageType
+	return this
+}
+
+// NodeKey identifies the edge node of the topic
+func (this Topic) NodeKey() string {
+	return this.Group + "/" + this.Node
+}
+
+// DeviceKey identifies the device of the topic; node messages have an empty device
+func (this Topic) DeviceKey() string {
+	return this.NodeKey() + "/" + this.Device
+}
+
+// NodeSubscription matches all messages of the edge node and its devices.
+// all messages are needed to track the sequence numbers of the node
+func (this Topic) NodeSubscription() string {
+	return Namespace + "/" + this.Group + "/+/" + this.Node + "/#"
+}
+
+// RebirthTopic is the NCMD topic of the edge node
+func (this Topic) RebirthTopic() string {
+	return Topic{Group: this.Group, MessageType: MessageNodeCommand, Node: this.Node}.String()
+}
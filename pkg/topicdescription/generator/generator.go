@@ -18,6 +18,7 @@ package generator
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/util"
 	"github.com/SENERGY-Platform/models/go/models"
@@ -30,6 +31,9 @@ import (
 const CommandAttribute = "senergy/local-mqtt/cmd-topic-tmpl"
 const ResponseAttribute = "senergy/local-mqtt/resp-topic-tmpl"
 const EventAttribute = "senergy/local-mqtt/event-topic-tmpl"
+const ResponseTimeoutAttribute = "senergy/local-mqtt/resp-timeout"
+const LivenessTimeoutAttribute = "senergy/local-mqtt/liveness-timeout"
+const PayloadFormatAttribute = "senergy/local-mqtt/payload-format"
 
 var TemplateLocalDeviceIdPlaceholders = []string{"Device", "LocalDeviceId"}
 var TemplateLocalServiceIdPlaceholders = []string{"Service", "LocalServiceId"}
@@ -95,21 +99,8 @@ func GenerateCommandServiceTopicDescriptions(device models.Device, service model
 	if temp.DeviceName == "" {
 		temp.DeviceName = "unknown name"
 	}
-	for _, attr := range service.Attributes {
-		if attr.Key == model.TransformerJsonUnwrapInput || attr.Key == model.TransformerJsonUnwrapOutput {
-			paths := strings.Split(attr.Value, ",")
-			for _, path := range paths {
-				path = strings.TrimSpace(path)
-				temp.Transformations = append(temp.Transformations, model.Transformation{
-					Path:           path,
-					Transformation: attr.Key,
-				})
-			}
-		}
-	}
-	slices.SortFunc(temp.Transformations, func(a, b model.Transformation) int {
-		return strings.Compare(a.Path, b.Path)
-	})
+	temp.Transformations = GenerateTransformations(device, service)
+	temp.PayloadFormat, _ = GetAttributeValue(service.Attributes, PayloadFormatAttribute)
 	respTopic, found := GetAttributeValue(service.Attributes, ResponseAttribute)
 	if found {
 		temp.RespTopic, err = GenerateTopic(respTopic, device.LocalId, service.LocalId, truncateDevicePrefix, device.Attributes)
@@ -117,6 +108,7 @@ func GenerateCommandServiceTopicDescriptions(device models.Device, service model
 			log.Println("WARNING: invalid response topic template", cmdTopicTempl, "in", device.Name, device.Id, device.LocalId, service.Name, service.Id, service.LocalId)
 			return result
 		}
+		temp.RespTimeout, _ = GetAttributeValue(service.Attributes, ResponseTimeoutAttribute)
 	}
 	return []model.TopicDescription{temp}
 }
@@ -149,22 +141,82 @@ func GenerateEventServiceTopicDescriptions(device models.Device, service models.
 	if temp.DeviceName == "" {
 		temp.DeviceName = "unknown name"
 	}
+	temp.Transformations = GenerateTransformations(device, service)
+	temp.PayloadFormat, _ = GetAttributeValue(service.Attributes, PayloadFormatAttribute)
+	//device attribute overwrites service attribute
+	if timeout, found := GetAttributeValue(device.Attributes, LivenessTimeoutAttribute); found {
+		temp.LivenessTimeout = timeout
+	} else {
+		temp.LivenessTimeout, _ = GetAttributeValue(service.Attributes, LivenessTimeoutAttribute)
+	}
+	return []model.TopicDescription{temp}
+}
+
+// GenerateTransformations reads the transformations of a service from its attributes:
+// json-unwrap-* attributes contain a comma separated list of paths,
+// json-reshape-* attributes contain a json object mapping target paths to source expressions,
+// json-wrap-* attributes contain a comma separated list of paths with optional types (e.g. "value:number"),
+// value-map attributes contain a json object mapping paths to value maps (e.g. {"state": {"map": {"ON": true, "OFF": false}}}),
+// expression attributes contain a json object mapping paths to formulas (e.g. {"temp": {"formula": "value / 100", "inverse": "value * 100"}}),
+// binary-layout attributes contain a json object mapping paths to byte layouts (e.g. {"temp": {"offset": 0, "length": 2, "type": "int", "scale": 0.1}})
+func GenerateTransformations(device models.Device, service models.Service) (result []model.Transformation) {
 	for _, attr := range service.Attributes {
-		if attr.Key == model.TransformerJsonUnwrapInput || attr.Key == model.TransformerJsonUnwrapOutput {
+		switch attr.Key {
+		case model.TransformerJsonUnwrapInput, model.TransformerJsonUnwrapOutput:
 			paths := strings.Split(attr.Value, ",")
 			for _, path := range paths {
 				path = strings.TrimSpace(path)
-				temp.Transformations = append(temp.Transformations, model.Transformation{
+				result = append(result, model.Transformation{
 					Path:           path,
 					Transformation: attr.Key,
 				})
 			}
+		case model.TransformerJsonReshapeInput, model.TransformerJsonReshapeOutput:
+			mappings := map[string]string{}
+			err := json.Unmarshal([]byte(attr.Value), &mappings)
+			if err != nil {
+				log.Println("WARNING: invalid", attr.Key, "attribute in", device.Name, device.Id, device.LocalId, service.Name, service.Id, service.LocalId, err)
+				continue
+			}
+			for target, source := range mappings {
+				result = append(result, model.Transformation{
+					Path:           target,
+					Transformation: attr.Key,
+					Details:        source,
+				})
+			}
+		case model.TransformerValueMap, model.TransformerExpression, model.TransformerBinaryLayout:
+			details := map[string]json.RawMessage{}
+			err := json.Unmarshal([]byte(attr.Value), &details)
+			if err != nil {
+				log.Println("WARNING: invalid", attr.Key, "attribute in", device.Name, device.Id, device.LocalId, service.Name, service.Id, service.LocalId, err)
+				continue
+			}
+			for path, detail := range details {
+				result = append(result, model.Transformation{
+					Path:           path,
+					Transformation: attr.Key,
+					Details:        string(detail),
+				})
+			}
+		case model.TransformerJsonWrapInput, model.TransformerJsonWrapOutput:
+			for _, path := range strings.Split(attr.Value, ",") {
+				path, valueType, _ := strings.Cut(strings.TrimSpace(path), ":")
+				result = append(result, model.Transformation{
+					Path:           strings.TrimSpace(path),
+					Transformation: attr.Key,
+					Details:        strings.TrimSpace(valueType),
+				})
+			}
 		}
 	}
-	slices.SortFunc(temp.Transformations, func(a, b model.Transformation) int {
+	slices.SortFunc(result, func(a, b model.Transformation) int {
+		if a.Path == b.Path {
+			return strings.Compare(a.Transformation, b.Transformation)
+		}
 		return strings.Compare(a.Path, b.Path)
 	})
-	return []model.TopicDescription{temp}
+	return result
 }
 
 func GetAttributeValue(attributes []models.Attribute, key string) (result string, found bool) {
@@ -66,6 +66,8 @@ func TestGenerateTopicDescriptionsWithTruncate(t *testing.T) {
 					Attributes: []models.Attribute{
 						{Key: EventAttribute, Value: "{{.Device}}/withEventTransformer"},
 						{Key: model.TransformerJsonUnwrapOutput, Value: "foo.bar,foo.batz"},
+						{Key: model.TransformerJsonReshapeOutput, Value: `{"value": "$.foo.bar", "unit": "$.foo.unit"}`},
+						{Key: model.TransformerValueMap, Value: `{"state": {"map": {"ON": true, "OFF": false}, "unknown": "error"}}`},
 					},
 				},
 				{
@@ -75,6 +77,9 @@ func TestGenerateTopicDescriptionsWithTruncate(t *testing.T) {
 						{Key: ResponseAttribute, Value: "{{.Device}}/withCmdTransformer/resp"},
 						{Key: model.TransformerJsonUnwrapOutput, Value: "foo.bar2,foo.batz2"},
 						{Key: model.TransformerJsonUnwrapInput, Value: ""},
+						{Key: model.TransformerJsonWrapInput, Value: "value:number"},
+						{Key: model.TransformerExpression, Value: `{"value": {"formula": "value / 100", "inverse": "value * 100"}}`},
+						{Key: model.TransformerBinaryLayout, Value: `{"value": {"offset": 0, "length": 2, "type": "uint"}}`},
 					},
 				},
 				{
@@ -143,6 +148,21 @@ func TestGenerateTopicDescriptionsWithTruncate(t *testing.T) {
 					Path:           "foo.batz",
 					Transformation: model.TransformerJsonUnwrapOutput,
 				},
+				{
+					Path:           "state",
+					Transformation: model.TransformerValueMap,
+					Details:        `{"map": {"ON": true, "OFF": false}, "unknown": "error"}`,
+				},
+				{
+					Path:           "unit",
+					Transformation: model.TransformerJsonReshapeOutput,
+					Details:        "$.foo.unit",
+				},
+				{
+					Path:           "value",
+					Transformation: model.TransformerJsonReshapeOutput,
+					Details:        "$.foo.bar",
+				},
 			},
 		},
 		{
@@ -165,6 +185,21 @@ func TestGenerateTopicDescriptionsWithTruncate(t *testing.T) {
 					Path:           "foo.batz2",
 					Transformation: model.TransformerJsonUnwrapOutput,
 				},
+				{
+					Path:           "value",
+					Transformation: model.TransformerBinaryLayout,
+					Details:        `{"offset": 0, "length": 2, "type": "uint"}`,
+				},
+				{
+					Path:           "value",
+					Transformation: model.TransformerExpression,
+					Details:        `{"formula": "value / 100", "inverse": "value * 100"}`,
+				},
+				{
+					Path:           "value",
+					Transformation: model.TransformerJsonWrapInput,
+					Details:        "number",
+				},
 			},
 		},
 		{
@@ -182,6 +217,21 @@ func TestGenerateTopicDescriptionsWithTruncate(t *testing.T) {
 					Path:           "foo.batz",
 					Transformation: model.TransformerJsonUnwrapOutput,
 				},
+				{
+					Path:           "state",
+					Transformation: model.TransformerValueMap,
+					Details:        `{"map": {"ON": true, "OFF": false}, "unknown": "error"}`,
+				},
+				{
+					Path:           "unit",
+					Transformation: model.TransformerJsonReshapeOutput,
+					Details:        "$.foo.unit",
+				},
+				{
+					Path:           "value",
+					Transformation: model.TransformerJsonReshapeOutput,
+					Details:        "$.foo.bar",
+				},
 			},
 		},
 		{
@@ -204,6 +254,21 @@ func TestGenerateTopicDescriptionsWithTruncate(t *testing.T) {
 					Path:           "foo.batz2",
 					Transformation: model.TransformerJsonUnwrapOutput,
 				},
+				{
+					Path:           "value",
+					Transformation: model.TransformerBinaryLayout,
+					Details:        `{"offset": 0, "length": 2, "type": "uint"}`,
+				},
+				{
+					Path:           "value",
+					Transformation: model.TransformerExpression,
+					Details:        `{"formula": "value / 100", "inverse": "value * 100"}`,
+				},
+				{
+					Path:           "value",
+					Transformation: model.TransformerJsonWrapInput,
+					Details:        "number",
+				},
 			},
 		},
 		{
@@ -0,0 +1,235 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package homie
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultBaseTopic = "homie"
+const DefaultDebounce = time.Second
+
+// ValuePath is the json path of the property value in events and commands (e.g. {"value": 21.5})
+const ValuePath = "value"
+
+// homie $state values; init, sleeping and lost devices are not able to handle commands
+var StateOnlineValues = []string{"ready", "alert"}
+var StateOfflineValues = []string{"init", "disconnected", "sleeping", "lost"}
+
+type MqttClient interface {
+	Subscribe(topic string, qos byte, handler func(topic string, retained bool, payload []byte)) error
+}
+
+type Provider = func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error)
+
+// Discovery collects the retained attributes ($homie, $name, $nodes, $type, $properties, $datatype, $settable, ...)
+// of devices following the homie convention and describes their properties as topic descriptions
+type Discovery struct {
+	BaseTopic   string
+	DeviceTypes map[string]string //homie node type -> device type id
+	Debounce    time.Duration     //delay of the change notification after the last attribute change
+	Debug       bool
+	mux         sync.Mutex
+	attributes  map[string]string //topic without base topic -> value
+	onChange    func()
+	timer       *time.Timer
+}
+
+func New(config configuration.Config) *Discovery {
+	baseTopic := config.HomieBaseTopic
+	if baseTopic == "" {
+		baseTopic = DefaultBaseTopic
+	}
+	return &Discovery{
+		BaseTopic:   strings.TrimSuffix(baseTopic, "/"),
+		DeviceTypes: config.HomieDeviceTypes,
+		Debounce:    DefaultDebounce,
+		Debug:       config.Debug,
+		attributes:  map[string]string{},
+	}
+}
+
+// Start subscribes to all homie topics; onChange is called (debounced) if the attributes of a device change
+func (this *Discovery) Start(client MqttClient, onChange func()) error {
+	this.mux.Lock()
+	this.onChange = onChange
+	this.mux.Unlock()
+	return client.Subscribe(this.BaseTopic+"/#", 2, this.Handler)
+}
+
+func (this *Discovery) Handler(topic string, retained bool, payload []byte) {
+	key, ok := strings.CutPrefix(topic, this.BaseTopic+"/")
+	if !ok {
+		return
+	}
+	parts := strings.Split(key, "/")
+	if !strings.HasPrefix(parts[len(parts)-1], "$") || len(parts) > 4 {
+		//property values and sub topics of attributes (e.g. $stats/uptime)
+		return
+	}
+	value := string(payload)
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	old, known := this.attributes[key]
+	if value == "" {
+		//deleted retained message
+		delete(this.attributes, key)
+	} else {
+		this.attributes[key] = value
+	}
+	if (value == "" && !known) || old == value || strings.HasSuffix(key, "/$state") {
+		return
+	}
+	if this.Debug {
+		log.Println("DEBUG: homie attribute changed", topic, value)
+	}
+	this.notify()
+}
+
+func (this *Discovery) notify() {
+	if this.onChange == nil {
+		return
+	}
+	if this.timer == nil {
+		this.timer = time.AfterFunc(this.Debounce, this.onChange)
+	} else {
+		this.timer.Reset(this.Debounce)
+	}
+}
+
+// Provider merges the descriptions of base with the discovered descriptions;
+// discovered devices are ignored if base describes a device with the same local id
+func (this *Discovery) Provider(base Provider) Provider {
+	return func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		result, err := base(config, repo)
+		if err != nil {
+			return result, err
+		}
+		return Merge(result, this.Descriptions()), nil
+	}
+}
+
+func Merge(descriptions []model.TopicDescription, discovered []model.TopicDescription) []model.TopicDescription {
+	known := map[string]bool{}
+	for _, desc := range descriptions {
+		known[desc.DeviceLocalId] = true
+		for _, device := range desc.Devices {
+			known[device] = true
+		}
+	}
+	for _, desc := range discovered {
+		if !known[desc.DeviceLocalId] {
+			descriptions = append(descriptions, desc)
+		}
+	}
+	return descriptions
+}
+
+// Descriptions describes the properties of all discovered devices.
+// a device uses the device type of its first node with a mapped node type; nodes of other device types are ignored.
+// properties are events and, if settable, commands; their values are wrapped in {"value": ...}
+func (this *Discovery) Descriptions() (result []model.TopicDescription) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	devices := []string{}
+	for key := range this.attributes {
+		if device, ok := strings.CutSuffix(key, "/$homie"); ok && !strings.Contains(device, "/") {
+			devices = append(devices, device)
+		}
+	}
+	slices.Sort(devices)
+	for _, device := range devices {
+		result = append(result, this.describeDevice(device)...)
+	}
+	return result
+}
+
+func (this *Discovery) describeDevice(device string) (result []model.TopicDescription) {
+	prefix := this.BaseTopic + "/" + device
+	name := this.attributes[device+"/$name"]
+	if name == "" {
+		name = device
+	}
+	deviceTypeId := ""
+	for _, node := range splitList(this.attributes[device+"/$nodes"]) {
+		nodeDeviceType := this.DeviceTypes[this.attributes[device+"/"+node+"/$type"]]
+		if nodeDeviceType == "" {
+			continue
+		}
+		if deviceTypeId == "" {
+			deviceTypeId = nodeDeviceType
+		}
+		if nodeDeviceType != deviceTypeId {
+			continue
+		}
+		for _, property := range splitList(this.attributes[device+"/"+node+"/$properties"]) {
+			key := device + "/" + node + "/" + property
+			valueType := getValueType(this.attributes[key+"/$datatype"])
+			result = append(result, model.TopicDescription{
+				EventTopic:          this.BaseTopic + "/" + key,
+				StatusTopic:         prefix + "/$state",
+				StatusOnlineValues:  StateOnlineValues,
+				StatusOfflineValues: StateOfflineValues,
+				DeviceTypeId:        deviceTypeId,
+				DeviceLocalId:       device,
+				ServiceLocalId:      node + "/" + property,
+				DeviceName:          name,
+				Transformations:     []model.Transformation{{Path: ValuePath, Transformation: model.TransformerJsonWrapOutput, Details: valueType}},
+			})
+			if this.attributes[key+"/$settable"] == "true" {
+				result = append(result, model.TopicDescription{
+					CmdTopic:        this.BaseTopic + "/" + key + "/set",
+					DeviceTypeId:    deviceTypeId,
+					DeviceLocalId:   device,
+					ServiceLocalId:  node + "/" + property + "/set",
+					DeviceName:      name,
+					Transformations: []model.Transformation{{Path: ValuePath, Transformation: model.TransformerJsonWrapInput, Details: valueType}},
+				})
+			}
+		}
+	}
+	return result
+}
+
+// getValueType returns the json-wrap type of a homie data type; enum, color, datetime and duration values are strings
+func getValueType(dataType string) string {
+	switch dataType {
+	case "integer", "float":
+		return "number"
+	case "boolean":
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+func splitList(list string) (result []string) {
+	for _, element := range strings.Split(list, ",") {
+		element = strings.TrimSpace(element)
+		if element != "" {
+			result = append(result, element)
+		}
+	}
+	return result
+}
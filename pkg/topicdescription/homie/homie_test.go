@@ -0,0 +1,165 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package homie
+
+import (
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type mqttMock struct {
+	subscriptions []string
+}
+
+func (this *mqttMock) Subscribe(topic string, qos byte, handler func(topic string, retained bool, payload []byte)) error {
+	this.subscriptions = append(this.subscriptions, topic)
+	return nil
+}
+
+func TestDiscovery(t *testing.T) {
+	discovery := New(configuration.Config{HomieDeviceTypes: map[string]string{"thermostat": "dt-thermostat", "light": "dt-light"}})
+	discovery.Debounce = 20 * time.Millisecond
+	client := &mqttMock{}
+	changes := atomic.Int32{}
+	err := discovery.Start(client, func() {
+		changes.Add(1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(client.subscriptions, []string{"homie/#"}) {
+		t.Error(client.subscriptions)
+	}
+
+	for topic, value := range map[string]string{
+		"homie/heater/$homie":                           "4.0.0",
+		"homie/heater/$name":                            "Heater",
+		"homie/heater/$state":                           "ready",
+		"homie/heater/$nodes":                           "thermostat,light,unknown",
+		"homie/heater/$stats/uptime":                    "120",
+		"homie/heater/thermostat/$type":                 "thermostat",
+		"homie/heater/thermostat/$properties":           "temperature,target",
+		"homie/heater/thermostat/temperature/$datatype": "float",
+		"homie/heater/thermostat/temperature":           "21.5",
+		"homie/heater/thermostat/target/$datatype":      "integer",
+		"homie/heater/thermostat/target/$settable":      "true",
+		"homie/heater/light/$type":                      "light",
+		"homie/heater/light/$properties":                "on",
+		"homie/heater/unknown/$type":                    "unknown",
+		"homie/heater/unknown/$properties":              "mode",
+		"homie/other/$name":                             "without $homie",
+	} {
+		discovery.Handler(topic, true, []byte(value))
+	}
+
+	expected := []model.TopicDescription{
+		{
+			EventTopic:          "homie/heater/thermostat/temperature",
+			StatusTopic:         "homie/heater/$state",
+			StatusOnlineValues:  StateOnlineValues,
+			StatusOfflineValues: StateOfflineValues,
+			DeviceTypeId:        "dt-thermostat",
+			DeviceLocalId:       "heater",
+			ServiceLocalId:      "thermostat/temperature",
+			DeviceName:          "Heater",
+			Transformations:     []model.Transformation{{Path: "value", Transformation: model.TransformerJsonWrapOutput, Details: "number"}},
+		},
+		{
+			EventTopic:          "homie/heater/thermostat/target",
+			StatusTopic:         "homie/heater/$state",
+			StatusOnlineValues:  StateOnlineValues,
+			StatusOfflineValues: StateOfflineValues,
+			DeviceTypeId:        "dt-thermostat",
+			DeviceLocalId:       "heater",
+			ServiceLocalId:      "thermostat/target",
+			DeviceName:          "Heater",
+			Transformations:     []model.Transformation{{Path: "value", Transformation: model.TransformerJsonWrapOutput, Details: "number"}},
+		},
+		{
+			CmdTopic:        "homie/heater/thermostat/target/set",
+			DeviceTypeId:    "dt-thermostat",
+			DeviceLocalId:   "heater",
+			ServiceLocalId:  "thermostat/target/set",
+			DeviceName:      "Heater",
+			Transformations: []model.Transformation{{Path: "value", Transformation: model.TransformerJsonWrapInput, Details: "number"}},
+		},
+	}
+	if actual := discovery.Descriptions(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("\n%#v\n%#v", actual, expected)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if changes.Load() != 1 {
+		t.Error("expected one debounced change notification", changes.Load())
+	}
+
+	//state changes are handled by the status topic
+	discovery.Handler("homie/heater/$state", true, []byte("lost"))
+	discovery.Handler("homie/heater/thermostat/temperature", false, []byte("22"))
+	time.Sleep(100 * time.Millisecond)
+	if changes.Load() != 1 {
+		t.Error("unexpected change notification", changes.Load())
+	}
+
+	//removed device
+	discovery.Handler("homie/heater/$homie", true, []byte{})
+	time.Sleep(100 * time.Millisecond)
+	if changes.Load() != 2 || len(discovery.Descriptions()) != 0 {
+		t.Error("expected removed device", changes.Load(), discovery.Descriptions())
+	}
+}
+
+func TestProvider(t *testing.T) {
+	discovery := New(configuration.Config{HomieBaseTopic: "devices/homie/", HomieDeviceTypes: map[string]string{"sensor": "dt"}})
+	for topic, value := range map[string]string{
+		"devices/homie/a/$homie":        "4.0.0",
+		"devices/homie/a/$nodes":        "n",
+		"devices/homie/a/n/$type":       "sensor",
+		"devices/homie/a/n/$properties": "p",
+		"devices/homie/b/$homie":        "4.0.0",
+		"devices/homie/b/$nodes":        "n",
+		"devices/homie/b/n/$type":       "sensor",
+		"devices/homie/b/n/$properties": "p",
+		"devices/homie/b/n/p/$datatype": "boolean",
+		"devices/homie/b/n/p/$settable": "false",
+		"homie/c/$homie":                "4.0.0",
+	} {
+		discovery.Handler(topic, true, []byte(value))
+	}
+	provider := discovery.Provider(func(config configuration.Config, repo *devicerepo.DeviceRepo) ([]model.TopicDescription, error) {
+		return []model.TopicDescription{{EventTopic: "a/custom", DeviceLocalId: "a", ServiceLocalId: "s", DeviceTypeId: "dt", DeviceName: "a"}}, nil
+	})
+	result, err := provider(configuration.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topics := []string{}
+	for _, desc := range result {
+		topics = append(topics, desc.GetTopic())
+	}
+	if !reflect.DeepEqual(topics, []string{"a/custom", "devices/homie/b/n/p"}) {
+		t.Error(topics)
+	}
+	if result[1].DeviceName != "b" || result[1].Transformations[0].Details != "bool" {
+		t.Errorf("%#v", result[1])
+	}
+}
@@ -18,28 +18,54 @@ package model
 
 const TransformerJsonUnwrapInput = "json-unwrap-input"
 const TransformerJsonUnwrapOutput = "json-unwrap-output"
+const TransformerJsonReshapeInput = "json-reshape-input"
+const TransformerJsonReshapeOutput = "json-reshape-output"
+const TransformerJsonWrapInput = "json-wrap-input"
+const TransformerJsonWrapOutput = "json-wrap-output"
+const TransformerValueMap = "value-map"
+const TransformerExpression = "expression"
+const TransformerBinaryLayout = "binary-layout"
 
 type TopicDescription struct {
-	CmdTopic        string           `json:"cmd_topic" yaml:"cmd_topic"`
-	EventTopic      string           `json:"event_topic" yaml:"event_topic"`
-	RespTopic       string           `json:"resp_topic" yaml:"resp_topic"`
-	DeviceTypeId    string           `json:"device_type_id" yaml:"device_type_id"`
-	DeviceLocalId   string           `json:"device_local_id" yaml:"device_local_id"`
-	ServiceLocalId  string           `json:"service_local_id" yaml:"service_local_id"`
-	Transformations []Transformation `json:"transformations" yaml:"transformations"`
-	DeviceName      string           `json:"device_name" yaml:"device_name"`
+	CmdTopic            string            `json:"cmd_topic" yaml:"cmd_topic"`
+	EventTopic          string            `json:"event_topic" yaml:"event_topic"`
+	RespTopic           string            `json:"resp_topic" yaml:"resp_topic"`
+	RespTimeout         string            `json:"resp_timeout,omitempty" yaml:"resp_timeout,omitempty"`
+	CorrelationPath     string            `json:"correlation_path,omitempty" yaml:"correlation_path,omitempty"`           //json path in command and response payload used to correlate responses to commands
+	CorrelationToken    string            `json:"correlation_token,omitempty" yaml:"correlation_token,omitempty"`         //"command_id" (default) or "generated"
+	UserProperties      map[string]string `json:"user_properties,omitempty" yaml:"user_properties,omitempty"`             //mqtt 5 user properties of published commands
+	MessageExpiry       string            `json:"message_expiry,omitempty" yaml:"message_expiry,omitempty"`               //mqtt 5 message expiry of published commands as duration
+	LivenessTimeout     string            `json:"liveness_timeout,omitempty" yaml:"liveness_timeout,omitempty"`           //device is offline if no event is received within this duration; "-" disables the global liveness_timeout
+	StatusTopic         string            `json:"status_topic,omitempty" yaml:"status_topic,omitempty"`                   //availability topic of the device (e.g. tele/x/LWT)
+	StatusPath          string            `json:"status_path,omitempty" yaml:"status_path,omitempty"`                     //optional json path of the state in status messages
+	StatusOnlineValues  []string          `json:"status_online_values,omitempty" yaml:"status_online_values,omitempty"`   //default: online, true, 1
+	StatusOfflineValues []string          `json:"status_offline_values,omitempty" yaml:"status_offline_values,omitempty"` //default: offline, false, 0
+	DeviceTypeId        string            `json:"device_type_id" yaml:"device_type_id"`
+	DeviceLocalId       string            `json:"device_local_id" yaml:"device_local_id"`
+	ServiceLocalId      string            `json:"service_local_id" yaml:"service_local_id"`
+	Transformations     []Transformation  `json:"transformations" yaml:"transformations"`
+	PayloadFormat       string            `json:"payload_format,omitempty" yaml:"payload_format,omitempty"`           //json (default), text, cbor, msgpack, xml, protobuf, sparkplug or a format registered with codec.Register
+	ProtobufDescriptor  string            `json:"protobuf_descriptor,omitempty" yaml:"protobuf_descriptor,omitempty"` //FileDescriptorSet file of payload_format protobuf; relative to the file of the description
+	ProtobufMessage     string            `json:"protobuf_message,omitempty" yaml:"protobuf_message,omitempty"`       //full name of the message of payload_format protobuf (e.g. factory.v1.Reading)
+	SparkplugMetrics    []string          `json:"sparkplug_metrics,omitempty" yaml:"sparkplug_metrics,omitempty"`     //metric name patterns (path.Match) forwarded by sparkplug event topics; default: all metrics
+	DeviceName          string            `json:"device_name" yaml:"device_name"`
+	Devices             []string          `json:"devices,omitempty" yaml:"devices,omitempty"` //device ids for event topics with a {device} capture; unknown devices are learned on their first event if empty
 }
 
 type Transformation struct {
 	Path           string `json:"path" yaml:"path"`
 	Transformation string `json:"transformation" yaml:"transformation"`
+	Details        string `json:"details,omitempty" yaml:"details,omitempty"` //kind specific parameter (e.g. the source expression of json-reshape)
 }
 
 func (this TopicDescription) GetTopic() string {
 	if this.EventTopic != "" {
 		return this.EventTopic
 	}
-	return this.CmdTopic
+	if this.CmdTopic != "" {
+		return this.CmdTopic
+	}
+	return this.StatusTopic
 }
 
 func (this TopicDescription) GetEventTopic() string {
@@ -58,6 +84,46 @@ func (this TopicDescription) GetResponseTopic() string {
 	return this.RespTopic
 }
 
+func (this TopicDescription) GetResponseTimeout() string {
+	return this.RespTimeout
+}
+
+func (this TopicDescription) GetCorrelationPath() string {
+	return this.CorrelationPath
+}
+
+func (this TopicDescription) GetCorrelationToken() string {
+	return this.CorrelationToken
+}
+
+func (this TopicDescription) GetUserProperties() map[string]string {
+	return this.UserProperties
+}
+
+func (this TopicDescription) GetMessageExpiry() string {
+	return this.MessageExpiry
+}
+
+func (this TopicDescription) GetLivenessTimeout() string {
+	return this.LivenessTimeout
+}
+
+func (this TopicDescription) GetStatusTopic() string {
+	return this.StatusTopic
+}
+
+func (this TopicDescription) GetStatusPath() string {
+	return this.StatusPath
+}
+
+func (this TopicDescription) GetStatusOnlineValues() []string {
+	return this.StatusOnlineValues
+}
+
+func (this TopicDescription) GetStatusOfflineValues() []string {
+	return this.StatusOfflineValues
+}
+
 func (this TopicDescription) GetDeviceTypeId() string {
 	return this.DeviceTypeId
 }
@@ -70,6 +136,26 @@ func (this TopicDescription) GetLocalServiceId() string {
 	return this.ServiceLocalId
 }
 
+func (this TopicDescription) GetDevices() []string {
+	return this.Devices
+}
+
+func (this TopicDescription) GetPayloadFormat() string {
+	return this.PayloadFormat
+}
+
+func (this TopicDescription) GetProtobufDescriptor() string {
+	return this.ProtobufDescriptor
+}
+
+func (this TopicDescription) GetProtobufMessage() string {
+	return this.ProtobufMessage
+}
+
+func (this TopicDescription) GetSparkplugMetrics() []string {
+	return this.SparkplugMetrics
+}
+
 func (this TopicDescription) HasTransformations() bool {
 	return len(this.Transformations) > 0
 }
@@ -82,3 +168,13 @@ func (this TopicDescription) GetTransformations(kind string) (result []string) {
 	}
 	return result
 }
+
+func (this TopicDescription) GetTransformationDetails(kind string) (result map[string]string) {
+	result = map[string]string{}
+	for _, trans := range this.Transformations {
+		if trans.Transformation == kind {
+			result[trans.Path] = trans.Details
+		}
+	}
+	return result
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package topicdescription
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/codec"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// Source is the location of a topic description or of a problem while loading topic descriptions
+type Source struct {
+	File string `json:"file"`
+	Line int    `json:"line,omitempty"`
+}
+
+type LoadProblem struct {
+	Source
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"` //the file has been ignored but no descriptions are missing
+}
+
+// LoadDirWithSources loads the topic descriptions of the dir tree and returns the source of each description.
+// files which can not be loaded are reported as problems.
+func LoadDirWithSources(dir string) (topicDescriptions []model.TopicDescription, sources []Source, problems []LoadProblem, err error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return topicDescriptions, sources, problems, err
+	}
+	for _, file := range files {
+		p := filepath.Join(dir, file.Name())
+		if file.IsDir() {
+			descriptions, descSources, descProblems, err := LoadDirWithSources(p)
+			if err != nil {
+				return topicDescriptions, sources, problems, err
+			}
+			topicDescriptions = append(topicDescriptions, descriptions...)
+			sources = append(sources, descSources...)
+			problems = append(problems, descProblems...)
+			continue
+		}
+		if IsTempFile(file.Name()) || filepath.Ext(file.Name()) == ".md" {
+			continue
+		}
+		var descriptions []model.TopicDescription
+		var lines []int
+		switch filepath.Ext(file.Name()) {
+		case ".json":
+			descriptions, lines, err = loadJsonWithLines(p)
+		case ".csv":
+			descriptions, lines, err = loadCsvWithLines(p)
+		case ".yml", ".yaml":
+			descriptions, lines, err = loadYamlWithLines(p)
+		case ".desc", ".binpb", ".pb":
+			err = codec.LoadProtobufDescriptorSet(p)
+			if err != nil {
+				problems = append(problems, newLoadProblem(p, err))
+			}
+			continue
+		default:
+			problems = append(problems, LoadProblem{Source: Source{File: p}, Message: "unknown file type in topic-descriptions directory", Warning: true})
+			continue
+		}
+		if err != nil {
+			problems = append(problems, newLoadProblem(p, err))
+			continue
+		}
+		topicDescriptions = append(topicDescriptions, resolveProtobufDescriptors(dir, descriptions)...)
+		for _, line := range lines {
+			sources = append(sources, Source{File: p, Line: line})
+		}
+	}
+	return topicDescriptions, sources, problems, nil
+}
+
+// sourceError is a load error with a known line
+type sourceError struct {
+	line int
+	err  error
+}
+
+func (this sourceError) Error() string {
+	return this.err.Error()
+}
+
+var yamlErrLine = regexp.MustCompile(`line (\d+)`)
+
+func newLoadProblem(file string, err error) LoadProblem {
+	result := LoadProblem{Source: Source{File: file}, Message: err.Error()}
+	var srcErr sourceError
+	if errors.As(err, &srcErr) {
+		result.Line = srcErr.line
+		return result
+	}
+	var csvErr *csv.ParseError
+	if errors.As(err, &csvErr) {
+		result.Line = csvErr.Line
+		return result
+	}
+	if match := yamlErrLine.FindStringSubmatch(err.Error()); match != nil {
+		result.Line, _ = strconv.Atoi(match[1])
+	}
+	return result
+}
+
+func loadJsonWithLines(location string) (topicDescriptions []model.TopicDescription, lines []int, err error) {
+	content, err := os.ReadFile(location)
+	if err != nil {
+		return topicDescriptions, lines, err
+	}
+	lineOf := func(offset int64) int {
+		return bytes.Count(content[:min(int(offset), len(content))], []byte("\n")) + 1
+	}
+	wrap := func(err error, offset int64) error {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			offset = typeErr.Offset
+		}
+		return sourceError{line: lineOf(offset), err: err}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	token, err := decoder.Token()
+	if err != nil {
+		return topicDescriptions, lines, wrap(err, decoder.InputOffset())
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return topicDescriptions, lines, sourceError{line: lineOf(decoder.InputOffset()), err: errors.New("expect list of topic descriptions")}
+	}
+	for decoder.More() {
+		//skip whitespace and separators to find the start of the element
+		start := decoder.InputOffset()
+		for start < int64(len(content)) && bytes.ContainsRune([]byte(" \t\r\n,"), rune(content[start])) {
+			start++
+		}
+		desc := model.TopicDescription{}
+		err = decoder.Decode(&desc)
+		if err != nil {
+			return topicDescriptions, lines, wrap(err, start)
+		}
+		topicDescriptions = append(topicDescriptions, desc)
+		lines = append(lines, lineOf(start))
+	}
+	_, err = decoder.Token()
+	if err != nil {
+		return topicDescriptions, lines, wrap(err, decoder.InputOffset())
+	}
+	return topicDescriptions, lines, nil
+}
+
+func loadYamlWithLines(location string) (topicDescriptions []model.TopicDescription, lines []int, err error) {
+	file, err := os.Open(location)
+	if err != nil {
+		return topicDescriptions, lines, err
+	}
+	defer file.Close()
+	doc := yaml.Node{}
+	err = yaml.NewDecoder(file).Decode(&doc)
+	if errors.Is(err, io.EOF) {
+		return topicDescriptions, lines, nil
+	}
+	if err != nil {
+		return topicDescriptions, lines, err
+	}
+	if len(doc.Content) == 0 {
+		return topicDescriptions, lines, nil
+	}
+	list := doc.Content[0]
+	if list.Kind != yaml.SequenceNode {
+		return topicDescriptions, lines, sourceError{line: list.Line, err: errors.New("expect list of topic descriptions")}
+	}
+	for _, element := range list.Content {
+		desc := model.TopicDescription{}
+		err = element.Decode(&desc)
+		if err != nil {
+			return topicDescriptions, lines, sourceError{line: element.Line, err: err}
+		}
+		topicDescriptions = append(topicDescriptions, desc)
+		lines = append(lines, element.Line)
+	}
+	return topicDescriptions, lines, nil
+}
+
+func loadCsvWithLines(location string) (topicDescriptions []model.TopicDescription, lines []int, err error) {
+	file, err := os.Open(location)
+	if err != nil {
+		return topicDescriptions, lines, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return topicDescriptions, lines, nil
+		}
+		if err != nil {
+			return topicDescriptions, lines, err
+		}
+		line, _ := reader.FieldPos(0)
+		desc, err := csvLineToTopicDescription(record)
+		if err != nil {
+			return topicDescriptions, lines, sourceError{line: line, err: err}
+		}
+		topicDescriptions = append(topicDescriptions, desc)
+		lines = append(lines, line)
+	}
+}
@@ -17,15 +17,11 @@
 package topicdescription
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"errors"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/configuration"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/devicerepo"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
-	"gopkg.in/yaml.v2"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 )
@@ -38,125 +34,69 @@ func Load(config configuration.Config, deviceRepo *devicerepo.DeviceRepo) (topic
 	}
 }
 
+// LoadDir loads the topic descriptions of the dir tree; files which can not be loaded are logged and ignored
 func LoadDir(dir string) (topicDescriptions []model.TopicDescription, err error) {
-	topicDescriptions = []model.TopicDescription{}
-	files, err := os.ReadDir(dir)
-	if err != nil {
-		return topicDescriptions, err
+	topicDescriptions, _, problems, err := LoadDirWithSources(dir)
+	if topicDescriptions == nil {
+		topicDescriptions = []model.TopicDescription{}
 	}
-	for _, file := range files {
-		p := filepath.Join(dir, file.Name())
-		if file.IsDir() {
-			temp, err := LoadDir(p)
-			if err != nil {
-				return topicDescriptions, err
-			}
-			topicDescriptions = append(topicDescriptions, temp...)
+	for _, problem := range problems {
+		if problem.Warning {
+			log.Println("WARNING:", problem.Message, problem.File)
 		} else {
-			ext := filepath.Ext(file.Name())
-			switch ext {
-			case ".md":
-				//ignore and do not warn
-			case ".json":
-				temp, err := LoadJson(p)
-				if err != nil {
-					log.Println("WARNING: unable to load", p, err)
-					continue
-				}
-				topicDescriptions = append(topicDescriptions, temp...)
-			case ".csv":
-				temp, err := LoadCsv(p)
-				if err != nil {
-					log.Println("WARNING: unable to load", p, err)
-					continue
-				}
-				topicDescriptions = append(topicDescriptions, temp...)
-			case ".yml":
-				fallthrough
-			case ".yaml":
-				temp, err := LoadYaml(p)
-				if err != nil {
-					log.Println("WARNING: unable to load", p, err)
-					continue
-				}
-				topicDescriptions = append(topicDescriptions, temp...)
-			default:
-				log.Println("WARNING: unknown file type in topic-descriptions directory", ext, file.Name())
-			}
+			log.Println("WARNING: unable to load", problem.File, problem.Message)
 		}
 	}
-	return topicDescriptions, nil
+	return topicDescriptions, err
 }
 
-func LoadJson(location string) (topicDescriptions []model.TopicDescription, err error) {
-	file, err := os.Open(location)
-	if err != nil {
-		log.Println("error on config load:\n", location, "\n", err)
-		return topicDescriptions, err
+// resolveProtobufDescriptors makes protobuf descriptor paths relative to the directory of their topic descriptions
+func resolveProtobufDescriptors(dir string, topicDescriptions []model.TopicDescription) []model.TopicDescription {
+	for i, desc := range topicDescriptions {
+		if desc.ProtobufDescriptor != "" && !filepath.IsAbs(desc.ProtobufDescriptor) {
+			topicDescriptions[i].ProtobufDescriptor = filepath.Join(dir, desc.ProtobufDescriptor)
+		}
 	}
-	err = json.NewDecoder(file).Decode(&topicDescriptions)
+	return topicDescriptions
+}
+
+func LoadJson(location string) (topicDescriptions []model.TopicDescription, err error) {
+	topicDescriptions, _, err = loadJsonWithLines(location)
 	if err != nil {
 		log.Println("error on config load:\n", location, "\n", err)
-		return topicDescriptions, err
 	}
-	return topicDescriptions, nil
+	return topicDescriptions, err
 }
 
 func LoadYaml(location string) (topicDescriptions []model.TopicDescription, err error) {
-	file, err := os.Open(location)
-	if err != nil {
-		log.Println("error on config load:\n", location, "\n", err)
-		return topicDescriptions, err
-	}
-	err = yaml.NewDecoder(file).Decode(&topicDescriptions)
+	topicDescriptions, _, err = loadYamlWithLines(location)
 	if err != nil {
 		log.Println("error on config load:\n", location, "\n", err)
-		return topicDescriptions, err
 	}
-	return topicDescriptions, nil
+	return topicDescriptions, err
 }
 
 func LoadCsv(location string) (topicDescriptions []model.TopicDescription, err error) {
-	file, err := os.Open(location)
+	topicDescriptions, _, err = loadCsvWithLines(location)
 	if err != nil {
 		log.Println("error on config load:\n", location, "\n", err)
-		return topicDescriptions, err
 	}
-	reader := csv.NewReader(file)
-	reader.Comment = '#'
-	reader.FieldsPerRecord = -1
-	reader.TrimLeadingSpace = true
-	lines, err := reader.ReadAll()
-	if err != nil {
-		log.Println("error on config load:\n", location, "\n", err)
-		return topicDescriptions, err
+	return topicDescriptions, err
+}
+
+func csvLineToTopicDescription(line []string) (result model.TopicDescription, err error) {
+	rows := len(line)
+	if rows != 6 && rows != 7 {
+		return result, errors.New("invalid cow count (expect 6 or 7 rows)")
 	}
-	for _, line := range lines {
-		temp := model.TopicDescription{
-			CmdTopic:       "",
-			EventTopic:     "",
-			RespTopic:      "",
-			DeviceTypeId:   "",
-			DeviceLocalId:  "",
-			ServiceLocalId: "",
-			DeviceName:     "",
-		}
-		rows := len(line)
-		if rows != 6 && rows != 7 {
-			err = errors.New("invalid cow count (expect 6 or 7 rows)")
-			log.Println("error on config load:\n", location, "\n", err)
-			return topicDescriptions, err
-		}
-		temp.CmdTopic = strings.TrimSpace(line[0])
-		temp.EventTopic = strings.TrimSpace(line[1])
-		temp.DeviceLocalId = strings.TrimSpace(line[2])
-		temp.DeviceName = strings.TrimSpace(line[3])
-		temp.DeviceTypeId = strings.TrimSpace(line[4])
-		temp.ServiceLocalId = strings.TrimSpace(line[5])
-		if rows == 7 {
-			temp.RespTopic = strings.TrimSpace(line[6])
-		}
-		topicDescriptions = append(topicDescriptions, temp)
+	result.CmdTopic = strings.TrimSpace(line[0])
+	result.EventTopic = strings.TrimSpace(line[1])
+	result.DeviceLocalId = strings.TrimSpace(line[2])
+	result.DeviceName = strings.TrimSpace(line[3])
+	result.DeviceTypeId = strings.TrimSpace(line[4])
+	result.ServiceLocalId = strings.TrimSpace(line[5])
+	if rows == 7 {
+		result.RespTopic = strings.TrimSpace(line[6])
 	}
-	return topicDescriptions, nil
+	return result, nil
 }
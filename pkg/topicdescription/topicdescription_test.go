@@ -18,7 +18,10 @@ package topicdescription
 
 import (
 	"encoding/json"
+	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/codec"
 	"github.com/SENERGY-Platform/mgw-mqtt-dc/pkg/topicdescription/model"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
@@ -199,3 +202,46 @@ func generateExpected(topics []string, withResp bool) (result []model.TopicDescr
 	}
 	return result
 }
+
+func TestLoadDirProtobufDescriptor(t *testing.T) {
+	dir := t.TempDir()
+	descriptorSet, err := os.ReadFile("../codec/testdata/sensors.binpb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "sub", "sensors.binpb"), descriptorSet, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "sub", "sensors.yaml"), []byte(`
+- event_topic: sensors/1
+  device_type_id: dtid
+  device_local_id: sensor_1
+  service_local_id: reading
+  device_name: sensor 1
+  payload_format: protobuf
+  protobuf_descriptor: sensors.binpb
+  protobuf_message: factory.v1.Reading
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 {
+		t.Fatal(result)
+	}
+	if result[0].ProtobufDescriptor != filepath.Join(dir, "sub", "sensors.binpb") {
+		t.Error(result[0].ProtobufDescriptor)
+	}
+	_, err = codec.GetProtobuf(result[0].ProtobufDescriptor, result[0].ProtobufMessage)
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package topicdescription
+
+import (
+	"context"
+	"github.com/fsnotify/fsnotify"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watch calls onChange if topic-description files in the dir tree are created, changed, renamed or removed.
+// bursts of changes within debounce result in a single call. new subdirectories are watched automatically.
+// changes in ignoreDirs (e.g. the directory of generated topic-descriptions) and editor temp files are ignored.
+func Watch(ctx context.Context, dir string, debounce time.Duration, ignoreDirs []string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w := &dirWatcher{
+		watcher:  watcher,
+		debounce: debounce,
+		onChange: onChange,
+	}
+	for _, ignore := range ignoreDirs {
+		if ignore == "" {
+			continue
+		}
+		abs, err := filepath.Abs(ignore)
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+		w.ignoreDirs = append(w.ignoreDirs, abs)
+	}
+	err = w.addTree(dir)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		watcher.Close()
+		w.stop()
+	}()
+	go w.run()
+	return nil
+}
+
+type dirWatcher struct {
+	watcher    *fsnotify.Watcher
+	debounce   time.Duration
+	ignoreDirs []string
+	onChange   func()
+	mux        sync.Mutex
+	timer      *time.Timer
+	stopped    bool
+}
+
+func (this *dirWatcher) run() {
+	for {
+		select {
+		case event, ok := <-this.watcher.Events:
+			if !ok {
+				return
+			}
+			this.handle(event)
+		case err, ok := <-this.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("WARNING: topic-description watcher error", err)
+		}
+	}
+}
+
+func (this *dirWatcher) handle(event fsnotify.Event) {
+	if this.isIgnored(event.Name) || (event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write)) {
+		return
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			err = this.addTree(event.Name)
+			if err != nil {
+				log.Println("WARNING: unable to watch new topic-description directory", event.Name, err)
+			}
+			//files may have been created before the directory has been watched
+			this.trigger()
+			return
+		}
+	}
+	if IsTempFile(filepath.Base(event.Name)) {
+		return
+	}
+	ext := filepath.Ext(event.Name)
+	//removed or renamed directories have no extension
+	if ext == "" || IsTopicDescriptionFileExt(ext) {
+		this.trigger()
+	}
+}
+
+func (this *dirWatcher) trigger() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.stopped {
+		return
+	}
+	if this.timer != nil {
+		this.timer.Stop()
+	}
+	this.timer = time.AfterFunc(this.debounce, this.onChange)
+}
+
+func (this *dirWatcher) stop() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.stopped = true
+	if this.timer != nil {
+		this.timer.Stop()
+	}
+}
+
+func (this *dirWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if this.isIgnored(path) {
+			return filepath.SkipDir
+		}
+		return this.watcher.Add(path)
+	})
+}
+
+func (this *dirWatcher) isIgnored(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, ignore := range this.ignoreDirs {
+		if abs == ignore || strings.HasPrefix(abs, ignore+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTempFile reports if the file name is used by editors for temporary or backup files
+func IsTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") ||
+		strings.HasPrefix(name, "#") ||
+		strings.HasSuffix(name, "~") ||
+		strings.HasSuffix(name, ".swp") ||
+		strings.HasSuffix(name, ".swx") ||
+		strings.HasSuffix(name, ".tmp") ||
+		name == "4913" //vim write test file
+}
+
+func IsTopicDescriptionFileExt(ext string) bool {
+	switch ext {
+	case ".json", ".csv", ".yml", ".yaml", ".desc", ".binpb", ".pb":
+		return true
+	default:
+		return false
+	}
+}
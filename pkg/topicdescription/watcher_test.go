@@ -0,0 +1,115 @@
+/*
+ * Copyright 2025 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package topicdescription
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	generated := filepath.Join(dir, "generated")
+	err := os.MkdirAll(generated, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := atomic.Int64{}
+	err = Watch(ctx, dir, 200*time.Millisecond, []string{generated}, func() {
+		calls.Add(1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectCalls := func(t *testing.T, expected int64) {
+		t.Helper()
+		time.Sleep(500 * time.Millisecond)
+		if actual := calls.Swap(0); actual != expected {
+			t.Error(actual, expected)
+		}
+	}
+
+	t.Run("burst of changes", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			err = os.WriteFile(filepath.Join(dir, "a.json"), []byte("[]"), 0666)
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		expectCalls(t, 1)
+	})
+
+	t.Run("temp files", func(t *testing.T) {
+		for _, name := range []string{".a.json.swp", "a.json~", "#a.json#", "4913", "readme.md"} {
+			err = os.WriteFile(filepath.Join(dir, name), []byte("foo"), 0666)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		expectCalls(t, 0)
+	})
+
+	t.Run("ignored dir", func(t *testing.T) {
+		err = os.WriteFile(filepath.Join(generated, "g.json"), []byte("[]"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectCalls(t, 0)
+	})
+
+	t.Run("new sub dir", func(t *testing.T) {
+		sub := filepath.Join(dir, "sub", "subsub")
+		err = os.MkdirAll(sub, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectCalls(t, 1)
+		err = os.WriteFile(filepath.Join(sub, "b.yaml"), []byte("[]"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectCalls(t, 1)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		err = os.Remove(filepath.Join(dir, "a.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectCalls(t, 1)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		cancel()
+		time.Sleep(100 * time.Millisecond)
+		err = os.WriteFile(filepath.Join(dir, "c.json"), []byte("[]"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectCalls(t, 0)
+	})
+}